@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,12 +14,19 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/gin-gonic/gin"
+
 	"github.com/k1nky/ypmetrics/internal/apiclient"
 	"github.com/k1nky/ypmetrics/internal/collector"
 	"github.com/k1nky/ypmetrics/internal/config"
 	"github.com/k1nky/ypmetrics/internal/crypto"
+	"github.com/k1nky/ypmetrics/internal/filewatch"
+	"github.com/k1nky/ypmetrics/internal/ingest"
 	"github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/retrier"
+	"github.com/k1nky/ypmetrics/internal/status"
 	"github.com/k1nky/ypmetrics/internal/storage"
+	"github.com/k1nky/ypmetrics/internal/tlsconfig"
 	"github.com/k1nky/ypmetrics/internal/usecases/poller"
 )
 
@@ -26,12 +34,21 @@ const (
 	DefaultProfilerAddress = "localhost:8099"
 )
 
+const (
+	// количество неудачных отправок подряд, после которого отправка приостанавливается
+	DefaultRetryBreakerThreshold = 5
+)
+
 var (
 	buildVersion string = "N/A"
 	buildDate    string = "N/A"
 	buildCommit  string = "N/A"
 )
 
+func init() {
+	gin.SetMode(gin.ReleaseMode)
+}
+
 func main() {
 	l := logger.New()
 	cfg := config.DefaultPollerConfig
@@ -75,6 +92,54 @@ func exposeProfiler(ctx context.Context, l *logger.Logger) {
 	}()
 }
 
+// exposeStatus показывает состояние агента и текущие метрики на адресе cfg.StatusAddress.
+func exposeStatus(ctx context.Context, cfg config.Poller, h *status.Handler, l *logger.Logger) {
+	server := http.Server{
+		Addr:    cfg.StatusAddress,
+		Handler: h.Router(),
+	}
+	go func() {
+		l.Infof("expose status on %s/status", cfg.StatusAddress)
+		if err := server.ListenAndServe(); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				l.Errorf("unexpected status closing: %v", err)
+			}
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				l.Errorf("unexpected error: %v", err)
+			}
+		}
+	}()
+}
+
+// exposeIngest начинает прием метрик от локальных приложений, если задан адрес или сокет.
+// Полученные метрики сохраняются в хранилище агента и отправляются на сервер вместе с собственными.
+func exposeIngest(ctx context.Context, cfg config.Poller, store *storage.MemStorage, l *logger.Logger) error {
+	if len(cfg.IngestAddress) == 0 && len(cfg.IngestSocket) == 0 {
+		return nil
+	}
+	s := ingest.New(store, l)
+	if len(cfg.IngestAddress) > 0 {
+		addr, err := s.Serve(ctx, ingest.NetworkTCP, cfg.IngestAddress)
+		if err != nil {
+			return err
+		}
+		l.Infof("accept metrics on %s", addr)
+	}
+	if len(cfg.IngestSocket) > 0 {
+		addr, err := s.Serve(ctx, ingest.NetworkUnix, cfg.IngestSocket)
+		if err != nil {
+			return err
+		}
+		l.Infof("accept metrics on unix:%s", addr)
+	}
+	return nil
+}
+
 func exit(rc int) {
 	os.Exit(rc)
 }
@@ -107,6 +172,43 @@ func readCryptoKey(path string) (*rsa.PublicKey, error) {
 	return key, err
 }
 
+// newTLSConfig возвращает настройки TLS агента или nil, если агент должен подключаться по HTTP.
+// Файлы сертификатов отслеживаются и перечитываются при изменении.
+func newTLSConfig(ctx context.Context, cfg config.Poller, l *logger.Logger) (*tls.Config, error) {
+	if !cfg.IsTLSEnabled() {
+		return nil, nil
+	}
+	var (
+		kp      *tlsconfig.KeyPair
+		rootCAs *tlsconfig.CAPool
+		err     error
+	)
+	if len(cfg.TLSCert) > 0 && len(cfg.TLSKey) > 0 {
+		if kp, err = tlsconfig.NewKeyPair(cfg.TLSCert, cfg.TLSKey); err != nil {
+			return nil, err
+		}
+		kp.Watch(ctx, filewatch.DefaultInterval, l)
+	}
+	if len(cfg.TLSCA) > 0 {
+		if rootCAs, err = tlsconfig.NewCAPool(cfg.TLSCA); err != nil {
+			return nil, err
+		}
+		rootCAs.Watch(ctx, filewatch.DefaultInterval, l)
+	}
+	return tlsconfig.NewClientConfig(kp, rootCAs), nil
+}
+
+// newRetrier возвращает контроллер повторной отправки метрик. Повторы не должны длиться дольше
+// интервала отправки, а при недоступности сервера отправка приостанавливается на тот же интервал.
+func newRetrier(cfg config.Poller) *retrier.Retrier {
+	policy := retrier.DefaultPolicy
+	// разносим во времени повторы от разных агентов
+	policy.Jitter = true
+	policy.MaxElapsedTime = cfg.ReportInterval()
+	breaker := retrier.NewBreaker(DefaultRetryBreakerThreshold, cfg.ReportInterval())
+	return retrier.NewWithPolicy(policy).SetBreaker(breaker)
+}
+
 func run(ctx context.Context, l *logger.Logger, cfg config.Poller) {
 	// для агента храним метрики в памяти
 	store := storage.NewMemStorage()
@@ -118,27 +220,62 @@ func run(ctx context.Context, l *logger.Logger, cfg config.Poller) {
 		l.Errorf("config: %s", err)
 		exit(1)
 	}
+	tlsConfig, err := newTLSConfig(ctx, cfg, l)
+	if err != nil {
+		l.Errorf("config: %s", err)
+		exit(1)
+	}
+	client.SetTLS(tlsConfig)
+	client.SetRetrier(newRetrier(cfg))
 	// сжимаем данные -> шифруем -> подписываем
-	client.SetGzip().SetEncrypt(key).SetKey(cfg.Key)
+	client.SetGzip().SetEncrypt(key).SetKeyWithID(cfg.KeyID, cfg.Key).SetToken(cfg.Token)
+	// сервер может ограничивать доступ по адресу агента, поэтому передаем адрес исходящего интерфейса
+	if ip, err := client.OutboundIP(); err != nil {
+		l.Warnf("detect outbound address: %v", err)
+	} else {
+		client.SetRealIP(ip)
+	}
 
 	p := poller.New(cfg, store, l, client)
-	p.AddCollector(
-		&collector.PollCounter{},
-		&collector.Random{},
-		&collector.Runtime{},
-		&collector.Gops{},
-	)
+	collectors, err := collector.DefaultRegistry.Build(cfg.Collectors)
+	if err != nil {
+		l.Errorf("config: collectors: %s", err)
+		exit(1)
+	}
+	for _, c := range collectors {
+		if s, ok := c.Collector.(collector.LoggerSetter); ok {
+			s.SetLogger(l)
+		}
+		if err := p.AddNamedCollector(c.Name, c.Collector, c.Config); err != nil {
+			// сборщик, включенный только по умолчанию, может не работать в этой системе
+			if c.Config.Enabled == nil && errors.Is(err, collector.ErrUnavailable) {
+				l.Debugf("skip collector %s: %s", c.Name, err)
+			} else {
+				l.Errorf("failed initializing collector %s: %s", c.Name, err)
+			}
+		}
+	}
 
+	if err := exposeIngest(ctx, cfg, store, l); err != nil {
+		l.Errorf("ingest: %s", err)
+		exit(1)
+	}
 	done := p.Run(ctx)
 	if cfg.EnableProfiling {
 		exposeProfiler(ctx, l)
 	}
+	if len(cfg.StatusAddress) > 0 {
+		h := status.New(store, p, cfg).SetBreaker(client.Retrier().Breaker())
+		exposeStatus(ctx, cfg, h, l)
+	}
 	// ожидаем завершения программы по сигналу
 	<-ctx.Done()
-	// ожидаем завершения отправки метрик или принудительно по таймауту
+	// poller отправляет последние метрики и сам ограничивает отправку таймаутом,
+	// но уже начатый запрос может длиться дольше, поэтому завершаемся принудительно по таймауту
 	select {
 	case <-done:
 	case <-time.After(cfg.ShutdownTimeout()):
+		l.Errorf("shutdown timeout exceeded")
 	}
 }
 
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
@@ -15,13 +16,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/k1nky/ypmetrics/internal/auth"
 	"github.com/k1nky/ypmetrics/internal/config"
 	"github.com/k1nky/ypmetrics/internal/crypto"
+	"github.com/k1nky/ypmetrics/internal/filewatch"
 	"github.com/k1nky/ypmetrics/internal/handler"
 	"github.com/k1nky/ypmetrics/internal/handler/middleware"
+	"github.com/k1nky/ypmetrics/internal/keyring"
 	"github.com/k1nky/ypmetrics/internal/logger"
 	"github.com/k1nky/ypmetrics/internal/retrier"
 	"github.com/k1nky/ypmetrics/internal/storage"
+	"github.com/k1nky/ypmetrics/internal/tlsconfig"
 	"github.com/k1nky/ypmetrics/internal/usecases/keeper"
 )
 
@@ -29,6 +34,13 @@ const (
 	DefaultProfilerPrefix = "/debug/pprof"
 )
 
+const (
+	// количество неудачных запросов к хранилищу подряд, после которого запросы приостанавливаются
+	DefaultRetryBreakerThreshold = 5
+	// время, на которое приостанавливаются запросы к хранилищу
+	DefaultRetryBreakerCooldown = 10 * time.Second
+)
+
 const (
 	DefaultReadTimeout  = 10 * time.Second
 	DefaultWriteTimeout = 10 * time.Second
@@ -68,8 +80,8 @@ func main() {
 	run(ctx, l, cfg)
 }
 
-func exposeProfiler(r *gin.Engine) {
-	g := r.Group(DefaultProfilerPrefix)
+func exposeProfiler(r *gin.Engine, handlers ...gin.HandlerFunc) {
+	g := r.Group(DefaultProfilerPrefix, handlers...)
 	g.GET("/", gin.WrapF(pprof.Index))
 	g.GET("/cmdline", gin.WrapF(pprof.Cmdline))
 	g.GET("/profile", gin.WrapF(pprof.Profile))
@@ -88,35 +100,105 @@ func exit(rc int) {
 	os.Exit(rc)
 }
 
-func newRouter(h handler.Handler, l *logger.Logger, sealKey string, decryptKey *rsa.PrivateKey) *gin.Engine {
+// routerOptions параметры обработки запросов.
+type routerOptions struct {
+	// набор ключей для проверки подписи, nil - подпись не проверяется
+	sealKeys *keyring.Keyring
+	// отклонять неподписанные запросы на изменение метрик
+	strictAuth bool
+	// ключ для расшифровки тела запроса, nil - тело не зашифровано
+	decryptKey *rsa.PrivateKey
+	// API токены, nil - токены не проверяются
+	tokens *auth.Tokens
+	// определять агента по клиентскому сертификату
+	clientCertIdentity bool
+	// фильтр адресов для маршрутов изменения метрик, nil - адреса не проверяются
+	subnets *middleware.SubnetFilter
+	// допустимое количество запросов в секунду от одного агента, 0 - без ограничений
+	rateLimit float64
+	// допустимое кратковременное превышение количества запросов
+	rateBurst int
+	// максимальный размер тела запроса, 0 - без ограничений
+	maxBodySize int64
+	// максимальный размер разжатого тела запроса, 0 - без ограничений
+	maxDecompressedBodySize int64
+}
+
+// scopeMiddlewares возвращает middleware для проверки доступа к области scope.
+func (opts routerOptions) scopeMiddlewares(scope auth.Scope) []gin.HandlerFunc {
+	if opts.tokens == nil {
+		return []gin.HandlerFunc{}
+	}
+	return []gin.HandlerFunc{middleware.NewTokenAuth(opts.tokens).Require(scope)}
+}
+
+func newRouter(h handler.Handler, l *logger.Logger, opts routerOptions) *gin.Engine {
 	router := gin.New()
 	// логируем запрос
 	router.Use(middleware.Logger(l))
-	if len(sealKey) > 0 {
-		// если указан ключ, то проверяем подпись полученных данных
-		router.Use(middleware.NewSeal(sealKey).Use())
+	if opts.clientCertIdentity {
+		// агент определяется по CN клиентского сертификата
+		router.Use(middleware.ClientCertIdentity())
+	}
+	if opts.rateLimit > 0 {
+		// ограничиваем частоту запросов до обработки тела запроса
+		router.Use(middleware.NewRateLimit(opts.rateLimit, opts.rateBurst).Use())
 	}
-	if decryptKey != nil {
+	// middleware, которые проверяют доступ к маршрутам изменения метрик
+	writeMiddlewares := []gin.HandlerFunc{}
+	if opts.subnets != nil {
+		// изменять метрики можно только с доверенных адресов
+		writeMiddlewares = append(writeMiddlewares, opts.subnets.Use())
+	}
+	writeMiddlewares = append(writeMiddlewares, opts.scopeMiddlewares(auth.ScopeWrite)...)
+	// middleware, которые читают и преобразуют тело запроса. Выполняются после проверки доступа,
+	// чтобы сервер не читал, не расшифровывал и не распаковывал тело запроса, который будет отклонен.
+	bodyMiddlewares := []gin.HandlerFunc{}
+	if opts.maxBodySize > 0 {
+		bodyMiddlewares = append(bodyMiddlewares, middleware.NewBodyLimit(opts.maxBodySize).Use())
+	}
+	if opts.sealKeys != nil {
+		// если указаны ключи, то проверяем подпись полученных данных
+		seal := middleware.NewKeyringSeal(opts.sealKeys)
+		bodyMiddlewares = append(bodyMiddlewares, seal.Use())
+		if opts.strictAuth {
+			// в строгом режиме изменять метрики можно только подписанными запросами
+			writeMiddlewares = append(writeMiddlewares, seal.Require())
+		}
+	}
+	if opts.decryptKey != nil {
 		// указан ключ шифрования, то расшифровываем тело запроса
-		router.Use(middleware.NewDecrypter(decryptKey).Use())
+		bodyMiddlewares = append(bodyMiddlewares, middleware.NewDecrypter(opts.decryptKey).Use())
 	}
 	// при необходимости раcпаковываем/запаковываем данные
-	router.Use(middleware.NewGzip([]string{"application/json", "text/html"}).Use())
+	bodyMiddlewares = append(bodyMiddlewares, middleware.NewGzip([]string{"application/json", "text/html"}).SetMaxSize(opts.maxDecompressedBodySize).Use())
 
-	router.GET("/", h.AllMetrics())
-	router.GET("/ping", h.Ping())
-	router.POST("/updates/", middleware.RequireContentType("application/json"), h.UpdatesJSON())
-
-	valueRoutes := router.Group("/value")
+	// чтение метрик
+	readRoutes := router.Group("/", append(opts.scopeMiddlewares(auth.ScopeRead), bodyMiddlewares...)...)
+	readRoutes.GET("/", h.AllMetrics())
+	readRoutes.GET("/ping", h.Ping())
+	readRoutes.GET("/values/", h.AllMetricsJSON())
+	valueRoutes := readRoutes.Group("/value")
 	valueRoutes.POST("/", middleware.RequireContentType("application/json"), h.ValueJSON())
 	valueRoutes.GET("/:type/:name", h.Value())
 
-	updateRoutes := router.Group("/update")
+	// изменение метрик
+	writeRoutes := router.Group("/", append(writeMiddlewares, bodyMiddlewares...)...)
+	writeRoutes.POST("/updates/", middleware.RequireContentType("application/json"), h.UpdatesJSON())
+	updateRoutes := writeRoutes.Group("/update")
 	updateRoutes.POST("/", middleware.RequireContentType("application/json"), h.UpdateJSON())
 	updateRoutes.POST("/:type/", func(c *gin.Context) {
 		c.Status(http.StatusNotFound)
 	})
-	updateRoutes.POST("/:type/:name/:value", h.Update())
+	if opts.strictAuth {
+		// у запроса без тела подпись всегда одинаковая и не защищает параметры в пути,
+		// поэтому в строгом режиме метрики изменяются только запросами с JSON
+		updateRoutes.POST("/:type/:name/:value", func(c *gin.Context) {
+			c.Status(http.StatusForbidden)
+		})
+	} else {
+		updateRoutes.POST("/:type/:name/:value", h.Update())
+	}
 
 	return router
 }
@@ -143,7 +225,9 @@ func run(ctx context.Context, l *logger.Logger, cfg config.Keeper) {
 		StoreInterval: cfg.StorageInterval(),
 		Restore:       cfg.Restore,
 	}
-	store := storage.NewStorage(storeConfig, l, retrier.New())
+	// при недоступности хранилища сразу возвращаем ошибку, а не ждем повторов в каждом запросе
+	storeRetrier := retrier.New().SetBreaker(retrier.NewBreaker(DefaultRetryBreakerThreshold, DefaultRetryBreakerCooldown))
+	store := storage.NewStorage(storeConfig, l, storeRetrier)
 	if err := store.Open(storeConfig); err != nil {
 		l.Errorf("opening storage: %v", err)
 	}
@@ -158,27 +242,71 @@ func run(ctx context.Context, l *logger.Logger, cfg config.Keeper) {
 		exit(1)
 	}
 
-	router := newRouter(h, l, cfg.Key, decryptKey)
+	sealKeys, err := newKeyring(ctx, cfg, l)
+	if err != nil {
+		l.Errorf("config: %s", err)
+		exit(1)
+	}
+
+	tokens, err := newTokens(ctx, cfg, l)
+	if err != nil {
+		l.Errorf("config: %s", err)
+		exit(1)
+	}
+
+	tlsConfig, err := newTLSConfig(ctx, cfg, l)
+	if err != nil {
+		l.Errorf("config: %s", err)
+		exit(1)
+	}
+
+	subnets, err := newSubnetFilter(cfg)
+	if err != nil {
+		l.Errorf("config: %s", err)
+		exit(1)
+	}
+
+	opts := routerOptions{
+		sealKeys:                sealKeys,
+		strictAuth:              cfg.StrictAuth,
+		decryptKey:              decryptKey,
+		tokens:                  tokens,
+		clientCertIdentity:      len(cfg.TLSClientCA) > 0,
+		subnets:                 subnets,
+		rateLimit:               cfg.RateLimit,
+		rateBurst:               int(cfg.RateBurst),
+		maxBodySize:             cfg.MaxBodySize,
+		maxDecompressedBodySize: cfg.MaxDecompressedBodySize,
+	}
+	router := newRouter(h, l, opts)
 	if cfg.EnableProfiling {
 		l.Infof("expose profiler on %s", DefaultProfilerPrefix)
-		exposeProfiler(router)
+		exposeProfiler(router, opts.scopeMiddlewares(auth.ScopeAdmin)...)
 	}
 
 	l.Infof("starting on %s", cfg.Address)
-	runHTTPServer(ctx, cfg.Address.String(), router, l)
+	runHTTPServer(ctx, cfg.Address.String(), router, tlsConfig, l)
 	<-ctx.Done()
 	time.Sleep(1 * time.Second)
 }
 
-func runHTTPServer(ctx context.Context, addr string, handler http.Handler, l *logger.Logger) {
+func runHTTPServer(ctx context.Context, addr string, handler http.Handler, tlsConfig *tls.Config, l *logger.Logger) {
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      handler,
 		WriteTimeout: DefaultWriteTimeout,
 		ReadTimeout:  DefaultReadTimeout,
+		TLSConfig:    tlsConfig,
 	}
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
+		var err error
+		if tlsConfig != nil {
+			// сертификаты заданы в tlsConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil {
 			if !errors.Is(err, http.ErrServerClosed) {
 				l.Errorf("unexpected server closing: %v", err)
 			}
@@ -194,6 +322,84 @@ func runHTTPServer(ctx context.Context, addr string, handler http.Handler, l *lo
 	}()
 }
 
+// newKeyring возвращает набор ключей подписи из конфигурации или nil, если ключи не заданы.
+// В строгом режиме ключи обязательны, иначе изменение метрик было бы доступно без подписи.
+// Файл с набором ключей отслеживается и перечитывается при изменении.
+func newKeyring(ctx context.Context, cfg config.Keeper, l *logger.Logger) (*keyring.Keyring, error) {
+	if len(cfg.Key) == 0 && len(cfg.KeyringPath) == 0 {
+		if cfg.StrictAuth {
+			return nil, errors.New("strict auth requires a signing key or keyring")
+		}
+		return nil, nil
+	}
+	keys := keyring.New()
+	keys.SetDefault(cfg.Key)
+	if len(cfg.KeyringPath) == 0 {
+		return keys, nil
+	}
+	if err := keys.LoadFile(cfg.KeyringPath); err != nil {
+		return nil, err
+	}
+	filewatch.Watch(ctx, cfg.KeyringPath, filewatch.DefaultInterval, func() error {
+		return keys.LoadFile(cfg.KeyringPath)
+	}, l)
+	return keys, nil
+}
+
+// newTokens возвращает набор API токенов из конфигурации или nil, если файл с токенами не задан.
+// Файл с токенами отслеживается и перечитывается при изменении, поэтому отзыв токена
+// вступает в силу без перезапуска сервера.
+func newTokens(ctx context.Context, cfg config.Keeper, l *logger.Logger) (*auth.Tokens, error) {
+	if len(cfg.TokensPath) == 0 {
+		return nil, nil
+	}
+	tokens := auth.New()
+	if err := tokens.LoadFile(cfg.TokensPath); err != nil {
+		return nil, err
+	}
+	filewatch.Watch(ctx, cfg.TokensPath, filewatch.DefaultInterval, func() error {
+		return tokens.LoadFile(cfg.TokensPath)
+	}, l)
+	return tokens, nil
+}
+
+// newSubnetFilter возвращает фильтр адресов клиентов или nil, если подсети не указаны.
+func newSubnetFilter(cfg config.Keeper) (*middleware.SubnetFilter, error) {
+	if len(cfg.TrustedSubnets) == 0 && len(cfg.DeniedSubnets) == 0 {
+		return nil, nil
+	}
+	return middleware.NewSubnetFilter(cfg.TrustedSubnets, cfg.DeniedSubnets, cfg.TrustedProxies)
+}
+
+// newTLSConfig возвращает настройки TLS сервера или nil, если сервер должен работать по HTTP.
+// Неполные настройки TLS считаются ошибкой, чтобы сервер не работал по HTTP вопреки ожиданиям.
+// Файлы сертификатов отслеживаются и перечитываются при изменении.
+func newTLSConfig(ctx context.Context, cfg config.Keeper, l *logger.Logger) (*tls.Config, error) {
+	if !cfg.IsTLSEnabled() {
+		if len(cfg.TLSCert) > 0 || len(cfg.TLSKey) > 0 {
+			return nil, errors.New("both TLS certificate and key are required")
+		}
+		if len(cfg.TLSClientCA) > 0 {
+			return nil, errors.New("TLS client CA requires TLS certificate and key")
+		}
+		return nil, nil
+	}
+	kp, err := tlsconfig.NewKeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	kp.Watch(ctx, filewatch.DefaultInterval, l)
+	if len(cfg.TLSClientCA) == 0 {
+		return tlsconfig.NewServerConfig(kp, nil), nil
+	}
+	clientCAs, err := tlsconfig.NewCAPool(cfg.TLSClientCA)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs.Watch(ctx, filewatch.DefaultInterval, l)
+	return tlsconfig.NewServerConfig(kp, clientCAs), nil
+}
+
 func readCryptoKey(path string) (*rsa.PrivateKey, error) {
 	if len(path) == 0 {
 		return nil, nil
@@ -0,0 +1,140 @@
+// Утилита tokens управляет API токенами сервера сбора метрик.
+//
+//	tokens mint --file tokens.json --id agent-1 --scope write [--ttl 720h]
+//	tokens revoke --file tokens.json --id agent-1
+//	tokens list --file tokens.json
+//
+// Значение выпущенного токена выводится один раз, в файле хранится только его хеш.
+// Сервер перечитывает файл с токенами при изменении, поэтому перезапуск не требуется.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/k1nky/ypmetrics/internal/auth"
+)
+
+const (
+	DefaultTokensPath = "tokens.json"
+)
+
+const usage = `Usage: tokens <command> [options]
+
+Commands:
+  mint     выпустить новый токен
+  revoke   отозвать токен
+  list     вывести список токенов
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+}
+
+func exit(rc int) {
+	os.Exit(rc)
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	path := cmd.StringP("file", "f", DefaultTokensPath, "путь до файла с токенами")
+	switch args[0] {
+	case "mint":
+		id := cmd.StringP("id", "i", "", "идентификатор токена")
+		scopes := cmd.StringSliceP("scope", "s", []string{}, "области доступа: read, write, admin")
+		ttl := cmd.DurationP("ttl", "t", 0, "срок действия токена, 0 - бессрочный")
+		if err := cmd.Parse(args[1:]); err != nil {
+			return err
+		}
+		return mint(*path, *id, *scopes, *ttl, out)
+	case "revoke":
+		id := cmd.StringP("id", "i", "", "идентификатор токена")
+		if err := cmd.Parse(args[1:]); err != nil {
+			return err
+		}
+		return revoke(*path, *id)
+	case "list":
+		if err := cmd.Parse(args[1:]); err != nil {
+			return err
+		}
+		return list(*path, out)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// load загружает токены из файла path. Отсутствующий файл считается пустым набором токенов.
+func load(path string) (*auth.Tokens, error) {
+	tokens := auth.New()
+	if err := tokens.LoadFile(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func mint(path string, id string, scopes []string, ttl time.Duration, out io.Writer) error {
+	tokens, err := load(path)
+	if err != nil {
+		return err
+	}
+	s := make([]auth.Scope, 0, len(scopes))
+	for _, v := range scopes {
+		s = append(s, auth.Scope(strings.TrimSpace(v)))
+	}
+	value, _, err := tokens.Mint(id, s, ttl)
+	if err != nil {
+		return err
+	}
+	if err := tokens.SaveFile(path); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, value)
+	return nil
+}
+
+func revoke(path string, id string) error {
+	tokens, err := load(path)
+	if err != nil {
+		return err
+	}
+	if err := tokens.Revoke(id); err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	return tokens.SaveFile(path)
+}
+
+func list(path string, out io.Writer) error {
+	tokens, err := load(path)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens.List() {
+		expires := "never"
+		if !t.ExpiresAt.IsZero() {
+			expires = t.ExpiresAt.Format(time.RFC3339)
+		}
+		scopes := make([]string, 0, len(t.Scopes))
+		for _, s := range t.Scopes {
+			scopes = append(scopes, string(s))
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\n", t.ID, strings.Join(scopes, ","), expires)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/auth"
+)
+
+func TestMintAndList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	out := &bytes.Buffer{}
+
+	err := run([]string{"mint", "--file", path, "--id", "agent-1", "--scope", "write,read"}, out)
+	if !assert.NoError(t, err) {
+		return
+	}
+	value := strings.TrimSpace(out.String())
+	assert.Len(t, value, 64)
+	out.Reset()
+	assert.NoError(t, run([]string{"mint", "--file", path, "--id", "admin", "--scope", "admin", "--ttl", "1h"}, out))
+
+	// в файле хранится хеш, по которому находится выпущенный токен
+	tokens := auth.New()
+	assert.NoError(t, tokens.LoadFile(path))
+	token, err := tokens.Lookup(value, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "agent-1", token.ID)
+
+	out.Reset()
+	assert.NoError(t, run([]string{"list", "--file", path}, out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Regexp(t, `^admin\tadmin\t\d{4}-\d{2}-\d{2}T`, lines[0])
+		assert.Equal(t, "agent-1\twrite,read\tnever", lines[1])
+	}
+
+	// идентификатор должен быть уникальным
+	assert.ErrorIs(t, run([]string{"mint", "--file", path, "--id", "agent-1", "--scope", "read"}, out), auth.ErrInvalidToken)
+}
+
+func TestMintScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+	}{
+		{name: "Unknown scope", scopes: []string{"--scope", "superuser"}},
+		{name: "Unknown scope in list", scopes: []string{"--scope", "read,delete"}},
+		{name: "Without scopes", scopes: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.json")
+			out := &bytes.Buffer{}
+			err := run(append([]string{"mint", "--file", path, "--id", "agent-1"}, tt.scopes...), out)
+			assert.ErrorIs(t, err, auth.ErrInvalidToken)
+			assert.Empty(t, out.String())
+			// токен не сохранен
+			assert.NoError(t, run([]string{"list", "--file", path}, out))
+			assert.Empty(t, out.String())
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	out := &bytes.Buffer{}
+	assert.NoError(t, run([]string{"mint", "--file", path, "--id", "agent-1", "--scope", "write"}, out))
+	assert.NoError(t, run([]string{"mint", "--file", path, "--id", "agent-2", "--scope", "write"}, out))
+
+	assert.ErrorIs(t, run([]string{"revoke", "--file", path, "--id", "unknown"}, out), auth.ErrTokenNotFound)
+
+	assert.NoError(t, run([]string{"revoke", "--file", path, "--id", "agent-1"}, out))
+	out.Reset()
+	assert.NoError(t, run([]string{"list", "--file", path}, out))
+	assert.Equal(t, "agent-2\twrite\tnever\n", out.String())
+
+	// повторный отзыв
+	assert.ErrorIs(t, run([]string{"revoke", "--file", path, "--id", "agent-1"}, out), auth.ErrTokenNotFound)
+}
+
+func TestRunInvalidCommand(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.Error(t, run([]string{}, out))
+	assert.Error(t, run([]string{"rotate"}, out))
+	assert.Error(t, run([]string{"mint", "--unknown"}, out))
+}
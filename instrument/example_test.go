@@ -0,0 +1,25 @@
+package instrument_test
+
+import (
+	"time"
+
+	"github.com/k1nky/ypmetrics/instrument"
+)
+
+func Example() {
+	r, err := instrument.New(instrument.Options{
+		Address:  "localhost:8080",
+		Interval: 10 * time.Second,
+		Key:      "secret",
+	})
+	if err != nil {
+		return
+	}
+	// при закрытии накопленные значения будут отправлены на сервер
+	defer r.Close()
+
+	orders := r.Counter("orders")
+	orders.Inc()
+	orders.Add(2)
+	r.Gauge("queue_size").Set(10)
+}
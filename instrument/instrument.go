@@ -0,0 +1,290 @@
+// Пакет instrument позволяет приложениям отправлять собственные метрики на сервер сбора метрик
+// без запуска агента.
+//
+// Метрики регистрируются в реестре (Registry) и накапливаются в памяти процесса. Реестр периодически
+// отправляет накопленные значения на сервер: для счетчиков отправляется прирост с момента последней
+// успешной отправки, для измерителей - последнее установленное значение. При закрытии реестра
+// выполняется последняя отправка.
+//
+//	r, err := instrument.New(instrument.Options{Address: "localhost:8080"})
+//	if err != nil {
+//		return err
+//	}
+//	defer r.Close()
+//	r.Counter("orders").Inc()
+//	r.Gauge("queue_size").Set(10)
+package instrument
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/k1nky/ypmetrics/internal/apiclient"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/logger"
+)
+
+// Значения по умолчанию.
+const (
+	// DefaultInterval интервал отправки метрик.
+	DefaultInterval = 10 * time.Second
+	// DefaultCloseTimeout таймаут последней отправки при закрытии реестра.
+	DefaultCloseTimeout = 5 * time.Second
+)
+
+var (
+	// ErrClosed реестр уже закрыт.
+	ErrClosed = errors.New("registry is closed")
+	// ErrNoAddress не указан адрес сервера.
+	ErrNoAddress = errors.New("server address is not specified")
+)
+
+// Logger логгер реестра.
+type Logger interface {
+	Errorf(string, ...interface{})
+	Debugf(string, ...interface{})
+	Warnf(string, ...interface{})
+}
+
+// Options параметры реестра.
+type Options struct {
+	// Address адрес сервера в формате [<протокол>://]<хост>[:порт].
+	Address string
+	// Interval интервал отправки метрик. По умолчанию DefaultInterval.
+	Interval time.Duration
+	// CloseTimeout таймаут последней отправки при закрытии реестра. По умолчанию DefaultCloseTimeout.
+	CloseTimeout time.Duration
+	// Key ключ подписи отправляемых данных.
+	Key string
+	// KeyID идентификатор ключа подписи.
+	KeyID string
+	// CryptoKey открытый ключ для шифрования отправляемых данных.
+	CryptoKey *rsa.PublicKey
+	// Token API токен для доступа к серверу.
+	Token string
+	// TLS настройки TLS. Если указаны, то метрики отправляются по HTTPS.
+	TLS *tls.Config
+	// DisableGzip отключает сжатие отправляемых данных.
+	DisableGzip bool
+	// Logger логгер. По умолчанию сообщения не выводятся.
+	Logger Logger
+}
+
+// Registry реестр метрик приложения.
+type Registry struct {
+	client       *apiclient.Client
+	logger       Logger
+	closeTimeout time.Duration
+
+	mu       sync.Mutex
+	counters map[string]*Counter
+	gauges   map[string]*Gauge
+	// отправки выполняются последовательно
+	pushMu sync.Mutex
+
+	stop   context.CancelFunc
+	done   chan struct{}
+	closed atomic.Bool
+}
+
+// New возвращает новый реестр и запускает периодическую отправку метрик.
+func New(opts Options) (*Registry, error) {
+	if len(opts.Address) == 0 {
+		return nil, ErrNoAddress
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.CloseTimeout <= 0 {
+		opts.CloseTimeout = DefaultCloseTimeout
+	}
+	var l Logger = &logger.Blackhole{}
+	if opts.Logger != nil {
+		l = opts.Logger
+	}
+	client := apiclient.New(opts.Address, l)
+	client.SetTLS(opts.TLS)
+	if !opts.DisableGzip {
+		client.SetGzip()
+	}
+	// сжимаем данные -> шифруем -> подписываем
+	client.SetEncrypt(opts.CryptoKey).SetKeyWithID(opts.KeyID, opts.Key).SetToken(opts.Token)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Registry{
+		client:       client,
+		logger:       l,
+		closeTimeout: opts.CloseTimeout,
+		counters:     make(map[string]*Counter),
+		gauges:       make(map[string]*Gauge),
+		stop:         cancel,
+		done:         make(chan struct{}),
+	}
+	go r.run(ctx, opts.Interval)
+	return r, nil
+}
+
+// Counter возвращает счетчик с именем name. Для одного имени всегда возвращается один и тот же счетчик.
+func (r *Registry) Counter(name string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.counters[name]
+	if !ok {
+		c = &Counter{name: name}
+		r.counters[name] = c
+	}
+	return c
+}
+
+// Gauge возвращает измеритель с именем name. Для одного имени всегда возвращается один и тот же измеритель.
+func (r *Registry) Gauge(name string) *Gauge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, ok := r.gauges[name]
+	if !ok {
+		g = &Gauge{name: name}
+		r.gauges[name] = g
+	}
+	return g
+}
+
+// Flush отправляет накопленные значения метрик на сервер. Если отправить не удалось,
+// то значения будут отправлены в следующий раз.
+func (r *Registry) Flush(ctx context.Context) error {
+	r.pushMu.Lock()
+	defer r.pushMu.Unlock()
+
+	metrics, commit := r.collect()
+	if len(metrics.Counters)+len(metrics.Gauges) == 0 {
+		return nil
+	}
+	err := r.client.PushMetricsContext(ctx, metrics)
+	commit(err == nil)
+	return err
+}
+
+// Close останавливает периодическую отправку и отправляет накопленные значения последний раз.
+// На отправку отводится Options.CloseTimeout.
+func (r *Registry) Close() error {
+	if !r.closed.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	r.stop()
+	<-r.done
+	ctx, cancel := context.WithTimeout(context.Background(), r.closeTimeout)
+	defer cancel()
+	return r.Flush(ctx)
+}
+
+func (r *Registry) run(ctx context.Context, interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Errorf("instrument: push metrics: %v", err)
+			}
+		}
+	}
+}
+
+// collect возвращает метрики для отправки и функцию, которую нужно вызвать с результатом отправки.
+// Прирост счетчиков при неудачной отправке возвращается обратно, измерители снова помечаются измененными.
+func (r *Registry) collect() (metric.Metrics, func(ok bool)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metrics := metric.Metrics{}
+	counters := make([]*Counter, 0)
+	gauges := make([]*Gauge, 0)
+	for _, c := range r.counters {
+		if delta := c.delta.Swap(0); delta != 0 {
+			metrics.Counters = append(metrics.Counters, metric.NewCounter(c.name, delta))
+			counters = append(counters, c)
+		}
+	}
+	for _, g := range r.gauges {
+		if g.dirty.Swap(false) {
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge(g.name, g.Value()))
+			gauges = append(gauges, g)
+		}
+	}
+	return metrics, func(ok bool) {
+		if ok {
+			return
+		}
+		for i, c := range counters {
+			c.delta.Add(metrics.Counters[i].Value)
+		}
+		for _, g := range gauges {
+			g.dirty.Store(true)
+		}
+	}
+}
+
+// Counter счетчик. На сервер отправляется прирост счетчика с момента последней отправки.
+type Counter struct {
+	name  string
+	delta atomic.Int64
+}
+
+// Name возвращает имя счетчика.
+func (c *Counter) Name() string {
+	return c.name
+}
+
+// Inc увеличивает счетчик на 1.
+func (c *Counter) Inc() {
+	c.delta.Add(1)
+}
+
+// Add увеличивает счетчик на delta.
+func (c *Counter) Add(delta int64) {
+	c.delta.Add(delta)
+}
+
+// Gauge измеритель. На сервер отправляется последнее значение, если оно изменилось с момента последней отправки.
+type Gauge struct {
+	name  string
+	bits  atomic.Uint64
+	dirty atomic.Bool
+}
+
+// Name возвращает имя измерителя.
+func (g *Gauge) Name() string {
+	return g.name
+}
+
+// Set устанавливает значение измерителя.
+func (g *Gauge) Set(value float64) {
+	g.bits.Store(math.Float64bits(value))
+	g.dirty.Store(true)
+}
+
+// Add изменяет значение измерителя на delta.
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			break
+		}
+	}
+	g.dirty.Store(true)
+}
+
+// Value возвращает текущее значение измерителя.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
@@ -0,0 +1,105 @@
+package instrument
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/handler"
+	"github.com/k1nky/ypmetrics/internal/handler/middleware"
+	"github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/storage"
+	"github.com/k1nky/ypmetrics/internal/usecases/keeper"
+)
+
+// newTestServer возвращает сервер метрик и хранилище, в которое он сохраняет метрики.
+// Пока fail установлен, сервер отвечает ошибкой.
+func newTestServer(fail *atomic.Bool) (*httptest.Server, *storage.MemStorage) {
+	store := storage.NewMemStorage()
+	h := handler.New(*keeper.New(store, config.Keeper{}, &logger.Blackhole{}))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if fail.Load() {
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+	})
+	router.Use(middleware.NewGzip([]string{"application/json"}).Use())
+	router.POST("/updates/", middleware.RequireContentType("application/json"), h.UpdatesJSON())
+	return httptest.NewServer(router), store
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{})
+	assert.ErrorIs(t, err, ErrNoAddress)
+}
+
+func TestRegistryFlush(t *testing.T) {
+	fail := &atomic.Bool{}
+	srv, store := newTestServer(fail)
+	defer srv.Close()
+	ctx := context.Background()
+
+	r, err := New(Options{Address: srv.URL, Interval: time.Hour})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+
+	c := r.Counter("requests")
+	assert.Same(t, c, r.Counter("requests"))
+	c.Inc()
+	c.Add(4)
+	r.Gauge("temperature").Set(36.6)
+	r.Gauge("queue").Add(2)
+	r.Gauge("queue").Add(1)
+	assert.NoError(t, r.Flush(ctx))
+	assert.Equal(t, int64(5), store.GetCounter(ctx, "requests").Value)
+	assert.Equal(t, 36.6, store.GetGauge(ctx, "temperature").Value)
+	assert.Equal(t, 3.0, store.GetGauge(ctx, "queue").Value)
+
+	// на сервер отправляется только прирост счетчика
+	c.Inc()
+	assert.NoError(t, r.Flush(ctx))
+	assert.Equal(t, int64(6), store.GetCounter(ctx, "requests").Value)
+
+	// неотправленный прирост отправляется в следующий раз
+	fail.Store(true)
+	c.Add(10)
+	r.Gauge("temperature").Set(37)
+	assert.Error(t, r.Flush(ctx))
+	fail.Store(false)
+	c.Inc()
+	assert.NoError(t, r.Flush(ctx))
+	assert.Equal(t, int64(17), store.GetCounter(ctx, "requests").Value)
+	assert.Equal(t, 37.0, store.GetGauge(ctx, "temperature").Value)
+}
+
+func TestRegistryPeriodicPushAndClose(t *testing.T) {
+	srv, store := newTestServer(&atomic.Bool{})
+	defer srv.Close()
+	ctx := context.Background()
+
+	r, err := New(Options{Address: srv.URL, Interval: 50 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		return
+	}
+	r.Counter("c").Inc()
+	assert.Eventually(t, func() bool {
+		c := store.GetCounter(ctx, "c")
+		return c != nil && c.Value == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// при закрытии отправляются последние значения
+	r.Counter("c").Add(2)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, int64(3), store.GetCounter(ctx, "c").Value)
+	assert.ErrorIs(t, r.Close(), ErrClosed)
+}
@@ -2,9 +2,13 @@
 package apiclient
 
 import (
+	"context"
 	"crypto/rsa"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -21,6 +25,8 @@ import (
 const (
 	// Таймаут запроса по умолчанию.
 	DefaultRequestTimeout = 5 * time.Second
+	// RealIPHeader заголовок с IP адресом агента.
+	RealIPHeader = "X-Real-IP"
 )
 
 // Типы метрик.
@@ -32,8 +38,29 @@ const (
 var (
 	// ErrUnexpectedResponse сервер вернул неожиданный ответ на запрос.
 	ErrUnexpectedResponse = errors.New("unexpected response")
+	// ErrMetricNotFound запрашиваемой метрики нет на сервере.
+	ErrMetricNotFound = errors.New("metric not found")
 )
 
+// NotFoundError запрашиваемой метрики нет на сервере.
+// Проверить ошибку можно как с помощью errors.As, так и errors.Is(err, ErrMetricNotFound).
+type NotFoundError struct {
+	// Type тип метрики.
+	Type string
+	// Name имя метрики.
+	Name string
+}
+
+// Error возвращает текст ошибки.
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Type, e.Name, ErrMetricNotFound)
+}
+
+// Unwrap возвращает ErrMetricNotFound.
+func (e *NotFoundError) Unwrap() error {
+	return ErrMetricNotFound
+}
+
 type clientLogger interface {
 	Errorf(string, ...interface{})
 	Debugf(string, ...interface{})
@@ -46,6 +73,7 @@ type Client struct {
 	EndpointURL string
 	httpclient  *resty.Client
 	middlewares []resty.PreRequestHook
+	retrier     *retrier.Retrier
 }
 
 // New возвращает нового клиента для сервера сбора метрик.
@@ -66,6 +94,7 @@ func New(url string, l clientLogger) *Client {
 		httpclient:  resty.New().SetTimeout(DefaultRequestTimeout).SetLogger(l),
 		// по умолчанию используем сжатие запросов
 		middlewares: []resty.PreRequestHook{},
+		retrier:     retrier.New(),
 	}
 
 	//	В качестве middleware в resty предлагается использовать RequestMiddleware с методом OnBeforeRequest.
@@ -82,19 +111,30 @@ func New(url string, l clientLogger) *Client {
 // PushMetric отправляет метрику типа typ с именем name и значением value на сервер.
 // Метод вернет ошибку, если отправить не удалось или сервер не принял данную метрику.
 func (c *Client) PushMetric(typ, name, value string) error {
+	return c.PushMetricContext(context.Background(), typ, name, value)
+}
 
+// PushMetricContext аналогичен PushMetric, но отправка, включая повторные попытки,
+// прерывается при завершении контекста ctx.
+func (c *Client) PushMetricContext(ctx context.Context, typ, name, value string) error {
 	path, err := url.JoinPath("update/", typ, name, value)
 	if err != nil {
 		return err
 	}
-	return c.postData(path, "text/plain", nil)
+	return c.postData(ctx, path, "text/plain", nil)
 }
 
 // PushCounter отправляет счетчик с именем name и значением value на сервер.
 // Данные отправляются в формате JSON.
 // Метод вернет ошибку, если отправить не удалось или сервер не принял данную метрику.
 func (c *Client) PushCounter(name string, value int64) error {
-	return c.postData("update/", "application/json", protocol.Metrics{
+	return c.PushCounterContext(context.Background(), name, value)
+}
+
+// PushCounterContext аналогичен PushCounter, но отправка, включая повторные попытки,
+// прерывается при завершении контекста ctx.
+func (c *Client) PushCounterContext(ctx context.Context, name string, value int64) error {
+	return c.postData(ctx, "update/", "application/json", protocol.Metrics{
 		ID:    name,
 		MType: CounterType,
 		Delta: &value,
@@ -105,7 +145,13 @@ func (c *Client) PushCounter(name string, value int64) error {
 // Данные отправляются в формате JSON.
 // Метод вернет ошибку, если отправить не удалось или сервер не принял данную метрику.
 func (c *Client) PushGauge(name string, value float64) (err error) {
-	return c.postData("update/", "application/json", protocol.Metrics{
+	return c.PushGaugeContext(context.Background(), name, value)
+}
+
+// PushGaugeContext аналогичен PushGauge, но отправка, включая повторные попытки,
+// прерывается при завершении контекста ctx.
+func (c *Client) PushGaugeContext(ctx context.Context, name string, value float64) error {
+	return c.postData(ctx, "update/", "application/json", protocol.Metrics{
 		ID:    name,
 		MType: GaugeType,
 		Value: &value,
@@ -116,6 +162,12 @@ func (c *Client) PushGauge(name string, value float64) (err error) {
 // Данные отправляются в формате JSON.
 // Метод вернет ошибку, если отправить не удалось или сервер не принял данную метрику.
 func (c *Client) PushMetrics(metrics metric.Metrics) (err error) {
+	return c.PushMetricsContext(context.Background(), metrics)
+}
+
+// PushMetricsContext аналогичен PushMetrics, но отправка, включая повторные попытки,
+// прерывается при завершении контекста ctx.
+func (c *Client) PushMetricsContext(ctx context.Context, metrics metric.Metrics) error {
 	metricsCount := len(metrics.Counters) + len(metrics.Gauges)
 	if metricsCount == 0 {
 		return nil
@@ -127,7 +179,78 @@ func (c *Client) PushMetrics(metrics metric.Metrics) (err error) {
 	for _, g := range metrics.Gauges {
 		m = append(m, protocol.Metrics{ID: g.Name, MType: GaugeType, Value: &g.Value})
 	}
-	return c.postData("updates/", "application/json", m)
+	return c.postData(ctx, "updates/", "application/json", m)
+}
+
+// GetCounter возвращает значение счетчика с именем name с сервера.
+// Если счетчика на сервере нет, то возвращается ошибка *NotFoundError.
+func (c *Client) GetCounter(ctx context.Context, name string) (*metric.Counter, error) {
+	m, err := c.getValue(ctx, CounterType, name)
+	if err != nil {
+		return nil, err
+	}
+	if m.Delta == nil {
+		return nil, fmt.Errorf("counter %s without value: %w", name, ErrUnexpectedResponse)
+	}
+	return metric.NewCounter(m.ID, *m.Delta), nil
+}
+
+// GetGauge возвращает значение измерителя с именем name с сервера.
+// Если измерителя на сервере нет, то возвращается ошибка *NotFoundError.
+func (c *Client) GetGauge(ctx context.Context, name string) (*metric.Gauge, error) {
+	m, err := c.getValue(ctx, GaugeType, name)
+	if err != nil {
+		return nil, err
+	}
+	if m.Value == nil {
+		return nil, fmt.Errorf("gauge %s without value: %w", name, ErrUnexpectedResponse)
+	}
+	return metric.NewGauge(m.ID, *m.Value), nil
+}
+
+// ListMetrics возвращает все метрики с сервера.
+func (c *Client) ListMetrics(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.Metrics{}
+	resp, err := c.do(ctx, http.MethodGet, "values/", "", nil)
+	if err != nil {
+		return metrics, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return metrics, fmt.Errorf("status %d %s: %w", resp.StatusCode(), resp.String(), ErrUnexpectedResponse)
+	}
+	values := make([]protocol.Metrics, 0)
+	if err := json.Unmarshal(resp.Body(), &values); err != nil {
+		return metrics, fmt.Errorf("%v: %w", err, ErrUnexpectedResponse)
+	}
+	for _, m := range values {
+		switch {
+		case m.MType == CounterType && m.Delta != nil:
+			metrics.Counters = append(metrics.Counters, metric.NewCounter(m.ID, *m.Delta))
+		case m.MType == GaugeType && m.Value != nil:
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge(m.ID, *m.Value))
+		}
+	}
+	return metrics, nil
+}
+
+// Запрашивает значение метрики типа typ с именем name.
+func (c *Client) getValue(ctx context.Context, typ string, name string) (protocol.Metrics, error) {
+	m := protocol.Metrics{ID: name, MType: typ}
+	resp, err := c.do(ctx, http.MethodPost, "value/", "application/json", m)
+	if err != nil {
+		return m, err
+	}
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return m, &NotFoundError{Type: typ, Name: name}
+	default:
+		return m, fmt.Errorf("status %d %s: %w", resp.StatusCode(), resp.String(), ErrUnexpectedResponse)
+	}
+	if err := json.Unmarshal(resp.Body(), &m); err != nil {
+		return m, fmt.Errorf("%v: %w", err, ErrUnexpectedResponse)
+	}
+	return m, nil
 }
 
 // SetEncrypt задает публичный ключ для шифрования отправляемых данных.
@@ -142,12 +265,102 @@ func (c *Client) SetEncrypt(key *rsa.PublicKey) *Client {
 // SetKey задает ключ подписи отправляемых данных, которым будут подписываться отправляемые данные.
 // Формирование подписи осуществляется автоматически для каждого запроса.
 func (c *Client) SetKey(key string) *Client {
+	return c.SetKeyWithID("", key)
+}
+
+// SetKeyWithID задает ключ подписи key с идентификатором id. Идентификатор передается серверу
+// вместе с подписью, чтобы сервер мог выбрать нужный ключ из своего набора ключей.
+// Кроме того, клиент проверяет подпись успешных ответов сервера этим же ключом,
+// чтобы обнаружить подмену сервера.
+func (c *Client) SetKeyWithID(id string, key string) *Client {
 	if len(key) > 0 {
-		c.middlewares = append(c.middlewares, middleware.NewSeal(key).Use())
+		seal := middleware.NewSealWithKeyID(id, key)
+		c.middlewares = append(c.middlewares, seal.Use())
+		c.httpclient.SetTransport(seal.Transport(c.httpclient.GetClient().Transport))
 	}
 	return c
 }
 
+// SetTLS задает настройки TLS и переключает клиента на протокол https.
+func (c *Client) SetTLS(cfg *tls.Config) *Client {
+	if cfg == nil {
+		return c
+	}
+	// транспорт может быть обернут (например, для проверки подписи ответов), поэтому
+	// настройки задаем исходному транспорту
+	rt := c.httpclient.GetClient().Transport
+	for {
+		u, ok := rt.(interface{ Unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		rt = u.Unwrap()
+	}
+	if t, ok := rt.(*http.Transport); ok {
+		t.TLSClientConfig = cfg
+	}
+	endpoint := strings.TrimPrefix(c.EndpointURL, "http://")
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	c.EndpointURL = "https://" + endpoint
+	return c
+}
+
+// SetToken задает API токен, который будет передаваться в заголовке Authorization каждого запроса.
+func (c *Client) SetToken(token string) *Client {
+	if len(token) > 0 {
+		c.httpclient.SetAuthToken(token)
+	}
+	return c
+}
+
+// SetRealIP задает IP адрес агента, который будет передаваться в заголовке X-Real-IP каждого запроса.
+func (c *Client) SetRealIP(ip net.IP) *Client {
+	if ip != nil {
+		c.httpclient.SetHeader(RealIPHeader, ip.String())
+	}
+	return c
+}
+
+// OutboundIP возвращает адрес сетевого интерфейса, через который клиент подключается к серверу.
+// Соединение по UDP не отправляет данных, поэтому сервер не обязан быть доступен.
+func (c *Client) OutboundIP() (net.IP, error) {
+	u, err := url.Parse(c.EndpointURL)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("unexpected local address %s", conn.LocalAddr())
+	}
+	return addr.IP, nil
+}
+
+// SetRetrier задает контроллер повторной отправки запросов. Если у контроллера задан предохранитель,
+// то после серии неудачных отправок клиент будет сразу возвращать ошибку retrier.ErrCircuitOpen.
+func (c *Client) SetRetrier(r *retrier.Retrier) *Client {
+	if r != nil {
+		c.retrier = r
+	}
+	return c
+}
+
+// Retrier возвращает контроллер повторной отправки запросов.
+func (c *Client) Retrier() *retrier.Retrier {
+	return c.retrier
+}
+
 // SetGzip включает сжатие передаваемых данных.
 func (c *Client) SetGzip() *Client {
 	c.middlewares = append(c.middlewares, middleware.NewGzip().Use())
@@ -164,24 +377,15 @@ func (c *Client) callMiddlewares(cli *resty.Client, r *http.Request) error {
 }
 
 // newRequest это shortcut для создания нового запроса.
-func (c *Client) newRequest() *resty.Request {
-	return c.httpclient.R().SetHeader("accept-encoding", "gzip")
+func (c *Client) newRequest(ctx context.Context) *resty.Request {
+	return c.httpclient.R().SetContext(ctx).SetHeader("accept-encoding", "gzip")
 }
 
 // Отправляет POST запрос по пути path с типом контента contentType и телом body.
-func (c *Client) postData(path string, contentType string, body interface{}) (err error) {
-	var (
-		requestURL string
-		resp       *resty.Response
-	)
-	if requestURL, err = url.JoinPath(c.EndpointURL, path); err != nil {
-		return err
-	}
-	// формируем запрос
-	request := c.newRequest().SetHeader("content-type", contentType).SetBody(body)
-	request.Method = http.MethodPost
-	request.URL = requestURL
-	if resp, err = c.send(request); err != nil {
+// Запрос и его повторы прерываются при завершении контекста ctx.
+func (c *Client) postData(ctx context.Context, path string, contentType string, body interface{}) (err error) {
+	var resp *resty.Response
+	if resp, err = c.do(ctx, http.MethodPost, path, contentType, body); err != nil {
 		return err
 	}
 	// код ответа отличный от 200 не будем считать ошибкой отправки данных
@@ -191,14 +395,36 @@ func (c *Client) postData(path string, contentType string, body interface{}) (er
 	return nil
 }
 
+// Выполняет запрос method по пути path с типом контента contentType и телом body.
+// Пустой contentType означает запрос без тела.
+func (c *Client) do(ctx context.Context, method string, path string, contentType string, body interface{}) (*resty.Response, error) {
+	requestURL, err := url.JoinPath(c.EndpointURL, path)
+	if err != nil {
+		return nil, err
+	}
+	// формируем запрос
+	request := c.newRequest(ctx)
+	if len(contentType) > 0 {
+		request.SetHeader("content-type", contentType).SetBody(body)
+	}
+	request.Method = method
+	request.URL = requestURL
+	return c.send(request)
+}
+
 // Отправляет сформированный запрос на сервер. Если при отправке возникнут ошибки,
 // запрос будет отправлен повторно.
 func (c *Client) send(request *resty.Request) (response *resty.Response, err error) {
 
-	r := retrier.New()
-	for r.Init(c.shouldRetry); r.Next(err); {
-		response, err = request.Send()
+	r := c.retrier
+	if r == nil {
+		r = retrier.New()
 	}
+	err = r.Do(request.Context(), c.shouldRetry, func(ctx context.Context) error {
+		var sendErr error
+		response, sendErr = request.Send()
+		return sendErr
+	})
 	return
 }
 
@@ -213,5 +439,9 @@ func (c *Client) shouldRetry(err error) bool {
 			return false
 		}
 	}
+	// ответ с неверной подписью повторять бессмысленно
+	if errors.Is(err, middleware.ErrInvalidSignature) {
+		return false
+	}
 	return true
 }
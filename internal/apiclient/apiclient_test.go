@@ -2,16 +2,24 @@ package apiclient
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/k1nky/ypmetrics/internal/entities/metric"
 	"github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/retrier"
 )
 
 func TestNew(t *testing.T) {
@@ -245,3 +253,149 @@ func TestClientPushMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestClientDetectSpoofedServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverKey  string
+		wantErr    bool
+		wantCalled int
+	}{
+		{name: "Same key", serverKey: "secret", wantErr: false, wantCalled: 1},
+		{name: "Another key", serverKey: "spoofed", wantErr: true, wantCalled: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := 0
+			httpserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				called++
+				h := hmac.New(sha256.New, []byte(tt.serverKey))
+				h.Write([]byte("{}"))
+				rw.Header().Set("HashSHA256", hex.EncodeToString(h.Sum(nil)))
+				rw.WriteHeader(http.StatusOK)
+				rw.Write([]byte("{}"))
+			}))
+			defer httpserver.Close()
+
+			c := New(httpserver.URL, &logger.Blackhole{}).SetKey("secret")
+			err := c.PushCounter("c0", 1)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			// при неверной подписи запрос не повторяется
+			assert.Equal(t, tt.wantCalled, called)
+		})
+	}
+}
+
+func TestClientSetToken(t *testing.T) {
+	httpserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer httpserver.Close()
+
+	c := New(httpserver.URL, &logger.Blackhole{}).SetToken("abc")
+	assert.NoError(t, c.PushCounter("c0", 1))
+}
+
+func TestClientSetTLS(t *testing.T) {
+	httpserver := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		h := hmac.New(sha256.New, []byte("secret"))
+		rw.Header().Set("HashSHA256", hex.EncodeToString(h.Sum(nil)))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer httpserver.Close()
+	host := strings.TrimPrefix(httpserver.URL, "https://")
+
+	tests := []struct {
+		name    string
+		address string
+	}{
+		// схема адреса должна стать https
+		{name: "Without scheme", address: host},
+		{name: "HTTP", address: "http://" + host},
+		{name: "HTTPS", address: "https://" + host},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.address, &logger.Blackhole{})
+			// проверка подписи оборачивает транспорт, настройки TLS все равно должны примениться
+			c.SetKey("secret").SetTLS(httpserver.Client().Transport.(*http.Transport).TLSClientConfig)
+			assert.Equal(t, "https://"+host, c.EndpointURL)
+			assert.NoError(t, c.PushCounter("c0", 1))
+		})
+	}
+}
+
+func TestClientSetRealIP(t *testing.T) {
+	httpserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "127.0.0.1", req.Header.Get("X-Real-IP"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer httpserver.Close()
+
+	c := New(httpserver.URL, &logger.Blackhole{})
+	ip, err := c.OutboundIP()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "127.0.0.1", ip.String())
+	assert.NoError(t, c.SetRealIP(ip).PushCounter("c0", 1))
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	requests := atomic.Int32{}
+	httpserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		// обрываем соединение, чтобы клиент получил ошибку транспорта
+		conn, _, _ := rw.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer httpserver.Close()
+
+	b := retrier.NewBreaker(2, time.Hour)
+	c := New(httpserver.URL, &logger.Blackhole{}).SetRetrier(retrier.NewWithPolicy(retrier.Policy{MaxAttempts: 1}).SetBreaker(b))
+	assert.Error(t, c.PushCounter("c0", 1))
+	assert.Error(t, c.PushCounter("c0", 1))
+	assert.Equal(t, retrier.StateOpen, c.Retrier().Breaker().State())
+	// предохранитель разомкнут, запрос не отправляется
+	assert.ErrorIs(t, c.PushCounter("c0", 1), retrier.ErrCircuitOpen)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestClientPushContext(t *testing.T) {
+	requests := atomic.Int32{}
+	httpserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		conn, _, _ := rw.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer httpserver.Close()
+
+	// повтор через час не должен дождаться своей очереди
+	c := New(httpserver.URL, &logger.Blackhole{}).SetRetrier(retrier.NewWithPolicy(retrier.Policy{InitialInterval: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.PushMetricsContext(ctx, metric.Metrics{Counters: []*metric.Counter{metric.NewCounter("c0", 1)}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// отмененный контекст прерывает сам запрос
+	release := make(chan struct{})
+	slowserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer slowserver.Close()
+	defer close(release)
+	c = New(slowserver.URL, &logger.Blackhole{})
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	assert.ErrorIs(t, c.PushCounterContext(ctx, "c0", 1), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"net/http"
@@ -13,27 +15,53 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
+// Заголовки, используемые для подписи данных.
+const (
+	// HashHeader заголовок с подписью данных.
+	HashHeader = "HashSHA256"
+	// KeyIDHeader заголовок с идентификатором ключа подписи.
+	KeyIDHeader = "Key-ID"
+)
+
+var (
+	// ErrInvalidSignature подпись ответа сервера отсутствует или не совпадает с ожидаемой.
+	ErrInvalidSignature = errors.New("invalid response signature")
+)
+
 // Seal это middleware для подписи тела запроса.
 // Подпись будет проставляться в заголовок HashSHA256.
 type Seal struct {
 	hashers sync.Pool
+	keyID   string
 }
 
 // NewSeal возвращает новую middleware для подписи с ключом secret.
 func NewSeal(secret string) *Seal {
+	return NewSealWithKeyID("", secret)
+}
+
+// NewSealWithKeyID возвращает новую middleware для подписи ключом secret с идентификатором id.
+// Идентификатор ключа передается в заголовке Key-ID, если он не пустой.
+func NewSealWithKeyID(id string, secret string) *Seal {
 	return &Seal{
 		hashers: sync.Pool{
 			New: func() any {
 				return hmac.New(sha256.New, []byte(secret))
 			},
 		},
+		keyID: id,
 	}
 }
 
 // Use добавляет заголовок HashSHA256 с подписью передаваемых данных по алгоритму sha256.
-// Применимо для POST запросов с непустым телом.
+// Если задан идентификатор ключа, то дополнительно добавляется заголовок Key-ID.
+// Подписываются POST запросы с непустым телом, но заголовок Key-ID добавляется ко всем запросам,
+// чтобы сервер подписал ответ нужным ключом.
 func (s *Seal) Use() resty.PreRequestHook {
 	return func(c *resty.Client, r *http.Request) error {
+		if len(s.keyID) > 0 {
+			r.Header.Set(KeyIDHeader, s.keyID)
+		}
 		if !s.shouldSign(r) {
 			return nil
 		}
@@ -49,7 +77,7 @@ func (s *Seal) Use() resty.PreRequestHook {
 		}
 		_ = r.Body.Close()
 		r.Body = io.NopCloser(body)
-		r.Header.Set("HashSHA256", hex.EncodeToString(h.Sum(nil)))
+		r.Header.Set(HashHeader, hex.EncodeToString(h.Sum(nil)))
 
 		return nil
 	}
@@ -62,3 +90,73 @@ func (s *Seal) shouldSign(r *http.Request) bool {
 	}
 	return false
 }
+
+// Transport возвращает http.RoundTripper, который проверяет подпись успешных ответов сервера
+// перед тем, как передать их клиенту. Ответ с отсутствующей или неверной подписью считается
+// подделанным, и в таком случае возвращается ошибка ErrInvalidSignature.
+// Подпись проверяется по телу ответа в том виде, в котором оно было получено (до распаковки).
+// Если next равен nil, то используется http.DefaultTransport.
+func (s *Seal) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &sealTransport{
+		seal: s,
+		next: next,
+	}
+}
+
+type sealTransport struct {
+	seal *Seal
+	next http.RoundTripper
+}
+
+// RoundTrip выполняет запрос и проверяет подпись ответа.
+func (t *sealTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+	// ответы с ошибкой не проверяем, они и так не будут приняты клиентом
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, nil
+	}
+	body := bytes.NewBuffer(nil)
+	_, err = body.ReadFrom(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.seal.verify(body.Bytes(), resp.Header.Get(HashHeader)); err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(body)
+	return resp, nil
+}
+
+// Unwrap возвращает исходный http.RoundTripper.
+func (t *sealTransport) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+// verify проверяет, что data подписаны ключом с подписью seal.
+func (s *Seal) verify(data []byte, seal string) error {
+	if len(seal) == 0 {
+		return fmt.Errorf("no signature: %w", ErrInvalidSignature)
+	}
+	want, err := hex.DecodeString(seal)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", ErrInvalidSignature)
+	}
+
+	h := s.hashers.Get().(hash.Hash)
+	defer s.hashers.Put(h)
+	h.Reset()
+	if _, err := h.Write(data); err != nil {
+		return err
+	}
+	if !hmac.Equal(want, h.Sum(nil)) {
+		return fmt.Errorf("signature mismatch: %w", ErrInvalidSignature)
+	}
+	return nil
+}
@@ -105,3 +105,82 @@ func TestSealNoSignRequest(t *testing.T) {
 	r.Post(httpserver.URL)
 	httpserver.Close()
 }
+
+func TestSealSignRequestWithKeyID(t *testing.T) {
+	seal := NewSealWithKeyID("k1", "secret")
+	httpserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "k1", req.Header.Get("Key-ID"))
+		assert.Equal(t, "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b", req.Header.Get("HashSHA256"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	cli := resty.NewWithClient(httpserver.Client())
+	cli.SetPreRequestHook(seal.Use())
+	r := cli.R().SetBody("hello")
+	r.Post(httpserver.URL)
+	httpserver.Close()
+}
+
+func TestSealVerifyResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		hash    string
+		wantErr bool
+	}{
+		{
+			name:    "Valid signature",
+			status:  http.StatusOK,
+			hash:    "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+			wantErr: false,
+		},
+		{
+			name:    "Invalid signature",
+			status:  http.StatusOK,
+			hash:    "734cc62f32841568f45715aeb9f4d7891324e6d948e4c6c60c0621cdac48623a",
+			wantErr: true,
+		},
+		{
+			name:    "Malformed signature",
+			status:  http.StatusOK,
+			hash:    "not a hash",
+			wantErr: true,
+		},
+		{
+			name:    "No signature",
+			status:  http.StatusOK,
+			hash:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Error response",
+			status:  http.StatusBadRequest,
+			hash:    "",
+			wantErr: false,
+		},
+	}
+	seal := NewSeal("secret")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpserver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if len(tt.hash) > 0 {
+					rw.Header().Set("HashSHA256", tt.hash)
+				}
+				rw.WriteHeader(tt.status)
+				rw.Write([]byte("hello"))
+			}))
+			defer httpserver.Close()
+
+			cli := resty.NewWithClient(httpserver.Client())
+			cli.SetTransport(seal.Transport(httpserver.Client().Transport))
+			resp, err := cli.R().Get(httpserver.URL)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSignature)
+				return
+			}
+			assert.NoError(t, err)
+			// тело ответа доступно клиенту после проверки
+			assert.Equal(t, "hello", resp.String())
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package apiclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/handler"
+	"github.com/k1nky/ypmetrics/internal/handler/middleware"
+	"github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/storage"
+	"github.com/k1nky/ypmetrics/internal/usecases/keeper"
+)
+
+// newTestServer возвращает сервер метрик с подписью, шифрованием и сжатием.
+func newTestServer(t *testing.T, secret string, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	store := storage.NewMemStorage()
+	store.UpdateMetrics(context.Background(), metric.Metrics{
+		Counters: []*metric.Counter{metric.NewCounter("c1", 10), metric.NewCounter("c0", 1)},
+		Gauges:   []*metric.Gauge{metric.NewGauge("g1", 1.5)},
+	})
+	h := handler.New(*keeper.New(store, config.Keeper{}, &logger.Blackhole{}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.NewSeal(secret).Use())
+	router.Use(middleware.NewDecrypter(key).Use())
+	router.Use(middleware.NewGzip([]string{"application/json"}).Use())
+	router.POST("/value/", middleware.RequireContentType("application/json"), h.ValueJSON())
+	router.GET("/values/", h.AllMetricsJSON())
+	return httptest.NewServer(router)
+}
+
+func TestClientRead(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	httpserver := newTestServer(t, "secret", key)
+	defer httpserver.Close()
+
+	c := New(httpserver.URL, &logger.Blackhole{}).SetGzip().SetEncrypt(&key.PublicKey).SetKey("secret")
+	ctx := context.Background()
+
+	counter, err := c.GetCounter(ctx, "c1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, metric.NewCounter("c1", 10), counter)
+	}
+	gauge, err := c.GetGauge(ctx, "g1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, metric.NewGauge("g1", 1.5), gauge)
+	}
+
+	_, err = c.GetCounter(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+	var notFound *NotFoundError
+	if assert.True(t, errors.As(err, &notFound)) {
+		assert.Equal(t, CounterType, notFound.Type)
+		assert.Equal(t, "unknown", notFound.Name)
+	}
+	_, err = c.GetGauge(ctx, "c1")
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	metrics, err := c.ListMetrics(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, metric.Metrics{
+			Counters: []*metric.Counter{metric.NewCounter("c0", 1), metric.NewCounter("c1", 10)},
+			Gauges:   []*metric.Gauge{metric.NewGauge("g1", 1.5)},
+		}, metrics)
+	}
+}
+
+func TestClientReadSpoofedServer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// сервер подписывает ответы другим ключом
+	httpserver := newTestServer(t, "other", key)
+	defer httpserver.Close()
+
+	c := New(httpserver.URL, &logger.Blackhole{}).SetEncrypt(&key.PublicKey).SetKey("secret")
+	_, err = c.ListMetrics(context.Background())
+	assert.Error(t, err)
+}
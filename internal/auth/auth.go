@@ -0,0 +1,269 @@
+// Пакет auth реализует аутентификацию по API токенам с областями доступа (scopes).
+// Токены хранятся только в виде хеша SHA-256, поэтому утечка файла с токенами
+// не раскрывает сами токены. Значение токена показывается один раз при его выпуске.
+//
+// Формат файла с токенами:
+//
+//	{
+//		"tokens": [
+//			{"id": "agent-1", "hash": "<sha256>", "scopes": ["write"], "expires_at": "2024-01-01T00:00:00Z"},
+//			{"id": "dashboard", "hash": "<sha256>", "scopes": ["read"]}
+//		]
+//	}
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scope область доступа токена.
+type Scope string
+
+// Области доступа.
+const (
+	// ScopeRead чтение метрик.
+	ScopeRead = Scope("read")
+	// ScopeWrite изменение метрик.
+	ScopeWrite = Scope("write")
+	// ScopeAdmin администрирование, включает в себя все остальные области.
+	ScopeAdmin = Scope("admin")
+)
+
+const (
+	// размер токена в байтах
+	tokenSize = 32
+)
+
+var (
+	// ErrTokenNotFound токен не найден или отозван.
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTokenExpired срок действия токена истек.
+	ErrTokenExpired = errors.New("token expired")
+	// ErrInvalidToken токен задан неверно.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// IsValid возвращает true, если область доступа имеет допустимое значение.
+func (s Scope) IsValid() bool {
+	switch s {
+	case ScopeRead, ScopeWrite, ScopeAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// Token описание выпущенного токена.
+type Token struct {
+	// ID идентификатор токена (например, имя агента).
+	ID string `json:"id"`
+	// Hash хеш SHA-256 значения токена в шестнадцатеричном виде.
+	Hash string `json:"hash"`
+	// Scopes области доступа токена.
+	Scopes []Scope `json:"scopes"`
+	// ExpiresAt момент истечения срока действия. Нулевое значение - бессрочный токен.
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// HasScope возвращает true, если токен имеет доступ к области scope.
+// Область admin дает доступ ко всем областям.
+func (t Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired возвращает true, если срок действия токена истек к моменту at.
+func (t Token) IsExpired(at time.Time) bool {
+	return !t.ExpiresAt.IsZero() && at.After(t.ExpiresAt)
+}
+
+// HashToken возвращает хеш значения токена value.
+func HashToken(value string) string {
+	h := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(h[:])
+}
+
+// Tokens потокобезопасный набор токенов.
+type Tokens struct {
+	lock sync.RWMutex
+	// токены по хешу
+	byHash map[string]Token
+}
+
+// tokensFile формат файла с токенами.
+type tokensFile struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// New возвращает новый пустой набор токенов.
+func New() *Tokens {
+	return &Tokens{
+		byHash: make(map[string]Token),
+	}
+}
+
+// Lookup возвращает токен по его значению value, если он действует в момент at.
+func (ts *Tokens) Lookup(value string, at time.Time) (Token, error) {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+
+	t, ok := ts.byHash[HashToken(value)]
+	if !ok {
+		return Token{}, ErrTokenNotFound
+	}
+	if t.IsExpired(at) {
+		return Token{}, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// Mint выпускает новый токен с идентификатором id, областями доступа scopes и сроком действия ttl.
+// Нулевой ttl означает бессрочный токен. Возвращает значение токена, которое больше нигде не хранится.
+func (ts *Tokens) Mint(id string, scopes []Scope, ttl time.Duration) (string, Token, error) {
+	buf := make([]byte, tokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", Token{}, err
+	}
+	value := hex.EncodeToString(buf)
+	t := Token{
+		ID:     id,
+		Hash:   HashToken(value),
+		Scopes: scopes,
+	}
+	if ttl > 0 {
+		t.ExpiresAt = time.Now().Add(ttl).UTC().Truncate(time.Second)
+	}
+	if err := validate(t); err != nil {
+		return "", Token{}, err
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	for _, v := range ts.byHash {
+		if v.ID == id {
+			return "", Token{}, fmt.Errorf("token %q: duplicate id: %w", id, ErrInvalidToken)
+		}
+	}
+	ts.byHash[t.Hash] = t
+	return value, t, nil
+}
+
+// Revoke отзывает токен с идентификатором id.
+func (ts *Tokens) Revoke(id string) error {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	for h, t := range ts.byHash {
+		if t.ID == id {
+			delete(ts.byHash, h)
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// List возвращает список токенов, упорядоченный по идентификатору.
+func (ts *Tokens) List() []Token {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+	result := make([]Token, 0, len(ts.byHash))
+	for _, t := range ts.byHash {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Load заменяет токены набора токенами, прочитанными из r в формате JSON.
+// Если хотя бы один токен задан неверно, набор остается прежним.
+func (ts *Tokens) Load(r io.Reader) error {
+	f := tokensFile{}
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return err
+	}
+	byHash := make(map[string]Token, len(f.Tokens))
+	ids := make(map[string]struct{}, len(f.Tokens))
+	for _, t := range f.Tokens {
+		if err := validate(t); err != nil {
+			return err
+		}
+		if _, ok := ids[t.ID]; ok {
+			return fmt.Errorf("token %q: duplicate id: %w", t.ID, ErrInvalidToken)
+		}
+		ids[t.ID] = struct{}{}
+		byHash[t.Hash] = t
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	ts.byHash = byHash
+	return nil
+}
+
+// LoadFile загружает токены из файла path.
+func (ts *Tokens) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ts.Load(f)
+}
+
+// Save сохраняет токены в w в формате JSON.
+func (ts *Tokens) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tokensFile{Tokens: ts.List()})
+}
+
+// SaveFile сохраняет токены в файл path. Файл перезаписывается атомарно,
+// чтобы сервер, отслеживающий файл, не прочитал его частично.
+func (ts *Tokens) SaveFile(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := ts.Save(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func validate(t Token) error {
+	if len(t.ID) == 0 {
+		return fmt.Errorf("empty id: %w", ErrInvalidToken)
+	}
+	if len(t.Hash) != sha256.Size*2 {
+		return fmt.Errorf("token %q: invalid hash: %w", t.ID, ErrInvalidToken)
+	}
+	if len(t.Scopes) == 0 {
+		return fmt.Errorf("token %q: no scopes: %w", t.ID, ErrInvalidToken)
+	}
+	for _, s := range t.Scopes {
+		if !s.IsValid() {
+			return fmt.Errorf("token %q: unknown scope %q: %w", t.ID, s, ErrInvalidToken)
+		}
+	}
+	return nil
+}
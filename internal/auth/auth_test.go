@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []Scope
+		scope  Scope
+		want   bool
+	}{
+		{name: "Same scope", scopes: []Scope{ScopeRead}, scope: ScopeRead, want: true},
+		{name: "Another scope", scopes: []Scope{ScopeRead}, scope: ScopeWrite, want: false},
+		{name: "Admin", scopes: []Scope{ScopeAdmin}, scope: ScopeWrite, want: true},
+		{name: "Multiple scopes", scopes: []Scope{ScopeRead, ScopeWrite}, scope: ScopeWrite, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := Token{Scopes: tt.scopes}
+			assert.Equal(t, tt.want, token.HasScope(tt.scope))
+		})
+	}
+}
+
+func TestTokensMintLookupRevoke(t *testing.T) {
+	ts := New()
+	value, token, err := ts.Mint("agent-1", []Scope{ScopeWrite}, 0)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, value)
+	// значение токена не хранится
+	assert.NotEqual(t, value, token.Hash)
+	assert.Equal(t, HashToken(value), token.Hash)
+
+	got, err := ts.Lookup(value, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "agent-1", got.ID)
+
+	// повторно с тем же идентификатором выпустить нельзя
+	_, _, err = ts.Mint("agent-1", []Scope{ScopeWrite}, 0)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	assert.NoError(t, ts.Revoke("agent-1"))
+	_, err = ts.Lookup(value, time.Now())
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+	assert.ErrorIs(t, ts.Revoke("agent-1"), ErrTokenNotFound)
+}
+
+func TestTokensExpired(t *testing.T) {
+	ts := New()
+	value, _, err := ts.Mint("agent-1", []Scope{ScopeWrite}, time.Hour)
+	assert.NoError(t, err)
+	_, err = ts.Lookup(value, time.Now())
+	assert.NoError(t, err)
+	_, err = ts.Lookup(value, time.Now().Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
+
+func TestTokensMintInvalid(t *testing.T) {
+	ts := New()
+	_, _, err := ts.Mint("", []Scope{ScopeRead}, 0)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, _, err = ts.Mint("agent-1", []Scope{}, 0)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, _, err = ts.Mint("agent-1", []Scope{"root"}, 0)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestTokensSaveLoad(t *testing.T) {
+	ts := New()
+	value, _, err := ts.Mint("agent-1", []Scope{ScopeWrite}, time.Hour)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ts.Save(buf))
+	assert.NotContains(t, buf.String(), value)
+
+	loaded := New()
+	assert.NoError(t, loaded.Load(buf))
+	got, err := loaded.Lookup(value, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []Scope{ScopeWrite}, got.Scopes)
+
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	assert.NoError(t, ts.SaveFile(path))
+	loaded = New()
+	assert.NoError(t, loaded.LoadFile(path))
+	assert.Len(t, loaded.List(), 1)
+}
+
+func TestTokensLoadInvalid(t *testing.T) {
+	hash := HashToken("value")
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Invalid JSON", data: `{"tokens": [`},
+		{name: "Invalid hash", data: `{"tokens": [{"id": "t1", "hash": "abc", "scopes": ["read"]}]}`},
+		{name: "Unknown scope", data: `{"tokens": [{"id": "t1", "hash": "` + hash + `", "scopes": ["root"]}]}`},
+		{name: "Duplicate id", data: `{"tokens": [{"id": "t1", "hash": "` + hash + `", "scopes": ["read"]}, {"id": "t1", "hash": "` + hash + `", "scopes": ["read"]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := New()
+			assert.Error(t, ts.Load(strings.NewReader(tt.data)))
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+// DefaultProcDir каталог файловой системы proc.
+const DefaultProcDir = "/proc"
+
+var (
+	// ErrCgroupNotFound cgroup v2 процесса не найдена. Ошибка оборачивает ErrUnavailable.
+	ErrCgroupNotFound = fmt.Errorf("cgroup v2 not found: %w", ErrUnavailable)
+)
+
+// CgroupOptions параметры сборщика Cgroup.
+type CgroupOptions struct {
+	// Path каталог cgroup, например, /sys/fs/cgroup/system.slice/agent.service.
+	// По умолчанию определяется cgroup v2 текущего процесса.
+	Path string `json:"path"`
+}
+
+// Cgroup сборщик метрик использования ресурсов cgroup v2, в которой запущен агент.
+// В контейнере метрики Gops относятся ко всему хосту, а метрики Cgroup - к самому контейнеру.
+// Сборщик включен по умолчанию и пропускается, если cgroup v2 не найдена.
+//
+// Собираются метрики типа Gauge: CgroupMemoryUsage, CgroupMemoryLimit, CgroupPids, CgroupPidsLimit,
+// CgroupCPULimit (количество CPU) и CgroupCPUPercent (загрузка CPU с предыдущего опроса).
+// Ограничения передаются, только если они заданы. Метрики типа Counter передаются с приращением
+// с предыдущего опроса: CgroupCPUUsage, CgroupCPUUser и CgroupCPUSystem в микросекундах,
+// CgroupCPUPeriods, CgroupCPUThrottled и CgroupCPUThrottledTime в микросекундах. Для каждого устройства
+// передаются метрики CgroupIOReadBytes, CgroupIOWriteBytes, CgroupIOReads и CgroupIOWrites, например,
+// CgroupIOReadBytes.8_0. Метрики контроллеров, которые не включены для cgroup, не передаются.
+type Cgroup struct {
+	Options CgroupOptions
+
+	dir    string
+	deltas *counterDeltas
+	// предыдущее значение usage_usec и время его получения для расчета загрузки CPU
+	prevUsage uint64
+	prevTime  time.Time
+
+	// каталог файловой системы proc и текущее время, переопределяются в тестах
+	procDir string
+	now     func() time.Time
+}
+
+// NewCgroup возвращает сборщик Cgroup с параметрами options в формате JSON.
+func NewCgroup(options json.RawMessage) (Collector, error) {
+	c := &Cgroup{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Init инициализирует сборщика. Если cgroup v2 не найдена, то возвращается ошибка ErrCgroupNotFound.
+func (c *Cgroup) Init() (err error) {
+	if len(c.procDir) == 0 {
+		c.procDir = DefaultProcDir
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+	c.dir = c.Options.Path
+	if len(c.dir) == 0 {
+		if c.dir, err = detectCgroup(c.procDir); err != nil {
+			return err
+		}
+	}
+	// в каталоге cgroup v2 всегда есть cgroup.controllers
+	if _, err := os.Stat(filepath.Join(c.dir, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("%s: %w", c.dir, ErrCgroupNotFound)
+	}
+	c.deltas = newCounterDeltas()
+	c.prevTime = time.Time{}
+	return nil
+}
+
+// Collect возвращает метрики, собранные сборщиком.
+func (c *Cgroup) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	now := c.now()
+
+	for _, v := range []struct {
+		file string
+		name string
+	}{
+		{"memory.current", "CgroupMemoryUsage"},
+		{"memory.max", "CgroupMemoryLimit"},
+		{"pids.current", "CgroupPids"},
+		{"pids.max", "CgroupPidsLimit"},
+	} {
+		value, ok, err := c.readValue(v.file)
+		if err != nil {
+			return *metrics, err
+		}
+		if ok {
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge(v.name, float64(value)))
+		}
+	}
+	if limit, ok, err := c.readCPULimit(); err != nil {
+		return *metrics, err
+	} else if ok {
+		metrics.Gauges = append(metrics.Gauges, metric.NewGauge("CgroupCPULimit", limit))
+	}
+
+	cpu, err := c.readKeyed("cpu.stat")
+	if err != nil {
+		return *metrics, err
+	}
+	for _, v := range []struct {
+		key  string
+		name string
+	}{
+		{"usage_usec", "CgroupCPUUsage"},
+		{"user_usec", "CgroupCPUUser"},
+		{"system_usec", "CgroupCPUSystem"},
+		{"nr_periods", "CgroupCPUPeriods"},
+		{"nr_throttled", "CgroupCPUThrottled"},
+		{"throttled_usec", "CgroupCPUThrottledTime"},
+	} {
+		value, ok := cpu[v.key]
+		if !ok {
+			continue
+		}
+		if delta, ok := c.deltas.delta(v.name, value); ok {
+			metrics.Counters = append(metrics.Counters, metric.NewCounter(v.name, delta))
+		}
+	}
+	if usage, ok := cpu["usage_usec"]; ok {
+		if !c.prevTime.IsZero() && now.After(c.prevTime) && usage >= c.prevUsage {
+			elapsed := now.Sub(c.prevTime).Microseconds()
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge("CgroupCPUPercent", float64(usage-c.prevUsage)/float64(elapsed)*100))
+		}
+		c.prevUsage, c.prevTime = usage, now
+	}
+
+	io, err := c.readIOStat()
+	if err != nil {
+		return *metrics, err
+	}
+	for device, stat := range io {
+		for _, v := range []struct {
+			key  string
+			name string
+		}{
+			{"rbytes", "CgroupIOReadBytes"},
+			{"wbytes", "CgroupIOWriteBytes"},
+			{"rios", "CgroupIOReads"},
+			{"wios", "CgroupIOWrites"},
+		} {
+			key := metricName(v.name, device)
+			if delta, ok := c.deltas.delta(key, stat[v.key]); ok {
+				metrics.Counters = append(metrics.Counters, metric.NewCounter(key, delta))
+			}
+		}
+	}
+	c.deltas.sweep()
+	return *metrics, nil
+}
+
+// readFile возвращает содержимое файла name из каталога cgroup. Если файла нет
+// (контроллер не включен), то возвращается false.
+func (c *Cgroup) readFile(name string) (string, bool, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+// readValue возвращает значение из файла name с одним числом. Значение max (без ограничения)
+// считается отсутствующим.
+func (c *Cgroup) readValue(name string) (uint64, bool, error) {
+	s, ok, err := c.readFile(name)
+	if err != nil || !ok || s == "max" {
+		return 0, false, err
+	}
+	value, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", name, err)
+	}
+	return value, true, nil
+}
+
+// readCPULimit возвращает ограничение CPU из файла cpu.max в формате "$MAX $PERIOD" в количестве CPU.
+func (c *Cgroup) readCPULimit() (float64, bool, error) {
+	s, ok, err := c.readFile("cpu.max")
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false, nil
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("cpu.max: %w", err)
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period == 0 {
+		return 0, false, fmt.Errorf("cpu.max: invalid period %q", fields[1])
+	}
+	return quota / period, true, nil
+}
+
+// readKeyed возвращает значения из файла name в формате "key value" на каждой строке.
+func (c *Cgroup) readKeyed(name string) (map[string]uint64, error) {
+	s, ok, err := c.readFile(name)
+	if err != nil || !ok {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, nil
+}
+
+// readIOStat возвращает статистику из файла io.stat по устройствам. Каждая строка файла
+// имеет формат "$MAJ:$MIN key=value ...".
+func (c *Cgroup) readIOStat() (map[string]map[string]uint64, error) {
+	s, ok, err := c.readFile("io.stat")
+	if err != nil || !ok {
+		return nil, err
+	}
+	devices := make(map[string]map[string]uint64)
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		stat := make(map[string]uint64)
+		for _, f := range fields[1:] {
+			key, value, found := strings.Cut(f, "=")
+			if !found {
+				continue
+			}
+			if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+				stat[key] = v
+			}
+		}
+		devices[fields[0]] = stat
+	}
+	return devices, nil
+}
+
+// detectCgroup возвращает каталог cgroup v2 текущего процесса. Путь cgroup берется из записи "0::$PATH"
+// файла self/cgroup, а точка монтирования cgroup2 - из self/mountinfo. Если каталога с таким путем
+// нет (например, в контейнере без пространства имен cgroup смонтирована только своя cgroup), то
+// используется точка монтирования.
+func detectCgroup(procDir string) (string, error) {
+	cgroupPath, err := readSelfCgroup(filepath.Join(procDir, "self", "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	root, mountpoint, err := readCgroup2Mount(filepath.Join(procDir, "self", "mountinfo"))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, cgroupPath); err == nil && !strings.HasPrefix(rel, "..") {
+		dir := filepath.Join(mountpoint, rel)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+	return mountpoint, nil
+}
+
+// readSelfCgroup возвращает путь cgroup v2 из файла path в формате proc/self/cgroup.
+func readSelfCgroup(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, found := strings.CutPrefix(scanner.Text(), "0::"); found {
+			return p, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrCgroupNotFound
+}
+
+// readCgroup2Mount возвращает корень и точку монтирования файловой системы cgroup2
+// из файла path в формате proc/self/mountinfo.
+func readCgroup2Mount(path string) (root string, mountpoint string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 0:30 / /sys/fs/cgroup rw,nosuid - cgroup2 cgroup2 rw
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" && len(fields) > 4 {
+				return fields[3], fields[4], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+	return "", "", ErrCgroupNotFound
+}
@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// copyFixture копирует файлы каталога src в новый временный каталог.
+func copyFixture(t *testing.T, src string) string {
+	t.Helper()
+	dst := t.TempDir()
+	entries, err := os.ReadDir(src)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		os.WriteFile(filepath.Join(dst, e.Name()), data, 0600)
+	}
+	return dst
+}
+
+func newTestCgroup(t *testing.T, dir string, now *time.Time) *Cgroup {
+	t.Helper()
+	c, err := NewCgroup(json.RawMessage(`{"path": "` + dir + `"}`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	cg := c.(*Cgroup)
+	cg.now = func() time.Time { return *now }
+	if !assert.NoError(t, cg.Init()) {
+		t.FailNow()
+	}
+	return cg
+}
+
+func TestCgroupCollect(t *testing.T) {
+	dir := copyFixture(t, "testdata/cgroup/limited")
+	now := time.Unix(1000, 0)
+	c := newTestCgroup(t, dir, &now)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, m.Counters)
+	assert.Equal(t, map[string]float64{
+		"CgroupMemoryUsage": 104857600,
+		"CgroupMemoryLimit": 536870912,
+		"CgroupPids":        12,
+		"CgroupPidsLimit":   100,
+		"CgroupCPULimit":    1.5,
+	}, gaugeValues(m))
+
+	os.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\nnr_periods 110\nnr_throttled 15\nthrottled_usec 80000\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "io.stat"), []byte("8:0 rbytes=5096 wbytes=8192 rios=2 wios=2 dbytes=0 dios=0\n"), 0600)
+	now = now.Add(time.Second)
+	m, err = c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, gaugeValues(m)["CgroupCPUPercent"])
+	assert.Equal(t, map[string]int64{
+		"CgroupCPUUsage":         500000,
+		"CgroupCPUUser":          300000,
+		"CgroupCPUSystem":        200000,
+		"CgroupCPUPeriods":       10,
+		"CgroupCPUThrottled":     5,
+		"CgroupCPUThrottledTime": 30000,
+		"CgroupIOReadBytes.8_0":  1000,
+		"CgroupIOWriteBytes.8_0": 0,
+		"CgroupIOReads.8_0":      1,
+		"CgroupIOWrites.8_0":     0,
+	}, counterValues(m))
+}
+
+func TestCgroupCollectUnlimited(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newTestCgroup(t, "testdata/cgroup/unlimited", &now)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	// ограничения не заданы, а контроллер io не включен
+	assert.Equal(t, map[string]float64{
+		"CgroupMemoryUsage": 2048,
+		"CgroupPids":        3,
+	}, gaugeValues(m))
+}
+
+func TestCgroupInit(t *testing.T) {
+	fixture, _ := filepath.Abs("testdata/cgroup/limited")
+
+	writeProc := func(t *testing.T, cgroup string, mountinfo string) string {
+		procDir := t.TempDir()
+		os.Mkdir(filepath.Join(procDir, "self"), 0700)
+		os.WriteFile(filepath.Join(procDir, "self", "cgroup"), []byte(cgroup), 0600)
+		os.WriteFile(filepath.Join(procDir, "self", "mountinfo"), []byte(mountinfo), 0600)
+		return procDir
+	}
+	mountRoot := filepath.Dir(fixture)
+	tests := []struct {
+		name      string
+		cgroup    string
+		mountinfo string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Nested cgroup",
+			cgroup:    "0::/limited\n",
+			mountinfo: "25 30 0:22 / /proc rw - proc proc rw\n36 30 0:30 / " + mountRoot + " rw,nosuid - cgroup2 cgroup2 rw\n",
+			want:      fixture,
+		},
+		{
+			name:      "Cgroup namespace",
+			cgroup:    "0::/\n",
+			mountinfo: "36 30 0:30 / " + fixture + " rw,nosuid - cgroup2 cgroup2 rw\n",
+			want:      fixture,
+		},
+		{
+			name:      "Only own cgroup mounted",
+			cgroup:    "0::/docker/abc\n",
+			mountinfo: "36 30 0:30 /docker/abc " + fixture + " ro - cgroup2 cgroup rw\n",
+			want:      fixture,
+		},
+		{
+			name:      "Cgroup v1",
+			cgroup:    "4:memory:/docker/abc\n",
+			mountinfo: "36 30 0:30 / /sys/fs/cgroup/memory rw - cgroup cgroup rw,memory\n",
+			wantErr:   true,
+		},
+		{
+			name:      "Not cgroup v2 directory",
+			cgroup:    "0::/\n",
+			mountinfo: "36 30 0:30 / " + mountRoot + " rw - cgroup2 cgroup2 rw\n",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cgroup{procDir: writeProc(t, tt.cgroup, tt.mountinfo)}
+			err := c.Init()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrCgroupNotFound)
+				assert.ErrorIs(t, err, ErrUnavailable)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, c.dir)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package collector
+
+// counterDeltas вычисляет приращения монотонно растущих счетчиков между опросами.
+// Счетчики, которые не встречались в очередном опросе, забываются, поэтому после
+// повторного появления (например, переподключения устройства) приращение считается заново.
+type counterDeltas struct {
+	prev map[string]uint64
+	seen map[string]struct{}
+}
+
+func newCounterDeltas() *counterDeltas {
+	return &counterDeltas{
+		prev: make(map[string]uint64),
+		seen: make(map[string]struct{}),
+	}
+}
+
+// delta возвращает приращение счетчика key со значением value с предыдущего опроса.
+// При первом появлении счетчика приращение неизвестно, и возвращается false.
+// Источники счетчиков (/proc, cgroup, Prometheus) используют 64-битные значения, которые
+// на практике не переполняются, поэтому уменьшение значения считается сбросом счетчика
+// (например, перезапуском приложения или устройства), и приращением считается текущее значение.
+func (d *counterDeltas) delta(key string, value uint64) (int64, bool) {
+	d.seen[key] = struct{}{}
+	prev, ok := d.prev[key]
+	d.prev[key] = value
+	if !ok {
+		return 0, false
+	}
+	if value >= prev {
+		return int64(value - prev), true
+	}
+	return int64(value), true
+}
+
+// sweep забывает счетчики, которые не встречались с предыдущего вызова sweep.
+func (d *counterDeltas) sweep() {
+	for key := range d.prev {
+		if _, ok := d.seen[key]; !ok {
+			delete(d.prev, key)
+		}
+	}
+	d.seen = make(map[string]struct{})
+}
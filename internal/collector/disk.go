@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+
+	"github.com/shirou/gopsutil/v3/disk"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/logger"
+)
+
+// DiskOptions параметры сборщика Disk. Фильтры задаются шаблонами в формате path.Match.
+type DiskOptions struct {
+	// IncludeMountpoints точки монтирования, по которым собираются метрики. По умолчанию все.
+	IncludeMountpoints []string `json:"include_mountpoints"`
+	// ExcludeMountpoints точки монтирования, которые исключаются.
+	ExcludeMountpoints []string `json:"exclude_mountpoints"`
+	// IncludeFSTypes типы файловых систем, по которым собираются метрики. По умолчанию все.
+	IncludeFSTypes []string `json:"include_fs_types"`
+	// ExcludeFSTypes типы файловых систем, которые исключаются.
+	ExcludeFSTypes []string `json:"exclude_fs_types"`
+}
+
+// Disk сборщик метрик использования файловых систем и дисков на основе пакета gopsutil.
+//
+// Для каждой точки монтирования собираются метрики типа Gauge: DiskTotal, DiskUsed, DiskFree,
+// DiskInodesTotal, DiskInodesUsed и DiskInodesFree. Для каждого устройства отобранных точек монтирования
+// собираются метрики типа Counter с приращением с предыдущего опроса: DiskReadBytes, DiskWriteBytes,
+// DiskReads и DiskWrites. Точка монтирования или устройство добавляется к имени метрики, например,
+// DiskUsed._var или DiskReadBytes.sda1. Если разные точки монтирования дают одинаковое имя
+// (например, /var/lib и /var_lib), то к имени следующей по алфавиту точки добавляется суффикс: DiskUsed._var_lib_2.
+// Точки монтирования, использование которых получить не удалось, пропускаются, а ошибка
+// записывается в лог. Ошибка получения статистики устройств также записывается в лог,
+// а метрики использования точек монтирования при этом возвращаются.
+type Disk struct {
+	Options DiskOptions
+
+	mountpoints *filter
+	fsTypes     *filter
+	deltas      *counterDeltas
+	logger      Logger
+
+	// функции получения статистики, по умолчанию используется gopsutil
+	partitions func(ctx context.Context, all bool) ([]disk.PartitionStat, error)
+	usage      func(ctx context.Context, path string) (*disk.UsageStat, error)
+	ioCounters func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error)
+}
+
+// NewDisk возвращает сборщик Disk с параметрами options в формате JSON.
+func NewDisk(options json.RawMessage) (Collector, error) {
+	c := &Disk{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetLogger устанавливает логер для вывода ошибок получения использования точек монтирования.
+func (c *Disk) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// Init инициализирует сборщика.
+func (c *Disk) Init() (err error) {
+	if c.logger == nil {
+		c.logger = &logger.Blackhole{}
+	}
+	if c.mountpoints, err = newFilter(c.Options.IncludeMountpoints, c.Options.ExcludeMountpoints); err != nil {
+		return err
+	}
+	if c.fsTypes, err = newFilter(c.Options.IncludeFSTypes, c.Options.ExcludeFSTypes); err != nil {
+		return err
+	}
+	c.deltas = newCounterDeltas()
+	if c.partitions == nil {
+		c.partitions = disk.PartitionsWithContext
+	}
+	if c.usage == nil {
+		c.usage = disk.UsageWithContext
+	}
+	if c.ioCounters == nil {
+		c.ioCounters = disk.IOCountersWithContext
+	}
+	return nil
+}
+
+// Collect возвращает метрики, собранные сборщиком.
+func (c *Disk) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	partitions, err := c.partitions(ctx, false)
+	if err != nil {
+		return *metrics, err
+	}
+	// имена метрик не должны зависеть от порядка точек монтирования в системе
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Mountpoint < partitions[j].Mountpoint })
+	labels := uniqueLabels{}
+	devices := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		if !c.mountpoints.Match(p.Mountpoint) || !c.fsTypes.Match(p.Fstype) {
+			continue
+		}
+		usage, err := c.usage(ctx, p.Mountpoint)
+		if err != nil {
+			// например, недоступная сетевая файловая система не должна мешать сбору остальных
+			c.logger.Warnf("disk %s: %s", p.Mountpoint, err)
+			continue
+		}
+		label := labels.label(p.Mountpoint)
+		metrics.Gauges = append(metrics.Gauges,
+			metric.NewGauge(metricName("DiskTotal", label), float64(usage.Total)),
+			metric.NewGauge(metricName("DiskUsed", label), float64(usage.Used)),
+			metric.NewGauge(metricName("DiskFree", label), float64(usage.Free)),
+			metric.NewGauge(metricName("DiskInodesTotal", label), float64(usage.InodesTotal)),
+			metric.NewGauge(metricName("DiskInodesUsed", label), float64(usage.InodesUsed)),
+			metric.NewGauge(metricName("DiskInodesFree", label), float64(usage.InodesFree)),
+		)
+		devices = append(devices, filepath.Base(p.Device))
+	}
+	if len(devices) == 0 {
+		return *metrics, nil
+	}
+	counters, err := c.ioCounters(ctx, devices...)
+	if err != nil {
+		// метрики использования уже собраны, поэтому ошибка не должна их отбрасывать
+		c.logger.Warnf("disk io counters: %s", err)
+		return *metrics, nil
+	}
+	for name, io := range counters {
+		for _, v := range []struct {
+			name  string
+			value uint64
+		}{
+			{"DiskReadBytes", io.ReadBytes},
+			{"DiskWriteBytes", io.WriteBytes},
+			{"DiskReads", io.ReadCount},
+			{"DiskWrites", io.WriteCount},
+		} {
+			key := metricName(v.name, name)
+			if delta, ok := c.deltas.delta(key, v.value); ok {
+				metrics.Counters = append(metrics.Counters, metric.NewCounter(key, delta))
+			}
+		}
+	}
+	c.deltas.sweep()
+	return *metrics, nil
+}
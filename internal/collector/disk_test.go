@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+func newTestDisk(t *testing.T, options string, io *uint64) *Disk {
+	t.Helper()
+	c, err := NewDisk(json.RawMessage(options))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	d := c.(*Disk)
+	d.partitions = func(ctx context.Context, all bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+			{Device: "/dev/sdb1", Mountpoint: "/var/lib", Fstype: "xfs"},
+			{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+		}, nil
+	}
+	d.usage = func(ctx context.Context, path string) (*disk.UsageStat, error) {
+		return &disk.UsageStat{Path: path, Total: 100, Used: 40, Free: 60, InodesTotal: 10, InodesUsed: 1, InodesFree: 9}, nil
+	}
+	d.ioCounters = func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error) {
+		result := map[string]disk.IOCountersStat{}
+		for _, name := range names {
+			result[name] = disk.IOCountersStat{Name: name, ReadBytes: *io, WriteBytes: 2 * *io, ReadCount: *io / 10, WriteCount: *io / 5}
+		}
+		return result, nil
+	}
+	if !assert.NoError(t, d.Init()) {
+		t.FailNow()
+	}
+	return d
+}
+
+func gaugeNames(m metric.Metrics) []string {
+	names := []string{}
+	for _, g := range m.Gauges {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+func TestDiskCollect(t *testing.T) {
+	io := uint64(1000)
+	c := newTestDisk(t, `{"exclude_fs_types": ["tmpfs"]}`, &io)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, m.Gauges, 12)
+	assert.Contains(t, gaugeNames(m), "DiskUsed._")
+	assert.Contains(t, gaugeNames(m), "DiskInodesFree._var_lib")
+	assert.NotContains(t, gaugeNames(m), "DiskUsed._run")
+	// при первом опросе приращения счетчиков неизвестны
+	assert.Empty(t, m.Counters)
+
+	io = 1500
+	m, err = c.Collect(context.TODO())
+	assert.NoError(t, err)
+	counters := map[string]int64{}
+	for _, c := range m.Counters {
+		counters[c.Name] = c.Value
+	}
+	assert.Equal(t, map[string]int64{
+		"DiskReadBytes.sda1": 500, "DiskWriteBytes.sda1": 1000, "DiskReads.sda1": 50, "DiskWrites.sda1": 100,
+		"DiskReadBytes.sdb1": 500, "DiskWriteBytes.sdb1": 1000, "DiskReads.sdb1": 50, "DiskWrites.sdb1": 100,
+	}, counters)
+}
+
+func TestDiskMountpointFilter(t *testing.T) {
+	io := uint64(0)
+	c := newTestDisk(t, `{"include_mountpoints": ["/var/*"]}`, &io)
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, m.Gauges, 6)
+	assert.Contains(t, gaugeNames(m), "DiskTotal._var_lib")
+}
+
+func TestDiskUsageError(t *testing.T) {
+	io := uint64(0)
+	c := newTestDisk(t, `{"exclude_fs_types": ["tmpfs"]}`, &io)
+	l := &testLogger{}
+	c.SetLogger(l)
+	usage := c.usage
+	c.usage = func(ctx context.Context, path string) (*disk.UsageStat, error) {
+		if path == "/var/lib" {
+			return nil, errors.New("stale file handle")
+		}
+		return usage(ctx, path)
+	}
+	c.Collect(context.TODO())
+	io = 100
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, m.Gauges, 6)
+	assert.Contains(t, gaugeNames(m), "DiskUsed._")
+	assert.Equal(t, map[string]int64{
+		"DiskReadBytes.sda1": 100, "DiskWriteBytes.sda1": 200, "DiskReads.sda1": 10, "DiskWrites.sda1": 20,
+	}, counterValues(m))
+	assert.Contains(t, l.String(), "warn: disk /var/lib: stale file handle")
+}
+
+func TestDiskIOCountersError(t *testing.T) {
+	io := uint64(0)
+	c := newTestDisk(t, `{"exclude_fs_types": ["tmpfs"]}`, &io)
+	l := &testLogger{}
+	c.SetLogger(l)
+	c.ioCounters = func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error) {
+		return nil, errors.New("no such file or directory")
+	}
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, m.Gauges, 12)
+	assert.Empty(t, m.Counters)
+	assert.Contains(t, l.String(), "warn: disk io counters: no such file or directory")
+}
+
+func TestDiskMountpointCollision(t *testing.T) {
+	io := uint64(0)
+	c := newTestDisk(t, `{}`, &io)
+	c.partitions = func(ctx context.Context, all bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{
+			{Device: "/dev/sdc1", Mountpoint: "/var_lib", Fstype: "ext4"},
+			{Device: "/dev/sdb1", Mountpoint: "/var/lib", Fstype: "xfs"},
+		}, nil
+	}
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, m.Gauges, 12)
+	assert.Contains(t, gaugeNames(m), "DiskUsed._var_lib")
+	assert.Contains(t, gaugeNames(m), "DiskUsed._var_lib_2")
+}
+
+func TestNewDisk(t *testing.T) {
+	_, err := NewDisk(json.RawMessage(`{"include_mountpoint": ["/"]}`))
+	assert.Error(t, err)
+	c, err := NewDisk(json.RawMessage(`{"exclude_mountpoints": ["["]}`))
+	assert.NoError(t, err)
+	assert.Error(t, c.Init())
+}
+
+func TestDiskCollectHost(t *testing.T) {
+	c := &Disk{}
+	if !assert.NoError(t, c.Init()) {
+		return
+	}
+	_, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+}
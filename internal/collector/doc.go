@@ -5,4 +5,7 @@
 //		   Collect(ctx context.Context) (metric.Metrics, error)
 //		   Init() error
 //	  }
+//
+// Сборщики регистрируются в реестре Registry по именам. Набор используемых сборщиков
+// и их параметры задаются в разделе collectors конфигурации агента.
 package collector
@@ -0,0 +1,253 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/protocol"
+)
+
+// Форматы вывода команд сборщика Exec.
+const (
+	// ExecFormatSimple строки вида "counter name value" или "gauge name value".
+	ExecFormatSimple = "simple"
+	// ExecFormatPrometheus текстовый формат Prometheus.
+	ExecFormatPrometheus = "prometheus"
+)
+
+const (
+	// DefaultExecTimeout таймаут выполнения команды по умолчанию.
+	DefaultExecTimeout = 10 * time.Second
+	// ExecWaitDelay время ожидания закрытия вывода команды после ее завершения. Дочерние процессы
+	// команды могут продолжать держать вывод открытым.
+	ExecWaitDelay = time.Second
+)
+
+var (
+	// ErrInvalidExecCommand у команды не задано имя, сама команда или задан неизвестный формат вывода.
+	ErrInvalidExecCommand = errors.New("invalid exec command")
+	// ErrInvalidExecLine строка вывода команды не соответствует формату simple.
+	ErrInvalidExecLine = errors.New("invalid exec output line")
+)
+
+// ExecCommand команда, которая выполняется сборщиком Exec.
+type ExecCommand struct {
+	// Name имя команды, которое добавляется к именам метрик команды и метрики ExecSuccess.
+	Name string `json:"name"`
+	// Command путь до исполняемого файла и его аргументы.
+	Command []string `json:"command"`
+	// Env дополнительные переменные окружения команды. Остальные переменные наследуются от агента.
+	Env map[string]string `json:"env"`
+	// TimeoutInSec таймаут выполнения команды (в секундах). По умолчанию DefaultExecTimeout.
+	TimeoutInSec uint `json:"timeout"`
+	// Format формат вывода команды: simple (по умолчанию) или prometheus.
+	Format string `json:"format"`
+}
+
+// ExecOptions параметры сборщика Exec.
+type ExecOptions struct {
+	// Commands команды, которые выполняются при каждом опросе.
+	Commands []ExecCommand `json:"commands"`
+}
+
+// execResult результат выполнения команды.
+type execResult struct {
+	stdout []byte
+	stderr []byte
+	err    error
+}
+
+// Exec сборщик метрик, которые выводят внешние команды (например, скрипты). Интервал опроса
+// задается в настройках сборщика, а все команды выполняются параллельно.
+//
+// Вывод команды в формате simple состоит из строк "counter name value" или "gauge name value",
+// пустые строки и строки, начинающиеся с #, пропускаются. Значение counter передается на сервер
+// как приращение. Вывод в формате prometheus разбирается как текстовый формат Prometheus: счетчики
+// передаются с приращением с предыдущего опроса, а к имени метрики добавляются метки, например,
+// requests_total.code_200. В обоих форматах в начало имени метрики добавляется имя команды,
+// например, app.queue_size или app.requests_total.code_200. Для каждой команды передается
+// метрика ExecSuccess.<name>, равная 1, если команда завершилась успешно и ее вывод разобран,
+// и 0 в противном случае.
+// Код завершения и вывод stderr команды записываются в лог.
+type Exec struct {
+	Options ExecOptions
+
+	deltas *counterDeltas
+	env    [][]string
+	logger Logger
+}
+
+// NewExec возвращает сборщик Exec с параметрами options в формате JSON.
+func NewExec(options json.RawMessage) (Collector, error) {
+	c := &Exec{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetLogger устанавливает логер для вывода ошибок команд.
+func (c *Exec) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// Init инициализирует сборщика.
+func (c *Exec) Init() error {
+	if c.logger == nil {
+		c.logger = &logger.Blackhole{}
+	}
+	c.env = make([][]string, 0, len(c.Options.Commands))
+	for _, cmd := range c.Options.Commands {
+		if len(cmd.Name) == 0 || len(cmd.Command) == 0 {
+			return fmt.Errorf("%q: %w", cmd.Name, ErrInvalidExecCommand)
+		}
+		switch cmd.Format {
+		case "", ExecFormatSimple, ExecFormatPrometheus:
+		default:
+			return fmt.Errorf("%q: unknown format %q: %w", cmd.Name, cmd.Format, ErrInvalidExecCommand)
+		}
+		env := make([]string, 0, len(cmd.Env))
+		for k, v := range cmd.Env {
+			env = append(env, k+"="+v)
+		}
+		sort.Strings(env)
+		c.env = append(c.env, append(os.Environ(), env...))
+	}
+	c.deltas = newCounterDeltas()
+	return nil
+}
+
+// Collect возвращает метрики, собранные сборщиком. Ошибки отдельных команд записываются в лог
+// и не приводят к ошибке опроса.
+func (c *Exec) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	results := make([]execResult, len(c.Options.Commands))
+	wg := sync.WaitGroup{}
+	for i := range c.Options.Commands {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.run(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, cmd := range c.Options.Commands {
+		r := results[i]
+		if len(r.stderr) > 0 {
+			c.logger.Warnf("exec %s: stderr: %s", cmd.Name, bytes.TrimSpace(r.stderr))
+		}
+		err := r.err
+		if err == nil {
+			err = c.parse(metrics, cmd, r.stdout)
+		}
+		success := 1.0
+		if err != nil {
+			c.logger.Errorf("exec %s: %s", cmd.Name, err)
+			success = 0
+		}
+		metrics.Gauges = append(metrics.Gauges, metric.NewGauge(metricName("ExecSuccess", cmd.Name), success))
+	}
+	c.deltas.sweep()
+	return *metrics, nil
+}
+
+// run выполняет i-ю команду.
+func (c *Exec) run(ctx context.Context, i int) execResult {
+	cmd := c.Options.Commands[i]
+	timeout := DefaultExecTimeout
+	if cmd.TimeoutInSec > 0 {
+		timeout = time.Duration(cmd.TimeoutInSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	e := exec.CommandContext(ctx, cmd.Command[0], cmd.Command[1:]...)
+	e.Env = c.env[i]
+	e.Stdout, e.Stderr = stdout, stderr
+	e.WaitDelay = ExecWaitDelay
+	err := e.Run()
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return execResult{stdout: stdout.Bytes(), stderr: stderr.Bytes(), err: err}
+}
+
+// parse разбирает вывод stdout команды cmd и добавляет метрики в metrics. Если вывод разобрать
+// не удалось, то метрики команды не добавляются.
+func (c *Exec) parse(metrics *metric.Metrics, cmd ExecCommand, stdout []byte) error {
+	if cmd.Format == ExecFormatPrometheus {
+		samples, err := parsePromText(bytes.NewReader(stdout))
+		if err != nil {
+			return err
+		}
+		target := metric.NewMetrics()
+		appendPromSamples(target, samples, c.deltas, cmd.Name+"/")
+		appendPrefixed(metrics, target, sanitizeLabel(cmd.Name))
+		return nil
+	}
+	m, err := parseExecSimple(bytes.NewReader(stdout))
+	if err != nil {
+		return err
+	}
+	appendPrefixed(metrics, m, sanitizeLabel(cmd.Name))
+	return nil
+}
+
+// parseExecSimple разбирает вывод в формате simple.
+func parseExecSimple(r io.Reader) (*metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: %w", n, ErrInvalidExecLine)
+		}
+		name := sanitizeLabel(fields[1])
+		switch fields[0] {
+		case protocol.TypeCounter:
+			value, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w: %v", n, ErrInvalidExecLine, err)
+			}
+			metrics.Counters = append(metrics.Counters, metric.NewCounter(name, value))
+		case protocol.TypeGauge:
+			value, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w: %v", n, ErrInvalidExecLine, err)
+			}
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("line %d: %w: invalid value %s", n, ErrInvalidExecLine, fields[2])
+			}
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge(name, value))
+		default:
+			return nil, fmt.Errorf("line %d: unknown type %q: %w", n, fields[0], ErrInvalidExecLine)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
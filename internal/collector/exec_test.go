@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testLogger логер, который сохраняет сообщения.
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) log(level string, template string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+": "+fmt.Sprintf(template, args...))
+}
+
+func (l *testLogger) Debugf(template string, args ...interface{}) { l.log("debug", template, args...) }
+func (l *testLogger) Warnf(template string, args ...interface{})  { l.log("warn", template, args...) }
+func (l *testLogger) Errorf(template string, args ...interface{}) { l.log("error", template, args...) }
+
+func (l *testLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.messages, "\n")
+}
+
+func newTestExec(t *testing.T, options string) (*Exec, *testLogger) {
+	t.Helper()
+	c, err := NewExec(json.RawMessage(options))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	e := c.(*Exec)
+	l := &testLogger{}
+	e.SetLogger(l)
+	if !assert.NoError(t, e.Init()) {
+		t.FailNow()
+	}
+	return e, l
+}
+
+func TestExecCollectSimple(t *testing.T) {
+	c, l := newTestExec(t, `{"commands": [
+		{"name": "simple", "command": ["testdata/exec/simple.sh"], "env": {"QUEUE_SIZE": "7"}},
+		{"name": "fail", "command": ["testdata/exec/fail.sh"]},
+		{"name": "invalid", "command": ["testdata/exec/invalid.sh"]},
+		{"name": "missing", "command": ["testdata/exec/missing.sh"]}
+	]}`)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"simple.jobs_done": 3}, counterValues(m))
+	// метрики команд с ошибкой не передаются
+	assert.Equal(t, map[string]float64{
+		"simple.queue_size":   7,
+		"ExecSuccess.simple":  1,
+		"ExecSuccess.fail":    0,
+		"ExecSuccess.invalid": 0,
+		"ExecSuccess.missing": 0,
+	}, gaugeValues(m))
+	assert.Contains(t, l.String(), "warn: exec fail: stderr: disk is not mounted")
+	assert.Contains(t, l.String(), "error: exec fail: exit status 3")
+	assert.Contains(t, l.String(), "error: exec invalid: line 1: unknown type \"histogram\"")
+	assert.Contains(t, l.String(), "error: exec missing:")
+}
+
+func TestExecCollectPrometheus(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "counter")
+	os.WriteFile(counter, []byte("100"), 0600)
+	c, _ := newTestExec(t, `{"commands": [
+		{"name": "prom", "command": ["testdata/exec/prometheus.sh"], "format": "prometheus", "env": {"COUNTER_FILE": "`+counter+`"}}
+	]}`)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, m.Counters)
+	assert.Equal(t, map[string]float64{
+		"prom.temperature.room_server_room": 21.5,
+		"ExecSuccess.prom":                  1,
+	}, gaugeValues(m))
+
+	os.WriteFile(counter, []byte("130"), 0600)
+	m, err = c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"prom.http_requests_total.code_200.method_GET": 30}, counterValues(m))
+}
+
+func TestExecCollectTimeout(t *testing.T) {
+	c, l := newTestExec(t, `{"commands": [{"name": "sleep", "command": ["testdata/exec/sleep.sh"], "timeout": 1}]}`)
+
+	start := time.Now()
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 4*time.Second)
+	assert.Equal(t, map[string]float64{"ExecSuccess.sleep": 0}, gaugeValues(m))
+	assert.Contains(t, l.String(), context.DeadlineExceeded.Error())
+}
+
+func TestExecInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{name: "Without name", options: `{"commands": [{"command": ["true"]}]}`},
+		{name: "Without command", options: `{"commands": [{"name": "true"}]}`},
+		{name: "Unknown format", options: `{"commands": [{"name": "true", "command": ["true"], "format": "json"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewExec(json.RawMessage(tt.options))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.ErrorIs(t, c.Init(), ErrInvalidExecCommand)
+		})
+	}
+}
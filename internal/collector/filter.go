@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+)
+
+// filter отбирает значения по шаблонам включения и исключения в формате path.Match.
+// Значение проходит фильтр, если оно подходит под один из шаблонов включения (или они не заданы)
+// и не подходит ни под один шаблон исключения.
+type filter struct {
+	include []string
+	exclude []string
+}
+
+// newFilter возвращает фильтр с шаблонами include и exclude. Если шаблон некорректный, то возвращается ошибка.
+func newFilter(include []string, exclude []string) (*filter, error) {
+	for _, p := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return &filter{
+		include: include,
+		exclude: exclude,
+	}, nil
+}
+
+// Match возвращает true, если значение v проходит фильтр.
+func (f *filter) Match(v string) bool {
+	if matchAny(f.exclude, v) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, v)
+}
+
+func matchAny(patterns []string, v string) bool {
+	for _, p := range patterns {
+		// шаблоны проверены при создании фильтра
+		if ok, _ := path.Match(p, v); ok {
+			return true
+		}
+	}
+	return false
+}
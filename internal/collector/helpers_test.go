@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricName(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		want   string
+	}{
+		{name: "DiskUsed", labels: nil, want: "DiskUsed"},
+		{name: "DiskUsed", labels: []string{"/"}, want: "DiskUsed._"},
+		{name: "DiskUsed", labels: []string{"/var/lib"}, want: "DiskUsed._var_lib"},
+		{name: "NetBytesSent", labels: []string{"eth0.100"}, want: "NetBytesSent.eth0.100"},
+		{name: "Proc", labels: []string{"nginx", ""}, want: "Proc.nginx._"},
+		{name: "Prom", labels: []string{"code=200 ok"}, want: "Prom.code_200_ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, metricName(tt.name, tt.labels...))
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	_, err := newFilter([]string{"["}, nil)
+	assert.Error(t, err)
+
+	f, err := newFilter(nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, f.Match("anything"))
+
+	f, err = newFilter([]string{"eth*", "lo"}, []string{"eth1"})
+	assert.NoError(t, err)
+	assert.True(t, f.Match("eth0"))
+	assert.True(t, f.Match("lo"))
+	assert.False(t, f.Match("eth1"))
+	assert.False(t, f.Match("docker0"))
+}
+
+func TestCounterDeltas(t *testing.T) {
+	d := newCounterDeltas()
+	_, ok := d.delta("a", 100)
+	assert.False(t, ok)
+	v, ok := d.delta("a", 150)
+	assert.True(t, ok)
+	assert.Equal(t, int64(50), v)
+
+	// сброс счетчика, в том числе со значения из верхней половины 32-битного диапазона
+	d.delta("b", 3e9)
+	v, _ = d.delta("b", 5)
+	assert.Equal(t, int64(5), v)
+	d.delta("c", 1<<40)
+	v, _ = d.delta("c", 7)
+	assert.Equal(t, int64(7), v)
+	d.delta("c", 1000)
+	v, _ = d.delta("c", 10)
+	assert.Equal(t, int64(10), v)
+
+	// счетчик, который не встречался с последнего sweep, забывается
+	d.sweep()
+	d.delta("a", 200)
+	d.sweep()
+	_, ok = d.delta("b", 10)
+	assert.False(t, ok)
+	v, ok = d.delta("a", 210)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), v)
+}
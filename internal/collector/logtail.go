@@ -0,0 +1,306 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/logger"
+)
+
+var (
+	// ErrInvalidLogPattern у файла не задан путь, у шаблона не задано имя, имя повторяется
+	// или в регулярном выражении нет группы value_group.
+	ErrInvalidLogPattern = errors.New("invalid log pattern")
+)
+
+// LogPattern шаблон строк журнала.
+type LogPattern struct {
+	// Name имя шаблона, которое добавляется к именам метрик. Имена шаблонов всех файлов должны быть уникальны.
+	Name string `json:"name"`
+	// Regex регулярное выражение, которому должна соответствовать строка.
+	Regex string `json:"regex"`
+	// ValueGroup имя группы регулярного выражения с числовым значением, например, latency
+	// для выражения `took (?P<latency>[0-9.]+)ms`. По умолчанию значение не извлекается.
+	ValueGroup string `json:"value_group"`
+}
+
+// LogFile файл журнала, за которым следит сборщик LogTail.
+type LogFile struct {
+	// Path путь до файла.
+	Path string `json:"path"`
+	// Patterns шаблоны строк.
+	Patterns []LogPattern `json:"patterns"`
+	// FromBeginning читать файл, который существовал при запуске, с начала.
+	// По умолчанию учитываются только строки, добавленные после запуска.
+	FromBeginning bool `json:"from_beginning"`
+}
+
+// LogTailOptions параметры сборщика LogTail.
+type LogTailOptions struct {
+	// Files файлы журналов.
+	Files []LogFile `json:"files"`
+	// StateFile файл, в котором сохраняются позиции чтения файлов между перезапусками агента.
+	// По умолчанию позиции не сохраняются.
+	StateFile string `json:"state_file"`
+}
+
+// logPattern шаблон с разобранным регулярным выражением.
+type logPattern struct {
+	name string
+	re   *regexp.Regexp
+	// индекс группы со значением или -1
+	valueGroup int
+}
+
+// logFileState позиция чтения файла.
+type logFileState struct {
+	// ID идентификатор файла (номер inode), по которому определяется ротация.
+	ID uint64 `json:"id"`
+	// Offset позиция начала первой непрочитанной строки.
+	Offset int64 `json:"offset"`
+}
+
+// tailedFile файл журнала, за которым следит сборщик.
+type tailedFile struct {
+	LogFile
+	patterns []logPattern
+	f        *os.File
+	state    logFileState
+	// файл уже открывался, поэтому новый файл по этому пути читается с начала
+	seen bool
+	// позиция восстановлена из StateFile
+	restored bool
+}
+
+// LogTail сборщик метрик по строкам файлов журналов, которые дописываются с момента предыдущего опроса.
+//
+// Для каждого шаблона передается метрика типа Counter LogMatches с количеством новых строк, которые
+// соответствуют шаблону, например, LogMatches.errors. Если у шаблона задана группа со значением,
+// то значение из последней подходящей строки передается в метрике типа Gauge LogValue, например,
+// LogValue.latency. Строки, которые еще не дописаны до конца, учитываются при следующем опросе.
+//
+// Ротация файла определяется по смене inode: сначала дочитывается прежний файл, затем новый файл
+// читается с начала. Удаленный файл дочитывается и закрывается до появления нового файла.
+// Если размер файла стал меньше позиции чтения, то файл считается обрезанным
+// и читается с начала. Позиции чтения сохраняются в StateFile после каждого опроса.
+type LogTail struct {
+	Options LogTailOptions
+
+	files  []*tailedFile
+	logger Logger
+}
+
+// NewLogTail возвращает сборщик LogTail с параметрами options в формате JSON.
+func NewLogTail(options json.RawMessage) (Collector, error) {
+	c := &LogTail{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetLogger устанавливает логер для вывода ошибок чтения файлов.
+func (c *LogTail) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// Init инициализирует сборщика и восстанавливает позиции чтения из StateFile.
+func (c *LogTail) Init() error {
+	if c.logger == nil {
+		c.logger = &logger.Blackhole{}
+	}
+	names := make(map[string]struct{})
+	c.files = make([]*tailedFile, 0, len(c.Options.Files))
+	for _, lf := range c.Options.Files {
+		if len(lf.Path) == 0 {
+			return fmt.Errorf("file path is required: %w", ErrInvalidLogPattern)
+		}
+		t := &tailedFile{LogFile: lf}
+		for _, p := range lf.Patterns {
+			if _, ok := names[p.Name]; ok || len(p.Name) == 0 {
+				return fmt.Errorf("%q: %w", p.Name, ErrInvalidLogPattern)
+			}
+			names[p.Name] = struct{}{}
+			re, err := regexp.Compile(p.Regex)
+			if err != nil {
+				return fmt.Errorf("%q: %w", p.Name, err)
+			}
+			pattern := logPattern{name: p.Name, re: re, valueGroup: -1}
+			if len(p.ValueGroup) > 0 {
+				if pattern.valueGroup = re.SubexpIndex(p.ValueGroup); pattern.valueGroup < 0 {
+					return fmt.Errorf("%q: group %q not found: %w", p.Name, p.ValueGroup, ErrInvalidLogPattern)
+				}
+			}
+			t.patterns = append(t.patterns, pattern)
+		}
+		c.files = append(c.files, t)
+	}
+	return c.loadState()
+}
+
+// Collect возвращает метрики, собранные сборщиком. Ошибки чтения отдельных файлов записываются в лог.
+func (c *LogTail) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	for _, t := range c.files {
+		matches := make([]int64, len(t.patterns))
+		values := make(map[int]float64)
+		if err := c.tail(t, matches, values); err != nil {
+			c.logger.Warnf("log tail %s: %s", t.Path, err)
+		}
+		for i, p := range t.patterns {
+			metrics.Counters = append(metrics.Counters, metric.NewCounter(metricName("LogMatches", p.name), matches[i]))
+			if v, ok := values[i]; ok {
+				metrics.Gauges = append(metrics.Gauges, metric.NewGauge(metricName("LogValue", p.name), v))
+			}
+		}
+	}
+	if err := c.saveState(); err != nil {
+		c.logger.Errorf("log tail: save state: %s", err)
+	}
+	return *metrics, nil
+}
+
+// tail дочитывает файл t и считает строки, которые соответствуют шаблонам.
+func (c *LogTail) tail(t *tailedFile, matches []int64, values map[int]float64) error {
+	if t.f != nil {
+		// дочитываем открытый файл, даже если он уже переименован при ротации
+		if err := c.read(t, matches, values); err != nil {
+			return err
+		}
+	}
+	fi, err := os.Stat(t.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// файл удален или еще не создан, новый файл нужно будет читать с начала
+			t.seen = true
+			if t.f != nil {
+				// удаленный файл уже дочитан, больше его держать открытым не нужно
+				t.f.Close()
+				t.f, t.restored = nil, false
+			}
+			return nil
+		}
+		return err
+	}
+	id := fileID(fi)
+	switch {
+	case t.f == nil || id != t.state.ID:
+		// новый или ротированный файл
+		f, err := os.Open(t.Path)
+		if err != nil {
+			return err
+		}
+		if t.f != nil {
+			t.f.Close()
+		}
+		offset := int64(0)
+		switch {
+		case t.f == nil && t.restored && id == t.state.ID && t.state.Offset <= fi.Size():
+			// позиция восстановлена из сохраненного состояния
+			offset = t.state.Offset
+		case !t.seen && !t.FromBeginning:
+			offset = fi.Size()
+		}
+		t.f, t.state, t.seen = f, logFileState{ID: id, Offset: offset}, true
+	case fi.Size() < t.state.Offset:
+		// файл обрезан
+		t.state.Offset = 0
+	}
+	return c.read(t, matches, values)
+}
+
+// read читает целые строки файла t с сохраненной позиции.
+func (c *LogTail) read(t *tailedFile, matches []int64, values map[int]float64) error {
+	if _, err := t.f.Seek(t.state.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(t.f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// недописанная строка будет прочитана при следующем опросе
+				return nil
+			}
+			return err
+		}
+		t.state.Offset += int64(len(line))
+		for i, p := range t.patterns {
+			m := p.re.FindSubmatch(line)
+			if m == nil {
+				continue
+			}
+			matches[i]++
+			if p.valueGroup < 0 {
+				continue
+			}
+			if v, err := strconv.ParseFloat(string(m[p.valueGroup]), 64); err == nil {
+				values[i] = v
+			}
+		}
+	}
+}
+
+// loadState восстанавливает позиции чтения файлов из StateFile.
+func (c *LogTail) loadState() error {
+	if len(c.Options.StateFile) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(c.Options.StateFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	state := make(map[string]logFileState)
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s: %w", c.Options.StateFile, err)
+	}
+	for _, t := range c.files {
+		if s, ok := state[t.Path]; ok {
+			// файл уже читался до перезапуска, поэтому после ротации его нужно читать с начала
+			t.state, t.seen, t.restored = s, true, true
+		}
+	}
+	return nil
+}
+
+// saveState сохраняет позиции чтения открытых файлов в StateFile. Файл заменяется атомарно.
+func (c *LogTail) saveState() error {
+	if len(c.Options.StateFile) == 0 {
+		return nil
+	}
+	state := make(map[string]logFileState, len(c.files))
+	for _, t := range c.files {
+		if t.f != nil {
+			state[t.Path] = t.state
+		}
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.Options.StateFile), filepath.Base(c.Options.StateFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Options.StateFile)
+}
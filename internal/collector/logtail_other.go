@@ -0,0 +1,11 @@
+//go:build !unix
+
+package collector
+
+import "os"
+
+// fileID возвращает идентификатор файла. На этой платформе ротация по смене файла не определяется,
+// определяется только обрезание файла.
+func fileID(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogTail(t *testing.T, options string) *LogTail {
+	t.Helper()
+	c, err := NewLogTail(json.RawMessage(options))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	lt := c.(*LogTail)
+	lt.SetLogger(&testLogger{})
+	if !assert.NoError(t, lt.Init()) {
+		t.FailNow()
+	}
+	return lt
+}
+
+func appendLog(t *testing.T, path string, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer f.Close()
+	f.WriteString(s)
+}
+
+func logTailOptions(path string, extra string) string {
+	return `{"files": [{"path": "` + path + `", ` + extra + `"patterns": [
+		{"name": "errors", "regex": "ERROR"},
+		{"name": "latency", "regex": "took (?P<ms>[0-9.]+)ms", "value_group": "ms"}
+	]}]`
+}
+
+func TestLogTailCollect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "ERROR old error\n")
+	c := newTestLogTail(t, logTailOptions(path, "")+`}`)
+
+	// строки, которые были до запуска, не учитываются
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 0, "LogMatches.latency": 0}, counterValues(m))
+	assert.Empty(t, m.Gauges)
+
+	appendLog(t, path, "ERROR failed\nGET / took 12.5ms\nGET /api took 7ms\nERROR not fin")
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 1, "LogMatches.latency": 2}, counterValues(m))
+	assert.Equal(t, map[string]float64{"LogValue.latency": 7}, gaugeValues(m))
+
+	// строка дописана до конца
+	appendLog(t, path, "ished\n")
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 1, "LogMatches.latency": 0}, counterValues(m))
+	assert.Empty(t, m.Gauges)
+}
+
+func TestLogTailFromBeginning(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	missing := filepath.Join(dir, "missing.log")
+	appendLog(t, path, "ERROR old error\n")
+	c := newTestLogTail(t, `{"files": [
+		{"path": "`+path+`", "from_beginning": true, "patterns": [{"name": "errors", "regex": "ERROR"}]},
+		{"path": "`+missing+`", "patterns": [{"name": "missing", "regex": "ERROR"}]}
+	]}`)
+
+	m, _ := c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 1, "LogMatches.missing": 0}, counterValues(m))
+
+	// файл, которого не было при запуске, читается с начала
+	appendLog(t, missing, "ERROR first\n")
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 0, "LogMatches.missing": 1}, counterValues(m))
+}
+
+func TestLogTailRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+	c := newTestLogTail(t, logTailOptions(path, "")+`}`)
+	c.Collect(context.TODO())
+
+	appendLog(t, path, "ERROR before rotation\n")
+	os.Rename(path, path+".1")
+	// запись в прежний файл после переименования
+	appendLog(t, path+".1", "ERROR after rename\n")
+	appendLog(t, path, "ERROR new file\nGET took 3ms\n")
+	m, _ := c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 3, "LogMatches.latency": 1}, counterValues(m))
+
+	// обрезание файла
+	os.Truncate(path, 0)
+	appendLog(t, path, "ERROR after truncate\n")
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 1, "LogMatches.latency": 0}, counterValues(m))
+}
+
+func TestLogTailRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+	c := newTestLogTail(t, logTailOptions(path, "")+`}`)
+	c.Collect(context.TODO())
+
+	appendLog(t, path, "ERROR before remove\n")
+	os.Remove(path)
+	m, _ := c.Collect(context.TODO())
+	// удаленный файл дочитан и закрыт
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 1, "LogMatches.latency": 0}, counterValues(m))
+	assert.Nil(t, c.files[0].f)
+
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 0, "LogMatches.latency": 0}, counterValues(m))
+
+	// новый файл читается с начала
+	appendLog(t, path, "ERROR new file\n")
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, map[string]int64{"LogMatches.errors": 1, "LogMatches.latency": 0}, counterValues(m))
+	assert.NotNil(t, c.files[0].f)
+}
+
+func TestLogTailState(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	options := logTailOptions(path, "") + `, "state_file": "` + filepath.Join(dir, "state.json") + `"}`
+	appendLog(t, path, "ERROR old error\n")
+
+	c := newTestLogTail(t, options)
+	c.Collect(context.TODO())
+	appendLog(t, path, "ERROR counted once\n")
+	m, _ := c.Collect(context.TODO())
+	assert.Equal(t, int64(1), counterValues(m)["LogMatches.errors"])
+
+	// строки, дописанные во время перезапуска, учитываются, а прочитанные ранее - нет
+	appendLog(t, path, "ERROR while restarting\n")
+	c = newTestLogTail(t, options)
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, int64(1), counterValues(m)["LogMatches.errors"])
+
+	// файл ротирован во время перезапуска
+	os.Rename(path, path+".1")
+	appendLog(t, path, "ERROR new file\n")
+	c = newTestLogTail(t, options)
+	m, _ = c.Collect(context.TODO())
+	assert.Equal(t, int64(1), counterValues(m)["LogMatches.errors"])
+}
+
+func TestLogTailInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{name: "Without path", options: `{"files": [{"patterns": [{"name": "a", "regex": "a"}]}]}`},
+		{name: "Without name", options: `{"files": [{"path": "a.log", "patterns": [{"regex": "a"}]}]}`},
+		{name: "Duplicate name", options: `{"files": [{"path": "a.log", "patterns": [{"name": "a", "regex": "a"}]}, {"path": "b.log", "patterns": [{"name": "a", "regex": "b"}]}]}`},
+		{name: "Invalid regexp", options: `{"files": [{"path": "a.log", "patterns": [{"name": "a", "regex": "("}]}]}`},
+		{name: "Unknown group", options: `{"files": [{"path": "a.log", "patterns": [{"name": "a", "regex": "(?P<v>\\d+)", "value_group": "value"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewLogTail(json.RawMessage(tt.options))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Error(t, c.Init())
+		})
+	}
+}
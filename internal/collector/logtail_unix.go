@@ -0,0 +1,16 @@
+//go:build unix
+
+package collector
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID возвращает номер inode файла.
+func fileID(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
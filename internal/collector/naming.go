@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+)
+
+// metricName возвращает имя метрики name с метками labels в виде name.label1.label2.
+// Недопустимые символы меток заменяются на подчеркивание, поэтому имя не зависит от порядка опроса
+// и его можно передать в пути запроса к серверу.
+func metricName(name string, labels ...string) string {
+	s := strings.Builder{}
+	s.WriteString(name)
+	for _, l := range labels {
+		s.WriteByte('.')
+		s.WriteString(sanitizeLabel(l))
+	}
+	return s.String()
+}
+
+// sanitizeLabel заменяет в метке символы, отличные от [A-Za-z0-9_.-], на подчеркивание.
+// Пустая метка заменяется на подчеркивание.
+func sanitizeLabel(label string) string {
+	if len(label) == 0 {
+		return "_"
+	}
+	b := []byte(label)
+	for i, c := range b {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '.' || c == '-') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// uniqueLabels запоминает выданные метки, чтобы разные значения, совпадающие после замены
+// недопустимых символов, не давали одинаковых имен метрик.
+type uniqueLabels map[string]bool
+
+// label возвращает метку для value. Если такая метка уже выдана, то к ней добавляется
+// суффикс _2, _3 и т.д.
+func (u uniqueLabels) label(value string) string {
+	label := sanitizeLabel(value)
+	unique := label
+	for i := 2; u[unique]; i++ {
+		unique = label + "_" + strconv.Itoa(i)
+	}
+	u[unique] = true
+	return unique
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/logger"
+)
+
+// tcpStates состояния TCP соединений, которые всегда передаются сборщиком Net, даже если соединений нет.
+var tcpStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+// NetOptions параметры сборщика Net. Фильтры задаются шаблонами в формате path.Match.
+type NetOptions struct {
+	// IncludeInterfaces сетевые интерфейсы, по которым собираются метрики. По умолчанию все.
+	IncludeInterfaces []string `json:"include_interfaces"`
+	// ExcludeInterfaces сетевые интерфейсы, которые исключаются.
+	ExcludeInterfaces []string `json:"exclude_interfaces"`
+	// DisableTCPStates не собирать количество TCP соединений по состояниям.
+	// Для подсчета соединений просматриваются открытые файлы всех процессов, что может быть затратно.
+	DisableTCPStates bool `json:"disable_tcp_states"`
+}
+
+// Net сборщик метрик сетевых интерфейсов на основе пакета gopsutil.
+//
+// Для каждого интерфейса собираются метрики типа Counter с приращением с предыдущего опроса:
+// NetBytesSent, NetBytesRecv, NetPacketsSent, NetPacketsRecv, NetErrIn, NetErrOut, NetDropIn и NetDropOut.
+// Имя интерфейса добавляется к имени метрики, например, NetBytesSent.eth0. При сбросе счетчика
+// приращением считается его текущее значение, а интерфейс, который пропал, при повторном появлении
+// считается новым. Количество TCP соединений по состояниям передается в метриках типа Gauge
+// NetTCPConnections, например, NetTCPConnections.ESTABLISHED. Если соединения получить не удалось,
+// то ошибка записывается в лог, а метрики интерфейсов передаются без метрик соединений.
+type Net struct {
+	Options NetOptions
+
+	interfaces *filter
+	deltas     *counterDeltas
+	logger     Logger
+
+	// функции получения статистики, по умолчанию используется gopsutil
+	ioCounters  func(ctx context.Context, pernic bool) ([]psnet.IOCountersStat, error)
+	connections func(ctx context.Context, kind string) ([]psnet.ConnectionStat, error)
+}
+
+// NewNet возвращает сборщик Net с параметрами options в формате JSON.
+func NewNet(options json.RawMessage) (Collector, error) {
+	c := &Net{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetLogger устанавливает логер для вывода ошибок получения TCP соединений.
+func (c *Net) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// Init инициализирует сборщика.
+func (c *Net) Init() (err error) {
+	if c.logger == nil {
+		c.logger = &logger.Blackhole{}
+	}
+	if c.interfaces, err = newFilter(c.Options.IncludeInterfaces, c.Options.ExcludeInterfaces); err != nil {
+		return err
+	}
+	c.deltas = newCounterDeltas()
+	if c.ioCounters == nil {
+		c.ioCounters = psnet.IOCountersWithContext
+	}
+	if c.connections == nil {
+		c.connections = psnet.ConnectionsWithoutUidsWithContext
+	}
+	return nil
+}
+
+// Collect возвращает метрики, собранные сборщиком.
+func (c *Net) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	counters, err := c.ioCounters(ctx, true)
+	if err != nil {
+		return *metrics, err
+	}
+	for _, nic := range counters {
+		if !c.interfaces.Match(nic.Name) {
+			continue
+		}
+		for _, v := range []struct {
+			name  string
+			value uint64
+		}{
+			{"NetBytesSent", nic.BytesSent},
+			{"NetBytesRecv", nic.BytesRecv},
+			{"NetPacketsSent", nic.PacketsSent},
+			{"NetPacketsRecv", nic.PacketsRecv},
+			{"NetErrIn", nic.Errin},
+			{"NetErrOut", nic.Errout},
+			{"NetDropIn", nic.Dropin},
+			{"NetDropOut", nic.Dropout},
+		} {
+			key := metricName(v.name, nic.Name)
+			if delta, ok := c.deltas.delta(key, v.value); ok {
+				metrics.Counters = append(metrics.Counters, metric.NewCounter(key, delta))
+			}
+		}
+	}
+	// пропавшие интерфейсы забываем
+	c.deltas.sweep()
+
+	if c.Options.DisableTCPStates {
+		return *metrics, nil
+	}
+	connections, err := c.connections(ctx, "tcp")
+	if err != nil {
+		// приращения счетчиков интерфейсов уже учтены, поэтому их нельзя терять
+		c.logger.Warnf("net: tcp connections: %s", err)
+		return *metrics, nil
+	}
+	states := make(map[string]int, len(tcpStates))
+	for _, s := range tcpStates {
+		states[s] = 0
+	}
+	for _, conn := range connections {
+		states[conn.Status]++
+	}
+	for s, count := range states {
+		metrics.Gauges = append(metrics.Gauges, metric.NewGauge(metricName("NetTCPConnections", s), float64(count)))
+	}
+	return *metrics, nil
+}
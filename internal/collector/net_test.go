@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+func counterValues(m metric.Metrics) map[string]int64 {
+	values := map[string]int64{}
+	for _, c := range m.Counters {
+		values[c.Name] = c.Value
+	}
+	return values
+}
+
+func gaugeValues(m metric.Metrics) map[string]float64 {
+	values := map[string]float64{}
+	for _, g := range m.Gauges {
+		values[g.Name] = g.Value
+	}
+	return values
+}
+
+func TestNetCollect(t *testing.T) {
+	nics := []psnet.IOCountersStat{
+		{Name: "eth0", BytesSent: 1000, BytesRecv: 3e9},
+		{Name: "lo", BytesSent: 10},
+		{Name: "veth1", BytesSent: 5},
+	}
+	c, err := NewNet(json.RawMessage(`{"exclude_interfaces": ["lo"]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	n := c.(*Net)
+	n.ioCounters = func(ctx context.Context, pernic bool) ([]psnet.IOCountersStat, error) {
+		return nics, nil
+	}
+	n.connections = func(ctx context.Context, kind string) ([]psnet.ConnectionStat, error) {
+		return []psnet.ConnectionStat{{Status: "ESTABLISHED"}, {Status: "ESTABLISHED"}, {Status: "LISTEN"}}, nil
+	}
+	if !assert.NoError(t, n.Init()) {
+		return
+	}
+
+	m, err := n.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, m.Counters)
+	gauges := gaugeValues(m)
+	assert.Len(t, gauges, len(tcpStates))
+	assert.Equal(t, 2.0, gauges["NetTCPConnections.ESTABLISHED"])
+	assert.Equal(t, 1.0, gauges["NetTCPConnections.LISTEN"])
+	assert.Equal(t, 0.0, gauges["NetTCPConnections.TIME_WAIT"])
+
+	// BytesRecv сбрасывается, veth1 пропадает
+	nics = []psnet.IOCountersStat{
+		{Name: "eth0", BytesSent: 1500, BytesRecv: 100},
+		{Name: "lo", BytesSent: 20},
+	}
+	m, err = n.Collect(context.TODO())
+	assert.NoError(t, err)
+	counters := counterValues(m)
+	assert.Equal(t, int64(500), counters["NetBytesSent.eth0"])
+	assert.Equal(t, int64(100), counters["NetBytesRecv.eth0"])
+	assert.Equal(t, int64(0), counters["NetDropIn.eth0"])
+	assert.NotContains(t, counters, "NetBytesSent.lo")
+	assert.NotContains(t, counters, "NetBytesSent.veth1")
+
+	// veth1 появляется снова и считается новым интерфейсом
+	nics = append(nics, psnet.IOCountersStat{Name: "veth1", BytesSent: 1})
+	m, err = n.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.NotContains(t, counterValues(m), "NetBytesSent.veth1")
+}
+
+func TestNetDisableTCPStates(t *testing.T) {
+	c, err := NewNet(json.RawMessage(`{"disable_tcp_states": true}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	n := c.(*Net)
+	n.connections = func(ctx context.Context, kind string) ([]psnet.ConnectionStat, error) {
+		t.Error("connections should not be requested")
+		return nil, nil
+	}
+	if !assert.NoError(t, n.Init()) {
+		return
+	}
+	m, err := n.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, m.Gauges)
+}
+
+func TestNetConnectionsError(t *testing.T) {
+	bytesSent := uint64(1000)
+	c, err := NewNet(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	n := c.(*Net)
+	l := &testLogger{}
+	n.SetLogger(l)
+	n.ioCounters = func(ctx context.Context, pernic bool) ([]psnet.IOCountersStat, error) {
+		return []psnet.IOCountersStat{{Name: "eth0", BytesSent: bytesSent}}, nil
+	}
+	n.connections = func(ctx context.Context, kind string) ([]psnet.ConnectionStat, error) {
+		return nil, errors.New("permission denied")
+	}
+	if !assert.NoError(t, n.Init()) {
+		return
+	}
+	n.Collect(context.TODO())
+	bytesSent = 1500
+	m, err := n.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500), counterValues(m)["NetBytesSent.eth0"])
+	assert.Empty(t, m.Gauges)
+	assert.Contains(t, l.String(), "warn: net: tcp connections: permission denied")
+}
@@ -0,0 +1,327 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/logger"
+)
+
+// Типы проверок сборщика Probe.
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+)
+
+const (
+	// DefaultProbeTimeout таймаут проверки по умолчанию.
+	DefaultProbeTimeout = 10 * time.Second
+	// ProbeMaxBodySize максимальный размер тела ответа, которое проверяется регулярным выражением.
+	ProbeMaxBodySize = 1 << 20
+)
+
+var (
+	// ErrInvalidProbeTarget у цели не задано имя, адрес или задан неизвестный тип проверки.
+	ErrInvalidProbeTarget = errors.New("invalid probe target")
+	// ErrProbeFailed ответ цели не соответствует ожиданиям.
+	ErrProbeFailed = errors.New("probe failed")
+)
+
+// ProbeTarget цель, которую проверяет сборщик Probe.
+type ProbeTarget struct {
+	// Name имя цели, которое добавляется к именам метрик.
+	Name string `json:"name"`
+	// Type тип проверки: http (по умолчанию) или tcp.
+	Type string `json:"type"`
+	// URL адрес для проверки http, например, https://example.com/healthz.
+	URL string `json:"url"`
+	// Address адрес и порт для проверки tcp, например, localhost:5432.
+	Address string `json:"address"`
+	// TimeoutInSec таймаут проверки (в секундах). По умолчанию DefaultProbeTimeout.
+	TimeoutInSec uint `json:"timeout"`
+	// Method метод запроса: GET (по умолчанию) или POST.
+	Method string `json:"method"`
+	// Body тело запроса POST.
+	Body string `json:"body"`
+	// ContentType тип содержимого тела запроса.
+	ContentType string `json:"content_type"`
+	// ExpectedStatus ожидаемые коды ответа. По умолчанию любой код 2xx.
+	ExpectedStatus []int `json:"expected_status"`
+	// BodyRegex регулярное выражение, которому должно соответствовать тело ответа.
+	BodyRegex string `json:"body_regex"`
+	// MinTLSExpiryDays минимальное количество дней до окончания действия сертификата цели.
+	// По умолчанию 0 - не проверяется.
+	MinTLSExpiryDays uint `json:"min_tls_expiry_days"`
+	// InsecureSkipVerify не проверять сертификат цели.
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	// NoFollowRedirects не выполнять перенаправления, код ответа перенаправления проверяется как есть.
+	NoFollowRedirects bool `json:"no_follow_redirects"`
+}
+
+// ProbeOptions параметры сборщика Probe.
+type ProbeOptions struct {
+	// Targets цели, которые проверяются при каждом опросе.
+	Targets []ProbeTarget `json:"targets"`
+}
+
+// probeTarget цель с разобранными параметрами.
+type probeTarget struct {
+	ProbeTarget
+	bodyRegex *regexp.Regexp
+	client    *http.Client
+}
+
+// probeResult результат проверки цели.
+type probeResult struct {
+	duration   time.Duration
+	statusCode int
+	// количество дней до окончания действия сертификата, если цель использует TLS
+	tlsExpiryDays *float64
+	err           error
+}
+
+// Probe сборщик результатов проверки доступности сервисов по HTTP(S) и TCP. Все цели проверяются параллельно.
+//
+// Для каждой цели передаются метрики типа Gauge: ProbeSuccess - 1, если проверка прошла успешно, и 0
+// в противном случае, ProbeDuration - длительность проверки в секундах. Для проверок http также
+// передается ProbeStatusCode - код ответа (0, если ответ не получен), а для целей с TLS -
+// ProbeTLSExpiryDays - количество дней до окончания действия ближайшего к истечению сертификата цели.
+// Имя цели добавляется к имени метрики, например, ProbeSuccess.api. Причины неудачных проверок
+// записываются в лог.
+type Probe struct {
+	Options ProbeOptions
+
+	targets []probeTarget
+	logger  Logger
+	now     func() time.Time
+}
+
+// NewProbe возвращает сборщик Probe с параметрами options в формате JSON.
+func NewProbe(options json.RawMessage) (Collector, error) {
+	c := &Probe{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetLogger устанавливает логер для вывода причин неудачных проверок.
+func (c *Probe) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// Init инициализирует сборщика.
+func (c *Probe) Init() error {
+	if c.logger == nil {
+		c.logger = &logger.Blackhole{}
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+	c.targets = make([]probeTarget, 0, len(c.Options.Targets))
+	for _, t := range c.Options.Targets {
+		target, err := newProbeTarget(t)
+		if err != nil {
+			return fmt.Errorf("%q: %w", t.Name, err)
+		}
+		c.targets = append(c.targets, target)
+	}
+	return nil
+}
+
+func newProbeTarget(t ProbeTarget) (probeTarget, error) {
+	target := probeTarget{ProbeTarget: t}
+	if len(t.Name) == 0 {
+		return target, ErrInvalidProbeTarget
+	}
+	if len(t.Type) == 0 {
+		target.Type = ProbeHTTP
+	}
+	switch target.Type {
+	case ProbeTCP:
+		if len(t.Address) == 0 {
+			return target, fmt.Errorf("address is required: %w", ErrInvalidProbeTarget)
+		}
+		return target, nil
+	case ProbeHTTP:
+	default:
+		return target, fmt.Errorf("unknown type %q: %w", t.Type, ErrInvalidProbeTarget)
+	}
+	target.Method = strings.ToUpper(t.Method)
+	switch target.Method {
+	case "":
+		target.Method = http.MethodGet
+	case http.MethodGet, http.MethodPost:
+	default:
+		return target, fmt.Errorf("unsupported method %q: %w", t.Method, ErrInvalidProbeTarget)
+	}
+	if len(t.URL) == 0 {
+		return target, fmt.Errorf("url is required: %w", ErrInvalidProbeTarget)
+	}
+	if _, err := http.NewRequest(target.Method, t.URL, nil); err != nil {
+		return target, err
+	}
+	if len(t.BodyRegex) > 0 {
+		re, err := regexp.Compile(t.BodyRegex)
+		if err != nil {
+			return target, err
+		}
+		target.bodyRegex = re
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// соединение устанавливается при каждой проверке заново, чтобы учитывать его в длительности
+	transport.DisableKeepAlives = true
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	target.client = &http.Client{Transport: transport}
+	if t.NoFollowRedirects {
+		target.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return target, nil
+}
+
+// Collect возвращает метрики, собранные сборщиком. Неудачные проверки не приводят к ошибке опроса.
+func (c *Probe) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	results := make([]probeResult, len(c.targets))
+	wg := sync.WaitGroup{}
+	for i := range c.targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.probe(ctx, c.targets[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, t := range c.targets {
+		r := results[i]
+		success := 1.0
+		if r.err != nil {
+			c.logger.Warnf("probe %s: %s", t.Name, r.err)
+			success = 0
+		}
+		metrics.Gauges = append(metrics.Gauges,
+			metric.NewGauge(metricName("ProbeSuccess", t.Name), success),
+			metric.NewGauge(metricName("ProbeDuration", t.Name), r.duration.Seconds()),
+		)
+		if t.Type == ProbeHTTP {
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge(metricName("ProbeStatusCode", t.Name), float64(r.statusCode)))
+		}
+		if r.tlsExpiryDays != nil {
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge(metricName("ProbeTLSExpiryDays", t.Name), *r.tlsExpiryDays))
+		}
+	}
+	return *metrics, nil
+}
+
+// probe проверяет цель t.
+func (c *Probe) probe(ctx context.Context, t probeTarget) probeResult {
+	timeout := DefaultProbeTimeout
+	if t.TimeoutInSec > 0 {
+		timeout = time.Duration(t.TimeoutInSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var r probeResult
+	if t.Type == ProbeTCP {
+		r = c.probeTCP(ctx, t)
+	} else {
+		r = c.probeHTTP(ctx, t)
+	}
+	r.duration = time.Since(start)
+	return r
+}
+
+// probeTCP проверяет, что к цели t можно установить TCP соединение.
+func (c *Probe) probeTCP(ctx context.Context, t probeTarget) probeResult {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", t.Address)
+	if err != nil {
+		return probeResult{err: err}
+	}
+	conn.Close()
+	return probeResult{}
+}
+
+// probeHTTP выполняет запрос к цели t и проверяет код ответа, тело ответа и сертификат.
+func (c *Probe) probeHTTP(ctx context.Context, t probeTarget) probeResult {
+	r := probeResult{}
+	var body io.Reader
+	if t.Method == http.MethodPost {
+		body = strings.NewReader(t.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, t.Method, t.URL, body)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if len(t.ContentType) > 0 {
+		req.Header.Set("Content-Type", t.ContentType)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	defer resp.Body.Close()
+	r.statusCode = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		notAfter := resp.TLS.PeerCertificates[0].NotAfter
+		for _, cert := range resp.TLS.PeerCertificates[1:] {
+			if cert.NotAfter.Before(notAfter) {
+				notAfter = cert.NotAfter
+			}
+		}
+		days := notAfter.Sub(c.now()).Hours() / 24
+		r.tlsExpiryDays = &days
+	}
+
+	if !expectedStatus(t.ExpectedStatus, resp.StatusCode) {
+		r.err = fmt.Errorf("%w: unexpected status %s", ErrProbeFailed, resp.Status)
+		return r
+	}
+	if t.bodyRegex != nil {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, ProbeMaxBodySize))
+		if err != nil {
+			r.err = err
+			return r
+		}
+		if !t.bodyRegex.Match(data) {
+			r.err = fmt.Errorf("%w: body does not match %q", ErrProbeFailed, t.BodyRegex)
+			return r
+		}
+	}
+	if t.MinTLSExpiryDays > 0 && r.tlsExpiryDays != nil && *r.tlsExpiryDays < float64(t.MinTLSExpiryDays) {
+		r.err = fmt.Errorf("%w: certificate expires in %.1f days", ErrProbeFailed, *r.tlsExpiryDays)
+	}
+	return r
+}
+
+// expectedStatus возвращает true, если код ответа status входит в expected. Если expected пустой,
+// то ожидается любой код 2xx.
+func expectedStatus(expected []int, status int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range expected {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
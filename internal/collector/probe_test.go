@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProbe(t *testing.T, options string) (*Probe, *testLogger) {
+	t.Helper()
+	c, err := NewProbe(json.RawMessage(options))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	p := c.(*Probe)
+	l := &testLogger{}
+	p.SetLogger(l)
+	if !assert.NoError(t, p.Init()) {
+		t.FailNow()
+	}
+	return p, l
+}
+
+func TestProbeCollectHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"user": "probe"}` || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/healthz", http.StatusMovedPermanently)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	c, l := newTestProbe(t, `{"targets": [
+		{"name": "health", "url": "`+s.URL+`/healthz", "body_regex": "\"status\": \"ok\""},
+		{"name": "login", "url": "`+s.URL+`/login", "method": "post", "body": "{\"user\": \"probe\"}", "content_type": "application/json", "expected_status": [401]},
+		{"name": "redirect", "url": "`+s.URL+`/old", "no_follow_redirects": true, "expected_status": [301]},
+		{"name": "missing", "url": "`+s.URL+`/missing"},
+		{"name": "body", "url": "`+s.URL+`/healthz", "body_regex": "failed"}
+	]}`)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	gauges := gaugeValues(m)
+	assert.Len(t, gauges, 15)
+	for name, want := range map[string]float64{"health": 200, "login": 401, "redirect": 301, "missing": 404, "body": 200} {
+		assert.Equal(t, want, gauges["ProbeStatusCode."+name], name)
+		assert.Greater(t, gauges["ProbeDuration."+name], 0.0, name)
+	}
+	assert.Equal(t, 1.0, gauges["ProbeSuccess.health"])
+	assert.Equal(t, 1.0, gauges["ProbeSuccess.login"])
+	assert.Equal(t, 1.0, gauges["ProbeSuccess.redirect"])
+	assert.Equal(t, 0.0, gauges["ProbeSuccess.missing"])
+	assert.Equal(t, 0.0, gauges["ProbeSuccess.body"])
+	assert.Contains(t, l.String(), "warn: probe missing: probe failed: unexpected status 404 Not Found")
+	assert.Contains(t, l.String(), "warn: probe body: probe failed: body does not match")
+}
+
+func TestProbeCollectTLS(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	cert := s.Certificate()
+
+	c, l := newTestProbe(t, `{"targets": [
+		{"name": "tls", "url": "`+s.URL+`", "insecure_skip_verify": true},
+		{"name": "expiring", "url": "`+s.URL+`", "insecure_skip_verify": true, "min_tls_expiry_days": 100000},
+		{"name": "untrusted", "url": "`+s.URL+`"}
+	]}`)
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	gauges := gaugeValues(m)
+	days := cert.NotAfter.Sub(c.now()).Hours() / 24
+	assert.InDelta(t, days, gauges["ProbeTLSExpiryDays.tls"], 1)
+	assert.Equal(t, 1.0, gauges["ProbeSuccess.tls"])
+	assert.Equal(t, 0.0, gauges["ProbeSuccess.expiring"])
+	assert.Equal(t, 0.0, gauges["ProbeSuccess.untrusted"])
+	assert.Equal(t, 0.0, gauges["ProbeStatusCode.untrusted"])
+	assert.NotContains(t, gauges, "ProbeTLSExpiryDays.untrusted")
+	assert.Contains(t, l.String(), "warn: probe expiring: probe failed: certificate expires in")
+}
+
+func TestProbeCollectTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	closed.Close()
+
+	c, _ := newTestProbe(t, `{"targets": [
+		{"name": "open", "type": "tcp", "address": "`+l.Addr().String()+`"},
+		{"name": "closed", "type": "tcp", "address": "`+closed.Addr().String()+`"}
+	]}`)
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ProbeSuccess.open", "ProbeDuration.open", "ProbeSuccess.closed", "ProbeDuration.closed"}, gaugeNames(m))
+	gauges := gaugeValues(m)
+	assert.Equal(t, 1.0, gauges["ProbeSuccess.open"])
+	assert.Equal(t, 0.0, gauges["ProbeSuccess.closed"])
+}
+
+func TestProbeInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{name: "Without name", options: `{"targets": [{"url": "http://localhost"}]}`},
+		{name: "Without url", options: `{"targets": [{"name": "api"}]}`},
+		{name: "Without address", options: `{"targets": [{"name": "db", "type": "tcp"}]}`},
+		{name: "Unknown type", options: `{"targets": [{"name": "api", "type": "icmp"}]}`},
+		{name: "Unsupported method", options: `{"targets": [{"name": "api", "url": "http://localhost", "method": "DELETE"}]}`},
+		{name: "Invalid regexp", options: `{"targets": [{"name": "api", "url": "http://localhost", "body_regex": "("}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewProbe(json.RawMessage(tt.options))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Error(t, c.Init())
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	psprocess "github.com/shirou/gopsutil/v3/process"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+var (
+	// ErrInvalidProcessGroup у группы процессов не задано имя или ни одного правила отбора.
+	ErrInvalidProcessGroup = errors.New("invalid process group")
+)
+
+// ProcessGroup правила отбора процессов в группу. Процесс попадает в группу,
+// если он подходит под все заданные правила.
+type ProcessGroup struct {
+	// Name имя группы, которое добавляется к имени метрики.
+	Name string `json:"name"`
+	// Process имя процесса.
+	Process string `json:"process"`
+	// Cmdline регулярное выражение для командной строки процесса.
+	Cmdline string `json:"cmdline"`
+	// Pidfile путь до файла с идентификатором процесса.
+	Pidfile string `json:"pidfile"`
+}
+
+// ProcessOptions параметры сборщика Process.
+type ProcessOptions struct {
+	// Groups группы процессов, по которым собираются метрики.
+	Groups []ProcessGroup `json:"groups"`
+}
+
+// proc процесс, по которому собирается статистика. Реализуется *process.Process из gopsutil.
+type proc interface {
+	NameWithContext(ctx context.Context) (string, error)
+	CmdlineWithContext(ctx context.Context) (string, error)
+	CreateTimeWithContext(ctx context.Context) (int64, error)
+	PercentWithContext(ctx context.Context, interval time.Duration) (float64, error)
+	MemoryInfoWithContext(ctx context.Context) (*psprocess.MemoryInfoStat, error)
+	NumFDsWithContext(ctx context.Context) (int32, error)
+	NumThreadsWithContext(ctx context.Context) (int32, error)
+}
+
+// processGroup группа процессов с разобранными правилами отбора.
+type processGroup struct {
+	ProcessGroup
+	cmdline *regexp.Regexp
+}
+
+// cachedProc процесс, сохраненный между опросами. Загрузка CPU считается между опросами,
+// поэтому процесс нужно сохранять, пока он существует.
+type cachedProc struct {
+	proc       proc
+	createTime int64
+}
+
+// Process сборщик метрик отдельных процессов на основе пакета gopsutil.
+//
+// Для каждой группы процессов собираются метрики типа Gauge: ProcCount - количество процессов в группе,
+// ProcCPUPercent - суммарная загрузка CPU с предыдущего опроса, ProcRSS - суммарный объем резидентной памяти,
+// ProcFDs - количество открытых файловых дескрипторов и ProcThreads - количество потоков.
+// Имя группы добавляется к имени метрики, например, ProcRSS.nginx. Процессы сначала отбираются
+// по pid-файлам, имени и командной строке, и только для отобранных процессов запрашивается
+// время создания и статистика. Если у всех групп задан pid-файл, то список процессов системы не запрашивается.
+type Process struct {
+	Options ProcessOptions
+
+	groups []processGroup
+	procs  map[int32]cachedProc
+
+	// функции получения процессов, по умолчанию используется gopsutil
+	pids    func(ctx context.Context) ([]int32, error)
+	newProc func(ctx context.Context, pid int32) (proc, error)
+}
+
+// NewProcess возвращает сборщик Process с параметрами options в формате JSON.
+func NewProcess(options json.RawMessage) (Collector, error) {
+	c := &Process{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Init инициализирует сборщика.
+func (c *Process) Init() error {
+	c.groups = make([]processGroup, 0, len(c.Options.Groups))
+	for _, g := range c.Options.Groups {
+		if len(g.Name) == 0 || (len(g.Process) == 0 && len(g.Cmdline) == 0 && len(g.Pidfile) == 0) {
+			return fmt.Errorf("%q: %w", g.Name, ErrInvalidProcessGroup)
+		}
+		group := processGroup{ProcessGroup: g}
+		if len(g.Cmdline) > 0 {
+			re, err := regexp.Compile(g.Cmdline)
+			if err != nil {
+				return fmt.Errorf("%q: %w", g.Name, err)
+			}
+			group.cmdline = re
+		}
+		c.groups = append(c.groups, group)
+	}
+	c.procs = make(map[int32]cachedProc)
+	if c.pids == nil {
+		c.pids = psprocess.PidsWithContext
+	}
+	if c.newProc == nil {
+		// NewProcessWithContext проверяет существование процесса и запрашивает время его создания,
+		// что слишком дорого для каждого процесса системы. Завершившийся процесс отсеивается позже,
+		// при запросе времени создания.
+		c.newProc = func(ctx context.Context, pid int32) (proc, error) {
+			return &psprocess.Process{Pid: pid}, nil
+		}
+	}
+	return nil
+}
+
+// processStats суммарная статистика группы процессов.
+type processStats struct {
+	count      int
+	cpuPercent float64
+	rss        uint64
+	fds        int64
+	threads    int64
+}
+
+func (s *processStats) add(other processStats) {
+	s.count += other.count
+	s.cpuPercent += other.cpuPercent
+	s.rss += other.rss
+	s.fds += other.fds
+	s.threads += other.threads
+}
+
+// Collect возвращает метрики, собранные сборщиком.
+func (c *Process) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	// идентификаторы процессов из pid-файлов
+	pidfiles := make(map[string]int32)
+	onlyPidfiles := true
+	for _, g := range c.groups {
+		if len(g.Pidfile) == 0 {
+			onlyPidfiles = false
+			continue
+		}
+		if pid, err := readPidfile(g.Pidfile); err == nil {
+			pidfiles[g.Pidfile] = pid
+		}
+	}
+	var pids []int32
+	if onlyPidfiles {
+		pids = uniquePids(pidfiles)
+	} else {
+		var err error
+		if pids, err = c.pids(ctx); err != nil {
+			return *metrics, err
+		}
+	}
+
+	stats := make([]processStats, len(c.groups))
+	alive := make(map[int32]cachedProc, len(c.procs))
+	for _, pid := range pids {
+		matched := c.match(ctx, pid, pidfiles)
+		if len(matched) == 0 {
+			continue
+		}
+		p, ok := c.proc(ctx, pid)
+		if !ok {
+			// процесс завершился или недоступен
+			continue
+		}
+		alive[pid] = p
+		// статистику процесса запрашиваем один раз, даже если он входит в несколько групп,
+		// так как загрузка CPU считается с предыдущего запроса
+		ps := c.stats(ctx, p.proc)
+		for _, i := range matched {
+			stats[i].add(ps)
+		}
+	}
+	// завершившиеся процессы забываем
+	c.procs = alive
+
+	for i, g := range c.groups {
+		s := stats[i]
+		metrics.Gauges = append(metrics.Gauges,
+			metric.NewGauge(metricName("ProcCount", g.Name), float64(s.count)),
+			metric.NewGauge(metricName("ProcCPUPercent", g.Name), s.cpuPercent),
+			metric.NewGauge(metricName("ProcRSS", g.Name), float64(s.rss)),
+			metric.NewGauge(metricName("ProcFDs", g.Name), float64(s.fds)),
+			metric.NewGauge(metricName("ProcThreads", g.Name), float64(s.threads)),
+		)
+	}
+	return *metrics, nil
+}
+
+// stats возвращает статистику процесса p.
+func (c *Process) stats(ctx context.Context, p proc) processStats {
+	s := processStats{count: 1}
+	if v, err := p.PercentWithContext(ctx, 0); err == nil {
+		s.cpuPercent = v
+	}
+	if v, err := p.MemoryInfoWithContext(ctx); err == nil {
+		s.rss = v.RSS
+	}
+	if v, err := p.NumFDsWithContext(ctx); err == nil {
+		s.fds = int64(v)
+	}
+	if v, err := p.NumThreadsWithContext(ctx); err == nil {
+		s.threads = int64(v)
+	}
+	return s
+}
+
+// proc возвращает процесс pid. Если процесс уже был в предыдущем опросе, то возвращается сохраненный процесс.
+// Процесс с тем же идентификатором, но другим временем создания считается новым.
+func (c *Process) proc(ctx context.Context, pid int32) (cachedProc, bool) {
+	p, err := c.newProc(ctx, pid)
+	if err != nil {
+		return cachedProc{}, false
+	}
+	createTime, err := p.CreateTimeWithContext(ctx)
+	if err != nil {
+		return cachedProc{}, false
+	}
+	if cached, ok := c.procs[pid]; ok && cached.createTime == createTime {
+		return cached, true
+	}
+	return cachedProc{proc: p, createTime: createTime}, true
+}
+
+// match возвращает индексы групп, в которые входит процесс pid. Сначала проверяются pid-файлы,
+// а имя и командная строка процесса запрашиваются, только если это нужно для проверки групп.
+func (c *Process) match(ctx context.Context, pid int32, pidfiles map[string]int32) []int {
+	var (
+		matched       []int
+		p             proc
+		name, cmdline *string
+	)
+	for i, g := range c.groups {
+		if len(g.Pidfile) > 0 {
+			if filePid, ok := pidfiles[g.Pidfile]; !ok || filePid != pid {
+				continue
+			}
+		}
+		if p == nil && (len(g.Process) > 0 || g.cmdline != nil) {
+			var err error
+			if p, err = c.newProc(ctx, pid); err != nil {
+				return nil
+			}
+		}
+		if len(g.Process) > 0 {
+			if name == nil {
+				v, _ := p.NameWithContext(ctx)
+				name = &v
+			}
+			if *name != g.Process {
+				continue
+			}
+		}
+		if g.cmdline != nil {
+			if cmdline == nil {
+				v, _ := p.CmdlineWithContext(ctx)
+				cmdline = &v
+			}
+			if !g.cmdline.MatchString(*cmdline) {
+				continue
+			}
+		}
+		matched = append(matched, i)
+	}
+	return matched
+}
+
+// uniquePids возвращает идентификаторы процессов из pid-файлов без повторов.
+func uniquePids(pidfiles map[string]int32) []int32 {
+	seen := make(map[int32]bool, len(pidfiles))
+	pids := make([]int32, 0, len(pidfiles))
+	for _, pid := range pidfiles {
+		if !seen[pid] {
+			seen[pid] = true
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// readPidfile возвращает идентификатор процесса из pid-файла path.
+func readPidfile(path string) (int32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return int32(pid), nil
+}
@@ -0,0 +1,229 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	psprocess "github.com/shirou/gopsutil/v3/process"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProc struct {
+	name       string
+	cmdline    string
+	createTime int64
+	// количество запросов загрузки CPU
+	percentCalls int
+}
+
+func (p *fakeProc) NameWithContext(ctx context.Context) (string, error) {
+	return p.name, nil
+}
+
+func (p *fakeProc) CmdlineWithContext(ctx context.Context) (string, error) {
+	return p.cmdline, nil
+}
+
+func (p *fakeProc) CreateTimeWithContext(ctx context.Context) (int64, error) {
+	return p.createTime, nil
+}
+
+func (p *fakeProc) PercentWithContext(ctx context.Context, interval time.Duration) (float64, error) {
+	p.percentCalls++
+	return 10, nil
+}
+
+func (p *fakeProc) MemoryInfoWithContext(ctx context.Context) (*psprocess.MemoryInfoStat, error) {
+	return &psprocess.MemoryInfoStat{RSS: 1024}, nil
+}
+
+func (p *fakeProc) NumFDsWithContext(ctx context.Context) (int32, error) {
+	return 5, nil
+}
+
+func (p *fakeProc) NumThreadsWithContext(ctx context.Context) (int32, error) {
+	return 2, nil
+}
+
+func newTestProcess(t *testing.T, options string, procs map[int32]*fakeProc) *Process {
+	t.Helper()
+	c, err := NewProcess(json.RawMessage(options))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	p := c.(*Process)
+	p.pids = func(ctx context.Context) ([]int32, error) {
+		pids := []int32{}
+		for pid := range procs {
+			pids = append(pids, pid)
+		}
+		return pids, nil
+	}
+	p.newProc = func(ctx context.Context, pid int32) (proc, error) {
+		if p, ok := procs[pid]; ok {
+			// каждый раз новый процесс, как в gopsutil
+			return &fakeProc{name: p.name, cmdline: p.cmdline, createTime: p.createTime}, nil
+		}
+		return nil, errors.New("process not found")
+	}
+	if !assert.NoError(t, p.Init()) {
+		t.FailNow()
+	}
+	return p
+}
+
+func TestProcessCollect(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "app.pid")
+	os.WriteFile(pidfile, []byte("30\n"), 0600)
+	procs := map[int32]*fakeProc{
+		10: {name: "nginx", cmdline: "nginx: master process", createTime: 1},
+		11: {name: "nginx", cmdline: "nginx: worker process", createTime: 1},
+		20: {name: "java", cmdline: "java -jar /opt/app/service.jar", createTime: 1},
+		30: {name: "app", cmdline: "/usr/bin/app", createTime: 1},
+	}
+	c := newTestProcess(t, `{"groups": [
+		{"name": "nginx", "process": "nginx"},
+		{"name": "nginx-workers", "process": "nginx", "cmdline": "worker"},
+		{"name": "service", "cmdline": "service\\.jar"},
+		{"name": "app", "pidfile": "`+pidfile+`"},
+		{"name": "missing", "process": "redis"}
+	]}`, procs)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	gauges := gaugeValues(m)
+	assert.Len(t, gauges, 25)
+	assert.Equal(t, 2.0, gauges["ProcCount.nginx"])
+	assert.Equal(t, 2048.0, gauges["ProcRSS.nginx"])
+	assert.Equal(t, 10.0, gauges["ProcFDs.nginx"])
+	assert.Equal(t, 4.0, gauges["ProcThreads.nginx"])
+	assert.Equal(t, 20.0, gauges["ProcCPUPercent.nginx"])
+	assert.Equal(t, 1.0, gauges["ProcCount.nginx-workers"])
+	assert.Equal(t, 1.0, gauges["ProcCount.service"])
+	assert.Equal(t, 1.0, gauges["ProcCount.app"])
+	assert.Equal(t, 0.0, gauges["ProcCount.missing"])
+}
+
+func TestProcessCache(t *testing.T) {
+	procs := map[int32]*fakeProc{
+		10: {name: "nginx", createTime: 1},
+	}
+	c := newTestProcess(t, `{"groups": [{"name": "a", "process": "nginx"}, {"name": "b", "process": "nginx"}]}`, procs)
+
+	c.Collect(context.TODO())
+	first := c.procs[10].proc.(*fakeProc)
+	// процесс входит в две группы, но загрузка CPU запрашивается один раз
+	assert.Equal(t, 1, first.percentCalls)
+
+	c.Collect(context.TODO())
+	assert.Same(t, first, c.procs[10].proc)
+
+	// идентификатор переиспользован другим процессом
+	procs[10].createTime = 2
+	c.Collect(context.TODO())
+	assert.NotSame(t, first, c.procs[10].proc)
+
+	// завершившийся процесс забывается
+	delete(procs, 10)
+	m, _ := c.Collect(context.TODO())
+	assert.Empty(t, c.procs)
+	assert.Equal(t, 0.0, gaugeValues(m)["ProcCount.a"])
+}
+
+// createTimeProc запоминает процессы, у которых запрашивалось время создания.
+type createTimeProc struct {
+	proc
+	pid     int32
+	queried *[]int32
+}
+
+func (p createTimeProc) CreateTimeWithContext(ctx context.Context) (int64, error) {
+	*p.queried = append(*p.queried, p.pid)
+	return p.proc.CreateTimeWithContext(ctx)
+}
+
+func TestProcessFilter(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "app.pid")
+	os.WriteFile(pidfile, []byte("30\n"), 0600)
+	procs := map[int32]*fakeProc{
+		10: {name: "nginx", createTime: 1},
+		20: {name: "java", createTime: 1},
+		30: {name: "app", createTime: 1},
+	}
+	tests := []struct {
+		name       string
+		groups     string
+		wantListed bool
+		want       []int32
+	}{
+		{name: "By name", groups: `[{"name": "nginx", "process": "nginx"}]`, wantListed: true, want: []int32{10}},
+		{name: "By pidfile", groups: `[{"name": "app", "pidfile": "` + pidfile + `"}]`, wantListed: false, want: []int32{30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestProcess(t, `{"groups": `+tt.groups+`}`, procs)
+			listed := false
+			pids := c.pids
+			c.pids = func(ctx context.Context) ([]int32, error) {
+				listed = true
+				return pids(ctx)
+			}
+			queried := []int32{}
+			newProc := c.newProc
+			c.newProc = func(ctx context.Context, pid int32) (proc, error) {
+				p, err := newProc(ctx, pid)
+				if err != nil {
+					return nil, err
+				}
+				return createTimeProc{proc: p, pid: pid, queried: &queried}, nil
+			}
+
+			_, err := c.Collect(context.TODO())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantListed, listed)
+			// время создания запрашивается только у отобранных процессов
+			assert.Equal(t, tt.want, queried)
+		})
+	}
+}
+
+func TestProcessInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{name: "Without name", options: `{"groups": [{"process": "nginx"}]}`},
+		{name: "Without rules", options: `{"groups": [{"name": "nginx"}]}`},
+		{name: "Invalid regexp", options: `{"groups": [{"name": "nginx", "cmdline": "("}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewProcess(json.RawMessage(tt.options))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Error(t, c.Init())
+		})
+	}
+}
+
+func TestProcessCollectSelf(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "self.pid")
+	os.WriteFile(pidfile, []byte(strconv.Itoa(os.Getpid())), 0600)
+	c := &Process{Options: ProcessOptions{Groups: []ProcessGroup{{Name: "self", Pidfile: pidfile}}}}
+	if !assert.NoError(t, c.Init()) {
+		return
+	}
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	gauges := gaugeValues(m)
+	assert.Equal(t, 1.0, gauges["ProcCount.self"])
+	assert.Greater(t, gauges["ProcRSS.self"], 0.0)
+	assert.Greater(t, gauges["ProcThreads.self"], 0.0)
+}
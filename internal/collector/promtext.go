@@ -0,0 +1,204 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+// Типы метрик в текстовом формате Prometheus.
+const (
+	promCounter = "counter"
+	promGauge   = "gauge"
+	promUntyped = "untyped"
+)
+
+var (
+	// ErrInvalidPromLine строка не соответствует текстовому формату Prometheus.
+	ErrInvalidPromLine = errors.New("invalid prometheus text line")
+)
+
+// promLabel метка значения метрики Prometheus.
+type promLabel struct {
+	name  string
+	value string
+}
+
+// promSample значение метрики Prometheus.
+type promSample struct {
+	name string
+	// тип семейства метрики из комментария TYPE
+	kind string
+	// метки, упорядоченные по имени
+	labels []promLabel
+	value  float64
+}
+
+// metricName возвращает имя метрики с метками в виде name.label1_value1.label2_value2.
+// Метки упорядочены по имени, поэтому имя не зависит от порядка меток в ответе.
+func (s promSample) metricName() string {
+	labels := make([]string, 0, len(s.labels))
+	for _, l := range s.labels {
+		labels = append(labels, l.name+"_"+l.value)
+	}
+	return metricName(sanitizeLabel(s.name), labels...)
+}
+
+// parsePromText разбирает метрики в текстовом формате Prometheus. Тип метрики берется
+// из комментария TYPE, метрики без него считаются untyped. Временные метки значений игнорируются.
+func parsePromText(r io.Reader) ([]promSample, error) {
+	types := make(map[string]string)
+	samples := make([]promSample, 0)
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == '#' {
+			// # TYPE name type
+			fields := strings.Fields(line[1:])
+			if len(fields) == 3 && fields[0] == "TYPE" {
+				types[fields[1]] = fields[2]
+			}
+			continue
+		}
+		s, err := parsePromSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		s.kind = promFamilyType(types, s.name)
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// promFamilyType возвращает тип семейства, к которому относится метрика name. Значения гистограмм
+// и сводок имеют суффиксы _bucket, _sum и _count к имени семейства.
+func promFamilyType(types map[string]string, name string) string {
+	if t, ok := types[name]; ok {
+		return t
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if family, found := strings.CutSuffix(name, suffix); found {
+			if t, ok := types[family]; ok {
+				return t
+			}
+		}
+	}
+	return promUntyped
+}
+
+// parsePromSample разбирает строку со значением метрики в формате name{label="value",...} value [timestamp].
+func parsePromSample(line string) (promSample, error) {
+	s := promSample{}
+	i := strings.IndexAny(line, "{ \t")
+	if i <= 0 {
+		return s, ErrInvalidPromLine
+	}
+	s.name = line[:i]
+	rest := line[i:]
+	if rest[0] == '{' {
+		var err error
+		if s.labels, rest, err = parsePromLabels(rest[1:]); err != nil {
+			return s, err
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, ErrInvalidPromLine
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("%w: %v", ErrInvalidPromLine, err)
+	}
+	s.value = value
+	sort.Slice(s.labels, func(i, j int) bool { return s.labels[i].name < s.labels[j].name })
+	return s, nil
+}
+
+// parsePromLabels разбирает метки до закрывающей фигурной скобки и возвращает остаток строки.
+func parsePromLabels(s string) ([]promLabel, string, error) {
+	labels := make([]promLabel, 0)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if len(s) == 0 {
+			return nil, "", ErrInvalidPromLine
+		}
+		if s[0] == '}' {
+			return labels, s[1:], nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			return nil, "", ErrInvalidPromLine
+		}
+		name := strings.TrimSpace(s[:eq])
+		value := strings.Builder{}
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] != '\\' || i+1 == len(s) {
+				value.WriteByte(s[i])
+				continue
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				value.WriteByte(s[i])
+			}
+		}
+		if i == len(s) {
+			return nil, "", ErrInvalidPromLine
+		}
+		labels = append(labels, promLabel{name: name, value: value.String()})
+		s = s[i+1:]
+	}
+}
+
+// appendPromSamples добавляет в metrics метрики Prometheus. Счетчики Prometheus накопительные,
+// поэтому передаются как Counter с приращением с предыдущего опроса, которое вычисляется в deltas
+// с префиксом ключа key. Метрики типа gauge и untyped передаются как Gauge. Гистограммы и сводки,
+// а также значения NaN и бесконечность пропускаются.
+func appendPromSamples(metrics *metric.Metrics, samples []promSample, deltas *counterDeltas, key string) {
+	for _, s := range samples {
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		name := s.metricName()
+		switch s.kind {
+		case promCounter:
+			if s.value < 0 {
+				continue
+			}
+			if delta, ok := deltas.delta(key+name, uint64(s.value)); ok {
+				metrics.Counters = append(metrics.Counters, metric.NewCounter(name, delta))
+			}
+		case promGauge, promUntyped:
+			metrics.Gauges = append(metrics.Gauges, metric.NewGauge(name, s.value))
+		}
+	}
+}
+
+// appendPrefixed добавляет в metrics метрики source, к именам которых добавлен префикс prefix,
+// например, prefix.requests_total.
+func appendPrefixed(metrics *metric.Metrics, source *metric.Metrics, prefix string) {
+	for _, m := range source.Counters {
+		metrics.Counters = append(metrics.Counters, metric.NewCounter(metricName(prefix, m.Name), m.Value))
+	}
+	for _, m := range source.Gauges {
+		metrics.Gauges = append(metrics.Gauges, metric.NewGauge(metricName(prefix, m.Name), m.Value))
+	}
+}
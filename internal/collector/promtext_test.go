@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+func TestParsePromText(t *testing.T) {
+	text := `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{path="/api",code="200"} 1027 1395066363000
+requests_total{code="500", path="/api",} 3
+# TYPE temperature gauge
+temperature 21.5
+# TYPE latency histogram
+latency_bucket{le="0.1"} 10
+latency_bucket{le="+Inf"} 12
+latency_sum 1.5
+latency_count 12
+build_info{version="1.0 \"beta\""} 1
+idle NaN
+`
+	samples, err := parsePromText(strings.NewReader(text))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, samples, 9)
+	assert.Equal(t, promSample{
+		name:   "requests_total",
+		kind:   promCounter,
+		labels: []promLabel{{"code", "200"}, {"path", "/api"}},
+		value:  1027,
+	}, samples[0])
+	assert.Equal(t, "requests_total.code_500.path__api", samples[1].metricName())
+	assert.Equal(t, "histogram", samples[3].kind)
+	assert.Equal(t, "histogram", samples[5].kind)
+	assert.Equal(t, `build_info.version_1.0__beta_`, samples[7].metricName())
+	assert.Equal(t, promUntyped, samples[7].kind)
+
+	m := metric.NewMetrics()
+	deltas := newCounterDeltas()
+	appendPromSamples(m, samples, deltas, "")
+	assert.Empty(t, m.Counters)
+	assert.ElementsMatch(t, []string{"temperature", "build_info.version_1.0__beta_"}, gaugeNames(*m))
+
+	samples[0].value = 1030
+	m = metric.NewMetrics()
+	appendPromSamples(m, samples, deltas, "")
+	assert.Equal(t, map[string]int64{"requests_total.code_200.path__api": 3, "requests_total.code_500.path__api": 0}, counterValues(*m))
+}
+
+func TestParsePromTextInvalid(t *testing.T) {
+	for _, line := range []string{
+		"requests_total",
+		"{code=\"200\"} 1",
+		"requests_total{code=200} 1",
+		"requests_total{code=\"200\" 1",
+		"requests_total one",
+		"requests_total 1 2 3",
+	} {
+		t.Run(line, func(t *testing.T) {
+			_, err := parsePromText(strings.NewReader(line))
+			assert.ErrorIs(t, err, ErrInvalidPromLine)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+// Имена встроенных сборщиков в реестре.
+const (
+	NamePollCount      = "poll_count"
+	NameRandom         = "random"
+	NameRuntime        = "runtime"
+	NameGops           = "gops"
+	NameDisk           = "disk"
+	NameNet            = "net"
+	NameProcess        = "process"
+	NameCgroup         = "cgroup"
+	NameRuntimeMetrics = "runtime_metrics"
+	NameExec           = "exec"
+	NameScrape         = "scrape"
+	NameProbe          = "probe"
+	NameLogTail        = "log_tail"
+)
+
+var (
+	// ErrUnknownCollector сборщик с указанным именем не зарегистрирован.
+	ErrUnknownCollector = errors.New("unknown collector")
+	// ErrUnavailable сборщик не может работать в этой системе. Сборщик, включенный по умолчанию,
+	// с такой ошибкой инициализации можно пропустить без сообщения об ошибке.
+	ErrUnavailable = errors.New("collector is not available")
+)
+
+// Collector сборщик метрик.
+type Collector interface {
+	Collect(ctx context.Context) (metric.Metrics, error)
+	Init() error
+}
+
+// Logger логер сборщика.
+type Logger interface {
+	Debugf(template string, args ...interface{})
+	Warnf(template string, args ...interface{})
+	Errorf(template string, args ...interface{})
+}
+
+// LoggerSetter сборщик, которому можно передать логер для сообщений, которые не приводят
+// к ошибке опроса всего сборщика.
+type LoggerSetter interface {
+	SetLogger(l Logger)
+}
+
+// Factory создает сборщик с параметрами options в формате JSON.
+// Если параметры не заданы, то options пустой.
+type Factory func(options json.RawMessage) (Collector, error)
+
+// Registration описание сборщика в реестре.
+type Registration struct {
+	// New создает сборщик.
+	New Factory
+	// Enabled сборщик используется, если он не выключен явно в конфигурации.
+	Enabled bool
+}
+
+// Entry сборщик, созданный по конфигурации.
+type Entry struct {
+	// Name имя сборщика в реестре.
+	Name string
+	// Collector сборщик.
+	Collector Collector
+	// Config настройки сборщика.
+	Config config.CollectorConfig
+}
+
+// Registry реестр сборщиков метрик по именам.
+type Registry struct {
+	registrations map[string]Registration
+}
+
+// DefaultRegistry реестр со всеми сборщиками пакета.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	DefaultRegistry.Register(NamePollCount, Registration{New: withoutOptions(func() Collector { return &PollCounter{} }), Enabled: true})
+	DefaultRegistry.Register(NameRandom, Registration{New: withoutOptions(func() Collector { return &Random{} }), Enabled: true})
+	DefaultRegistry.Register(NameRuntime, Registration{New: withoutOptions(func() Collector { return &Runtime{} }), Enabled: true})
+	DefaultRegistry.Register(NameGops, Registration{New: withoutOptions(func() Collector { return &Gops{} }), Enabled: true})
+	DefaultRegistry.Register(NameCgroup, Registration{New: NewCgroup, Enabled: true})
+	// остальные сборщики нужно включать явно
+	DefaultRegistry.Register(NameDisk, Registration{New: NewDisk})
+	DefaultRegistry.Register(NameNet, Registration{New: NewNet})
+	DefaultRegistry.Register(NameProcess, Registration{New: NewProcess})
+	DefaultRegistry.Register(NameRuntimeMetrics, Registration{New: NewRuntimeMetrics})
+	DefaultRegistry.Register(NameExec, Registration{New: NewExec})
+	DefaultRegistry.Register(NameScrape, Registration{New: NewScrape})
+	DefaultRegistry.Register(NameProbe, Registration{New: NewProbe})
+	DefaultRegistry.Register(NameLogTail, Registration{New: NewLogTail})
+}
+
+// NewRegistry возвращает пустой реестр сборщиков.
+func NewRegistry() *Registry {
+	return &Registry{
+		registrations: make(map[string]Registration),
+	}
+}
+
+// Register добавляет сборщик name в реестр. Повторная регистрация заменяет предыдущую.
+func (r *Registry) Register(name string, reg Registration) {
+	r.registrations[name] = reg
+}
+
+// Names возвращает упорядоченный список имен зарегистрированных сборщиков.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.registrations))
+	for name := range r.registrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New создает сборщик name с параметрами options.
+func (r *Registry) New(name string, options json.RawMessage) (Collector, error) {
+	reg, ok := r.registrations[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrUnknownCollector)
+	}
+	c, err := reg.New(options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+// Build создает включенные сборщики по настройкам cfg. Сборщики, которые не указаны в cfg,
+// создаются, если они включены по умолчанию. Сборщики возвращаются в порядке имен.
+// Если в cfg указан незарегистрированный сборщик, то возвращается ошибка ErrUnknownCollector.
+func (r *Registry) Build(cfg map[string]config.CollectorConfig) ([]Entry, error) {
+	for name := range cfg {
+		if _, ok := r.registrations[name]; !ok {
+			return nil, fmt.Errorf("%s: %w", name, ErrUnknownCollector)
+		}
+	}
+	entries := make([]Entry, 0, len(r.registrations))
+	for _, name := range r.Names() {
+		c := cfg[name]
+		if !c.IsEnabled(r.registrations[name].Enabled) {
+			continue
+		}
+		collector, err := r.New(name, c.Options)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Name: name, Collector: collector, Config: c})
+	}
+	return entries, nil
+}
+
+// decodeOptions разбирает параметры сборщика options в v. Неизвестные параметры считаются ошибкой.
+// Пустые параметры оставляют v без изменений.
+func decodeOptions(options json.RawMessage, v any) error {
+	if len(options) == 0 {
+		return nil
+	}
+	d := json.NewDecoder(bytes.NewReader(options))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// withoutOptions возвращает Factory для сборщика, у которого нет параметров.
+func withoutOptions(f func() Collector) Factory {
+	return func(options json.RawMessage) (Collector, error) {
+		if err := decodeOptions(options, &struct{}{}); err != nil {
+			return nil, err
+		}
+		return f(), nil
+	}
+}
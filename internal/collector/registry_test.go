@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/config"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	assert.Equal(t, []string{NameCgroup, NameDisk, NameExec, NameGops, NameLogTail, NameNet, NamePollCount, NameProbe, NameProcess, NameRandom, NameRuntime, NameRuntimeMetrics, NameScrape}, DefaultRegistry.Names())
+	c, err := DefaultRegistry.New(NameGops, nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &Gops{}, c)
+}
+
+func TestRegistryNew(t *testing.T) {
+	r := NewRegistry()
+	r.Register("pc", Registration{New: withoutOptions(func() Collector { return &PollCounter{} })})
+
+	_, err := r.New("unknown", nil)
+	assert.ErrorIs(t, err, ErrUnknownCollector)
+	_, err = r.New("pc", json.RawMessage(`{"unknown": 1}`))
+	assert.Error(t, err)
+	c, err := r.New("pc", json.RawMessage(`{}`))
+	assert.NoError(t, err)
+	assert.IsType(t, &PollCounter{}, c)
+}
+
+func TestRegistryBuild(t *testing.T) {
+	enabled, disabled := true, false
+	r := NewRegistry()
+	r.Register("pc", Registration{New: withoutOptions(func() Collector { return &PollCounter{} }), Enabled: true})
+	r.Register("random", Registration{New: withoutOptions(func() Collector { return &Random{} }), Enabled: true})
+	r.Register("optional", Registration{New: withoutOptions(func() Collector { return &Gops{} })})
+
+	tests := []struct {
+		name    string
+		cfg     map[string]config.CollectorConfig
+		want    []string
+		wantErr bool
+	}{
+		{name: "Defaults", cfg: nil, want: []string{"pc", "random"}},
+		{
+			name: "Enable and disable",
+			cfg: map[string]config.CollectorConfig{
+				"optional": {Enabled: &enabled},
+				"random":   {Enabled: &disabled},
+			},
+			want: []string{"optional", "pc"},
+		},
+		{
+			name:    "Unknown collector",
+			cfg:     map[string]config.CollectorConfig{"unknown": {}},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid options",
+			cfg:     map[string]config.CollectorConfig{"pc": {Options: json.RawMessage(`{"a":1}`)}},
+			wantErr: true,
+		},
+		{
+			name: "Invalid options of disabled collector",
+			cfg:  map[string]config.CollectorConfig{"optional": {Options: json.RawMessage(`{"a":1}`)}},
+			want: []string{"pc", "random"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := r.Build(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			names := make([]string, 0, len(entries))
+			for _, e := range entries {
+				names = append(names, e.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"runtime/metrics"
+	"strings"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+// runtimeQuantiles квантили, которые вычисляются по гистограммам runtime/metrics.
+var runtimeQuantiles = []struct {
+	name string
+	q    float64
+}{
+	{"p50", 0.5}, {"p90", 0.9}, {"p99", 0.99},
+}
+
+// RuntimeMetricsOptions параметры сборщика RuntimeMetrics.
+type RuntimeMetricsOptions struct {
+	// Names имена метрик runtime/metrics, которые собираются, в виде шаблонов в формате path.Match,
+	// например, "/gc/pauses:seconds" или "/sched/*". По умолчанию собираются все метрики.
+	Names []string `json:"names"`
+}
+
+// RuntimeMetrics сборщик метрик на основе стандартного пакета runtime/metrics. В отличие от Runtime
+// не останавливает выполнение программы и передает все метрики, которые поддерживает среда выполнения.
+//
+// Имя метрики формируется из имени в runtime/metrics с префиксом GoRuntime, например,
+// /gc/heap/goal:bytes передается как GoRuntime.gc_heap_goal_bytes. Накопительные целочисленные метрики
+// передаются как Counter с приращением с предыдущего опроса, остальные - как Gauge.
+// По гистограммам (например, /gc/pauses:seconds или /sched/latencies:seconds) передаются метрики
+// типа Gauge с суффиксами count, p50, p90, p99 и max, которые считаются по значениям,
+// добавленным в гистограмму с предыдущего опроса. Квантили и максимум оцениваются по верхним
+// границам интервалов гистограммы.
+type RuntimeMetrics struct {
+	Options RuntimeMetricsOptions
+
+	samples []metrics.Sample
+	// накопительные метрики по именам в runtime/metrics
+	cumulative map[string]bool
+	deltas     *counterDeltas
+	// количество значений в интервалах гистограмм на предыдущем опросе
+	histograms map[string][]uint64
+}
+
+// NewRuntimeMetrics возвращает сборщик RuntimeMetrics с параметрами options в формате JSON.
+func NewRuntimeMetrics(options json.RawMessage) (Collector, error) {
+	c := &RuntimeMetrics{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Init инициализирует сборщика.
+func (c *RuntimeMetrics) Init() error {
+	names, err := newFilter(c.Options.Names, nil)
+	if err != nil {
+		return err
+	}
+	c.samples = c.samples[:0]
+	c.cumulative = make(map[string]bool)
+	for _, d := range metrics.All() {
+		if !names.Match(d.Name) {
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+		c.cumulative[d.Name] = d.Cumulative
+	}
+	c.deltas = newCounterDeltas()
+	c.histograms = make(map[string][]uint64)
+	return nil
+}
+
+// Collect возвращает метрики, собранные сборщиком.
+func (c *RuntimeMetrics) Collect(ctx context.Context) (metric.Metrics, error) {
+	result := metric.NewMetrics()
+	metrics.Read(c.samples)
+	for _, s := range c.samples {
+		name := runtimeMetricName(s.Name)
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			if c.cumulative[s.Name] {
+				if delta, ok := c.deltas.delta(name, s.Value.Uint64()); ok {
+					result.Counters = append(result.Counters, metric.NewCounter(name, delta))
+				}
+				continue
+			}
+			result.Gauges = append(result.Gauges, metric.NewGauge(name, float64(s.Value.Uint64())))
+		case metrics.KindFloat64:
+			result.Gauges = append(result.Gauges, metric.NewGauge(name, s.Value.Float64()))
+		case metrics.KindFloat64Histogram:
+			result.Gauges = append(result.Gauges, c.histogram(s.Name, name, s.Value.Float64Histogram())...)
+		}
+	}
+	return *result, nil
+}
+
+// histogram возвращает метрики гистограммы h с именем name по значениям, добавленным
+// с предыдущего опроса. Гистограммы в runtime/metrics накопительные.
+func (c *RuntimeMetrics) histogram(key string, name string, h *metrics.Float64Histogram) []*metric.Gauge {
+	counts := make([]uint64, len(h.Counts))
+	prev := c.histograms[key]
+	var total uint64
+	for i, v := range h.Counts {
+		counts[i] = v
+		if len(prev) == len(h.Counts) && v >= prev[i] {
+			counts[i] = v - prev[i]
+		}
+		total += counts[i]
+	}
+	c.histograms[key] = append(prev[:0], h.Counts...)
+
+	gauges := []*metric.Gauge{metric.NewGauge(metricName(name, "count"), float64(total))}
+	for _, q := range runtimeQuantiles {
+		gauges = append(gauges, metric.NewGauge(metricName(name, q.name), histogramQuantile(counts, h.Buckets, total, q.q)))
+	}
+	return append(gauges, metric.NewGauge(metricName(name, "max"), histogramQuantile(counts, h.Buckets, total, 1)))
+}
+
+// histogramQuantile возвращает оценку квантиля q по гистограмме с количеством значений counts
+// в интервалах buckets. Если значений нет, то возвращается 0.
+func histogramQuantile(counts []uint64, buckets []float64, total uint64, q float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, v := range counts {
+		seen += v
+		if v == 0 || seen < rank {
+			continue
+		}
+		// верхняя граница последнего интервала может быть бесконечной, тогда берется нижняя
+		if upper := buckets[i+1]; !math.IsInf(upper, 0) {
+			return upper
+		}
+		if lower := buckets[i]; !math.IsInf(lower, 0) {
+			return lower
+		}
+		return 0
+	}
+	return 0
+}
+
+// runtimeMetricName возвращает имя метрики для метрики runtime/metrics с именем name.
+func runtimeMetricName(name string) string {
+	return metricName("GoRuntime", strings.TrimPrefix(name, "/"))
+}
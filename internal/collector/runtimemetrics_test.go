@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeMetricsCollect(t *testing.T) {
+	c, err := NewRuntimeMetrics(json.RawMessage(`{"names": ["/gc/cycles/total:gc-cycles", "/gc/heap/goal:bytes", "/gc/pauses:seconds"]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, c.Init()) {
+		return
+	}
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	// у накопительного счетчика на первом опросе приращение неизвестно
+	assert.Empty(t, m.Counters)
+	assert.ElementsMatch(t, []string{
+		"GoRuntime.gc_heap_goal_bytes",
+		"GoRuntime.gc_pauses_seconds.count",
+		"GoRuntime.gc_pauses_seconds.p50",
+		"GoRuntime.gc_pauses_seconds.p90",
+		"GoRuntime.gc_pauses_seconds.p99",
+		"GoRuntime.gc_pauses_seconds.max",
+	}, gaugeNames(m))
+
+	runtime.GC()
+	m, err = c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, counterValues(m)["GoRuntime.gc_cycles_total_gc-cycles"], int64(1))
+	gauges := gaugeValues(m)
+	assert.GreaterOrEqual(t, gauges["GoRuntime.gc_pauses_seconds.count"], 1.0)
+	assert.Greater(t, gauges["GoRuntime.gc_pauses_seconds.max"], 0.0)
+}
+
+func TestRuntimeMetricsAll(t *testing.T) {
+	c := &RuntimeMetrics{}
+	if !assert.NoError(t, c.Init()) {
+		return
+	}
+	assert.Len(t, c.samples, len(metrics.All()))
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m.Gauges)
+}
+
+func TestRuntimeMetricsHistogram(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Buckets: []float64{math.Inf(-1), 1, 2, 3, 4, math.Inf(1)},
+		Counts:  []uint64{0, 50, 40, 9, 1},
+	}
+	c := &RuntimeMetrics{}
+	if !assert.NoError(t, c.Init()) {
+		return
+	}
+	collect := func() map[string]float64 {
+		values := map[string]float64{}
+		for _, g := range c.histogram("/test/latencies:seconds", "Latencies", h) {
+			values[g.Name] = g.Value
+		}
+		return values
+	}
+	assert.Equal(t, map[string]float64{
+		"Latencies.count": 100,
+		"Latencies.p50":   2,
+		"Latencies.p90":   3,
+		"Latencies.p99":   4,
+		// верхняя граница последнего интервала бесконечна
+		"Latencies.max": 4,
+	}, collect())
+
+	// учитываются только новые значения
+	h.Counts = []uint64{0, 50, 40, 9, 3}
+	values := collect()
+	assert.Equal(t, 2.0, values["Latencies.count"])
+	assert.Equal(t, 4.0, values["Latencies.p50"])
+
+	// без новых значений
+	values = collect()
+	assert.Equal(t, 0.0, values["Latencies.count"])
+	assert.Equal(t, 0.0, values["Latencies.max"])
+}
+
+func TestRuntimeMetricsInit(t *testing.T) {
+	c, err := NewRuntimeMetrics(json.RawMessage(`{"names": ["["]}`))
+	assert.NoError(t, err)
+	assert.Error(t, c.Init())
+}
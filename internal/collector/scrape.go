@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/logger"
+)
+
+const (
+	// DefaultScrapeTimeout таймаут опроса цели по умолчанию.
+	DefaultScrapeTimeout = 10 * time.Second
+	// ScrapeMaxBodySize максимальный размер ответа цели.
+	ScrapeMaxBodySize = 10 << 20
+	// scrapeAccept заголовок Accept запроса к цели, текстовый формат Prometheus.
+	scrapeAccept = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+)
+
+var (
+	// ErrInvalidScrapeTarget у цели не задано имя или адрес.
+	ErrInvalidScrapeTarget = errors.New("invalid scrape target")
+	// ErrScrapeBodyTooLarge ответ цели больше ScrapeMaxBodySize.
+	ErrScrapeBodyTooLarge = errors.New("scrape response body is too large")
+)
+
+// ScrapeTarget цель, метрики которой собирает сборщик Scrape.
+type ScrapeTarget struct {
+	// Name имя цели, которое добавляется к именам метрик.
+	Name string `json:"name"`
+	// URL адрес, по которому цель отдает метрики, например, http://localhost:9100/metrics.
+	URL string `json:"url"`
+	// TimeoutInSec таймаут запроса к цели (в секундах). По умолчанию DefaultScrapeTimeout.
+	TimeoutInSec uint `json:"timeout"`
+	// Username и Password для Basic аутентификации.
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// BearerToken токен, который передается в заголовке Authorization.
+	BearerToken string `json:"bearer_token"`
+}
+
+// ScrapeOptions параметры сборщика Scrape.
+type ScrapeOptions struct {
+	// Targets цели, которые опрашиваются при каждом опросе.
+	Targets []ScrapeTarget `json:"targets"`
+}
+
+// Scrape сборщик метрик приложений, которые отдают их по HTTP в текстовом формате Prometheus.
+// Все цели опрашиваются параллельно.
+//
+// Имя метрики формируется из имени цели, имени метрики Prometheus и ее меток, упорядоченных по имени,
+// например, app.http_requests_total.code_200.method_GET. Метрики типа counter передаются как Counter
+// с приращением с предыдущего опроса, метрики типа gauge и без типа - как Gauge. Гистограммы и сводки
+// пропускаются. Для каждой цели передается метрика ScrapeUp.<name>, равная 1, если метрики
+// получены и разобраны, и 0 в противном случае. Ошибки опроса целей записываются в лог.
+type Scrape struct {
+	Options ScrapeOptions
+
+	client *http.Client
+	deltas *counterDeltas
+	logger Logger
+}
+
+// NewScrape возвращает сборщик Scrape с параметрами options в формате JSON.
+func NewScrape(options json.RawMessage) (Collector, error) {
+	c := &Scrape{}
+	if err := decodeOptions(options, &c.Options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetLogger устанавливает логер для вывода ошибок опроса целей.
+func (c *Scrape) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// Init инициализирует сборщика.
+func (c *Scrape) Init() error {
+	if c.logger == nil {
+		c.logger = &logger.Blackhole{}
+	}
+	for _, t := range c.Options.Targets {
+		if len(t.Name) == 0 || len(t.URL) == 0 {
+			return fmt.Errorf("%q: %w", t.Name, ErrInvalidScrapeTarget)
+		}
+		if _, err := http.NewRequest(http.MethodGet, t.URL, nil); err != nil {
+			return fmt.Errorf("%q: %w", t.Name, err)
+		}
+	}
+	if c.client == nil {
+		c.client = &http.Client{}
+	}
+	c.deltas = newCounterDeltas()
+	return nil
+}
+
+// Collect возвращает метрики, собранные сборщиком. Ошибки опроса отдельных целей записываются в лог
+// и не приводят к ошибке опроса.
+func (c *Scrape) Collect(ctx context.Context) (metric.Metrics, error) {
+	metrics := metric.NewMetrics()
+	samples := make([][]promSample, len(c.Options.Targets))
+	errs := make([]error, len(c.Options.Targets))
+	wg := sync.WaitGroup{}
+	for i := range c.Options.Targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			samples[i], errs[i] = c.scrape(ctx, c.Options.Targets[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, t := range c.Options.Targets {
+		up := 1.0
+		if errs[i] != nil {
+			c.logger.Errorf("scrape %s: %s", t.Name, errs[i])
+			up = 0
+		} else {
+			target := metric.NewMetrics()
+			appendPromSamples(target, samples[i], c.deltas, t.Name+"/")
+			appendPrefixed(metrics, target, sanitizeLabel(t.Name))
+		}
+		metrics.Gauges = append(metrics.Gauges, metric.NewGauge(metricName("ScrapeUp", t.Name), up))
+	}
+	c.deltas.sweep()
+	return *metrics, nil
+}
+
+// scrape запрашивает метрики цели t.
+func (c *Scrape) scrape(ctx context.Context, t ScrapeTarget) ([]promSample, error) {
+	timeout := DefaultScrapeTimeout
+	if t.TimeoutInSec > 0 {
+		timeout = time.Duration(t.TimeoutInSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", scrapeAccept)
+	if len(t.Username) > 0 {
+		req.SetBasicAuth(t.Username, t.Password)
+	}
+	if len(t.BearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+t.BearerToken)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	// читаем на байт больше, чтобы отличить слишком большой ответ от ответа ровно ScrapeMaxBodySize
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ScrapeMaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > ScrapeMaxBodySize {
+		return nil, ErrScrapeBodyTooLarge
+	}
+	return parsePromText(bytes.NewReader(body))
+}
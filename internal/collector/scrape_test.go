@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestScrape(t *testing.T, options string) (*Scrape, *testLogger) {
+	t.Helper()
+	c, err := NewScrape(json.RawMessage(options))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := c.(*Scrape)
+	l := &testLogger{}
+	s.SetLogger(l)
+	if !assert.NoError(t, s.Init()) {
+		t.FailNow()
+	}
+	return s, l
+}
+
+func TestScrapeCollect(t *testing.T) {
+	requests := atomic.Int64{}
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		fmt.Fprintf(w, "# TYPE http_requests_total counter\n")
+		fmt.Fprintf(w, "http_requests_total{method=\"GET\",code=\"200\"} %d\n", 100*n)
+		fmt.Fprintf(w, "# TYPE goroutines gauge\ngoroutines 8\n")
+	}))
+	defer app.Close()
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "up_since 1700000000\n")
+	}))
+	defer auth.Close()
+	bearer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "# TYPE goroutines gauge\ngoroutines 3\n")
+	}))
+	defer bearer.Close()
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html></html>\n")
+	}))
+	defer invalid.Close()
+
+	c, l := newTestScrape(t, `{"targets": [
+		{"name": "app", "url": "`+app.URL+`"},
+		{"name": "auth", "url": "`+auth.URL+`", "username": "user", "password": "secret"},
+		{"name": "bearer", "url": "`+bearer.URL+`", "bearer_token": "token"},
+		{"name": "unauthorized", "url": "`+bearer.URL+`"},
+		{"name": "invalid", "url": "`+invalid.URL+`"}
+	]}`)
+
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, m.Counters)
+	assert.Equal(t, map[string]float64{
+		"app.goroutines":        8,
+		"auth.up_since":         1700000000,
+		"bearer.goroutines":     3,
+		"ScrapeUp.app":          1,
+		"ScrapeUp.auth":         1,
+		"ScrapeUp.bearer":       1,
+		"ScrapeUp.unauthorized": 0,
+		"ScrapeUp.invalid":      0,
+	}, gaugeValues(m))
+	assert.Contains(t, l.String(), "error: scrape unauthorized: unexpected status 401 Unauthorized")
+	assert.Contains(t, l.String(), "error: scrape invalid:")
+
+	m, err = c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"app.http_requests_total.code_200.method_GET": 100}, counterValues(m))
+}
+
+func TestScrapeCounterReset(t *testing.T) {
+	value := atomic.Int64{}
+	value.Store(3e9)
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# TYPE requests_total counter\nrequests_total %d\n", value.Load())
+	}))
+	defer app.Close()
+
+	c, _ := newTestScrape(t, `{"targets": [{"name": "app", "url": "`+app.URL+`"}]}`)
+	c.Collect(context.TODO())
+	// приложение перезапущено, счетчик сброшен
+	value.Store(10)
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"app.requests_total": 10}, counterValues(m))
+}
+
+func TestScrapeCollectTimeout(t *testing.T) {
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	defer close(done)
+
+	c, l := newTestScrape(t, `{"targets": [{"name": "slow", "url": "`+slow.URL+`", "timeout": 1}]}`)
+	start := time.Now()
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 4*time.Second)
+	assert.Equal(t, map[string]float64{"ScrapeUp.slow": 0}, gaugeValues(m))
+	assert.Contains(t, l.String(), "error: scrape slow:")
+}
+
+func TestScrapeBodyTooLarge(t *testing.T) {
+	large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# TYPE goroutines gauge\ngoroutines 8\n")
+		// без проверки размера обрезанный ответ разобрался бы без ошибки
+		w.Write([]byte("# " + strings.Repeat("x", ScrapeMaxBodySize) + "\n"))
+	}))
+	defer large.Close()
+
+	c, l := newTestScrape(t, `{"targets": [{"name": "large", "url": "`+large.URL+`"}]}`)
+	m, err := c.Collect(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"ScrapeUp.large": 0}, gaugeValues(m))
+	assert.Contains(t, l.String(), "error: scrape large: "+ErrScrapeBodyTooLarge.Error())
+}
+
+func TestScrapeInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{name: "Without name", options: `{"targets": [{"url": "http://localhost/metrics"}]}`},
+		{name: "Without url", options: `{"targets": [{"name": "app"}]}`},
+		{name: "Invalid url", options: `{"targets": [{"name": "app", "url": "http://[::1"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewScrape(json.RawMessage(tt.options))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Error(t, c.Init())
+		})
+	}
+}
@@ -0,0 +1,9 @@
+#!/bin/sh
+# значение счетчика читается из файла COUNTER_FILE
+cat <<METRICS
+# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} $(cat "$COUNTER_FILE")
+# TYPE temperature gauge
+temperature{room="server room"} 21.5
+METRICS
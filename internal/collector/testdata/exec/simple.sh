@@ -0,0 +1,5 @@
+#!/bin/sh
+# метрики в формате simple
+echo "counter jobs_done 3"
+echo ""
+echo "gauge queue_size ${QUEUE_SIZE:-0}"
@@ -15,6 +15,10 @@ const (
 	DefaultKeeperStoreIntervalInSec = 300
 	DefaultKeeperAddress            = "localhost:8080"
 	DefaultKeeperLogLevel           = "info"
+	// 10 МБ
+	DefaultKeeperMaxBodySize = 10 << 20
+	// 100 МБ
+	DefaultKeeperMaxDecompressedBodySize = 100 << 20
 )
 
 // Keeper конфигурация сервера сбора метрик.
@@ -37,21 +41,71 @@ type Keeper struct {
 	LogLevel string `env:"LOG_LEVEL" json:"log_level"`
 	// Key секрет для формирования и проверки подписи данных.
 	Key string `env:"KEY" json:"key"`
+	// KeyringPath путь до файла с набором именованных ключей подписи.
+	// Файл перечитывается при изменении без перезапуска сервера.
+	KeyringPath string `env:"KEYRING" json:"keyring"`
+	// StrictAuth строгий режим проверки подписи: запросы на изменение метрик без подписи отклоняются,
+	// а изменение метрики через параметры в пути (/update/<тип>/<имя>/<значение>) запрещено.
+	// Требует заданного ключа подписи. По умолчанию false.
+	StrictAuth bool `env:"STRICT_AUTH" json:"strict_auth"`
+	// TokensPath путь до файла с API токенами. Если указан, то все запросы требуют токен
+	// с соответствующей областью доступа. Файл перечитывается при изменении без перезапуска сервера.
+	TokensPath string `env:"TOKENS_FILE" json:"tokens_file"`
+	// TLSCert путь до файла с сертификатом сервера. Если указан вместе с TLSKey, то сервер работает по HTTPS.
+	TLSCert string `env:"TLS_CERT" json:"tls_cert"`
+	// TLSKey путь до файла с закрытым ключом сертификата сервера.
+	TLSKey string `env:"TLS_KEY" json:"tls_key"`
+	// TLSClientCA путь до файла с сертификатами центров сертификации, которыми должны быть подписаны
+	// сертификаты агентов. Если указан, то агенты обязаны предъявлять сертификат.
+	TLSClientCA string `env:"TLS_CLIENT_CA" json:"tls_client_ca"`
+	// TrustedSubnets подсети в формате CIDR, с адресов которых разрешено изменение метрик.
+	// Если не указаны, то изменение разрешено с любого адреса, кроме запрещенных.
+	TrustedSubnets []string `env:"TRUSTED_SUBNET" envSeparator:"," json:"trusted_subnets"`
+	// DeniedSubnets подсети в формате CIDR, с адресов которых изменение метрик запрещено.
+	// Имеют приоритет над TrustedSubnets.
+	DeniedSubnets []string `env:"DENIED_SUBNET" envSeparator:"," json:"denied_subnets"`
+	// TrustedProxies подсети в формате CIDR доверенных прокси. Адрес клиента берется из заголовка
+	// X-Real-IP, только если запрос пришел с адреса доверенного прокси.
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:"," json:"trusted_proxies"`
+	// RateLimit допустимое количество запросов в секунду от одного агента. Агент определяется
+	// по клиентскому сертификату или по адресу. По умолчанию 0 - без ограничений.
+	RateLimit float64 `env:"RATE_LIMIT_RPS" json:"rate_limit_rps"`
+	// RateBurst допустимое кратковременное превышение количества запросов от одного агента.
+	RateBurst uint `env:"RATE_BURST" json:"rate_burst"`
+	// MaxBodySize максимальный размер тела запроса в байтах в том виде, в котором он передан агентом.
+	// По умолчанию 10 МБ, 0 - без ограничений.
+	MaxBodySize int64 `env:"MAX_BODY_SIZE" json:"max_body_size"`
+	// MaxDecompressedBodySize максимальный размер разжатого тела запроса в байтах.
+	// По умолчанию 100 МБ, 0 - без ограничений.
+	MaxDecompressedBodySize int64 `env:"MAX_DECOMPRESSED_BODY_SIZE" json:"max_decompressed_body_size"`
 	// EnableProfiling доступ к профилировщику. По умолчанию false.
 	EnableProfiling bool `env:"ENABLE_PPROF" json:"enable_pprof"`
 }
 
 // DefaultKeeperConfig конфиг сервера по умолчанию.
 var DefaultKeeperConfig = Keeper{
-	Address:            DefaultKeeperAddress,
-	CryptoKey:          "",
-	StoreIntervalInSec: DefaultKeeperStoreIntervalInSec,
-	FileStoragePath:    "",
-	Restore:            true,
-	DatabaseDSN:        "",
-	LogLevel:           DefaultKeeperLogLevel,
-	Key:                "",
-	EnableProfiling:    false,
+	Address:                 DefaultKeeperAddress,
+	CryptoKey:               "",
+	StoreIntervalInSec:      DefaultKeeperStoreIntervalInSec,
+	FileStoragePath:         "",
+	Restore:                 true,
+	DatabaseDSN:             "",
+	LogLevel:                DefaultKeeperLogLevel,
+	Key:                     "",
+	KeyringPath:             "",
+	StrictAuth:              false,
+	TokensPath:              "",
+	TLSCert:                 "",
+	TLSKey:                  "",
+	TLSClientCA:             "",
+	TrustedSubnets:          nil,
+	DeniedSubnets:           nil,
+	TrustedProxies:          nil,
+	RateLimit:               0,
+	RateBurst:               0,
+	MaxBodySize:             DefaultKeeperMaxBodySize,
+	MaxDecompressedBodySize: DefaultKeeperMaxDecompressedBodySize,
+	EnableProfiling:         false,
 }
 
 // ParseKeeperConfig разбирает настройки Keeper'a из файла конфигурации и/или аргументов командной строки
@@ -70,6 +124,11 @@ func ParseKeeperConfig(c *Keeper, jsonValue []byte) error {
 	return nil
 }
 
+// IsTLSEnabled возвращает true, если сервер должен работать по HTTPS.
+func (cfg Keeper) IsTLSEnabled() bool {
+	return len(cfg.TLSCert) > 0 && len(cfg.TLSKey) > 0
+}
+
 // StorageInterval возвращает интервал сброса метрик из памяти на диск в виде time.Duration.
 func (cfg Keeper) StorageInterval() time.Duration {
 	return time.Duration(cfg.StoreIntervalInSec) * time.Second
@@ -97,6 +156,19 @@ func parseKeeperConfigFromCmd(c *Keeper) error {
 	databaseDSN := cmd.StringP("database-dsn", "d", c.DatabaseDSN, "адрес подключения к БД")
 	logLevel := cmd.StringP("log-level", "", c.LogLevel, "уровень логирования")
 	key := cmd.StringP("key", "k", c.Key, "ключ хеширования")
+	keyringPath := cmd.StringP("keyring", "", c.KeyringPath, "путь до файла с набором ключей хеширования")
+	strictAuth := cmd.BoolP("strict-auth", "", c.StrictAuth, "отклонять неподписанные запросы на изменение метрик")
+	tokensPath := cmd.StringP("tokens-file", "", c.TokensPath, "путь до файла с API токенами")
+	tlsCert := cmd.StringP("tls-cert", "", c.TLSCert, "путь до файла с сертификатом сервера")
+	tlsKey := cmd.StringP("tls-key", "", c.TLSKey, "путь до файла с закрытым ключом сертификата сервера")
+	tlsClientCA := cmd.StringP("tls-client-ca", "", c.TLSClientCA, "путь до файла с сертификатами центров сертификации агентов")
+	trustedSubnets := cmd.StringSliceP("trusted-subnet", "", c.TrustedSubnets, "доверенные подсети в формате CIDR")
+	deniedSubnets := cmd.StringSliceP("denied-subnet", "", c.DeniedSubnets, "запрещенные подсети в формате CIDR")
+	trustedProxies := cmd.StringSliceP("trusted-proxies", "", c.TrustedProxies, "подсети доверенных прокси в формате CIDR")
+	rateLimit := cmd.Float64P("rate-limit-rps", "", c.RateLimit, "допустимое количество запросов в секунду от одного агента")
+	rateBurst := cmd.UintP("rate-burst", "", c.RateBurst, "допустимое кратковременное превышение количества запросов от одного агента")
+	maxBodySize := cmd.Int64P("max-body-size", "", c.MaxBodySize, "максимальный размер тела запроса в байтах")
+	maxDecompressedBodySize := cmd.Int64P("max-decompressed-body-size", "", c.MaxDecompressedBodySize, "максимальный размер разжатого тела запроса в байтах")
 	enableProfiling := cmd.BoolP("enable-pprof", "", c.EnableProfiling, "включить профилировщик")
 
 	if err := cmd.Parse(os.Args[1:]); err != nil {
@@ -108,15 +180,28 @@ func parseKeeperConfigFromCmd(c *Keeper) error {
 	}
 
 	*c = Keeper{
-		Address:            address,
-		CryptoKey:          *cryproKey,
-		StoreIntervalInSec: *storeInterval,
-		FileStoragePath:    *storagePath,
-		Restore:            restoreValue,
-		DatabaseDSN:        *databaseDSN,
-		LogLevel:           *logLevel,
-		Key:                *key,
-		EnableProfiling:    *enableProfiling,
+		Address:                 address,
+		CryptoKey:               *cryproKey,
+		StoreIntervalInSec:      *storeInterval,
+		FileStoragePath:         *storagePath,
+		Restore:                 restoreValue,
+		DatabaseDSN:             *databaseDSN,
+		LogLevel:                *logLevel,
+		Key:                     *key,
+		KeyringPath:             *keyringPath,
+		StrictAuth:              *strictAuth,
+		TokensPath:              *tokensPath,
+		TLSCert:                 *tlsCert,
+		TLSKey:                  *tlsKey,
+		TLSClientCA:             *tlsClientCA,
+		TrustedSubnets:          *trustedSubnets,
+		DeniedSubnets:           *deniedSubnets,
+		TrustedProxies:          *trustedProxies,
+		RateLimit:               *rateLimit,
+		RateBurst:               *rateBurst,
+		MaxBodySize:             *maxBodySize,
+		MaxDecompressedBodySize: *maxDecompressedBodySize,
+		EnableProfiling:         *enableProfiling,
 	}
 	return nil
 }
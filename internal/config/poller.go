@@ -17,8 +17,13 @@ const (
 	DefaultPollerShutdownTimeout     = 10
 	DefaultPollerLogLevel            = "info"
 	DefaultPollerAddress             = "localhost:8080"
+	DefaultPollerHealthPushFailures  = 3
+	DefaultPollerPollWorkers         = 2
 )
 
+// RedactedValue значение, которым заменяются секреты при выводе конфигурации.
+const RedactedValue = "[REDACTED]"
+
 // Poller конфигурация агента.
 type Poller struct {
 	// Адрес сервера, к которому будет подключаться агент
@@ -35,12 +40,78 @@ type Poller struct {
 	LogLevel string `env:"LOG_LEVEL" json:"log_level"`
 	// Ключ подписи передаваемых данных.
 	Key string `env:"KEY" json:"key"`
-	// RateLimit ограничение передаваемых метрик за раз. По умолчанию ограничения нет.
+	// KeyID идентификатор ключа подписи. Передается серверу в заголовке Key-ID.
+	KeyID string `env:"KEY_ID" json:"key_id"`
+	// Token API токен для доступа к серверу.
+	Token string `env:"TOKEN" json:"token"`
+	// TLSCA путь до файла с сертификатами центров сертификации для проверки сертификата сервера.
+	// Если указан, то агент подключается к серверу по HTTPS.
+	TLSCA string `env:"TLS_CA" json:"tls_ca"`
+	// TLSCert путь до файла с сертификатом агента, который предъявляется серверу.
+	TLSCert string `env:"TLS_CERT" json:"tls_cert"`
+	// TLSKey путь до файла с закрытым ключом сертификата агента.
+	TLSKey string `env:"TLS_KEY" json:"tls_key"`
+	// PollWorkers количество одновременно опрашиваемых сборщиков метрик. По умолчанию 2.
+	PollWorkers uint `env:"POLL_WORKERS" json:"poll_workers"`
+	// Collectors настройки сборщиков метрик по их именам. Сборщики, которые не указаны,
+	// используются с настройками по умолчанию.
+	Collectors map[string]CollectorConfig `json:"collectors"`
+	// RateLimit количество одновременно исходящих запросов на сервер. По умолчанию 0 - один запрос.
 	RateLimit uint `env:"RATE_LIMIT" json:"rate_limit"`
+	// BatchSize максимальное количество метрик в одном запросе. По умолчанию 0 - без ограничений.
+	BatchSize uint `env:"BATCH_SIZE" json:"batch_size"`
+	// BatchBytes максимальный размер метрик в одном запросе в байтах (до сжатия).
+	// По умолчанию 0 - без ограничений.
+	BatchBytes uint `env:"BATCH_BYTES" json:"batch_bytes"`
+	// RequestsPerSecond максимальное количество запросов на сервер в секунду. По умолчанию 0 - без ограничений.
+	RequestsPerSecond float64 `env:"REQUESTS_PER_SECOND" json:"requests_per_second"`
 	// Таймаут отправки метрик при завершении программы
 	ShutdownTimeoutInSec uint `env:"SHUTDOWN_TIMEOUT" json:"shutdown_timeout"`
 	// EnableProfiling доступ к профилировщику. По умолчанию недоступен.
 	EnableProfiling bool `env:"ENABLE_PPROF" json:"enable_profiling"`
+	// IngestAddress адрес и порт, на котором агент принимает метрики от локальных приложений
+	// по протоколу сервера. Запросы не проверяются, поэтому допускается только локальный адрес
+	// (localhost, 127.0.0.1 или ::1). По умолчанию не задан - метрики не принимаются.
+	IngestAddress string `env:"INGEST_ADDRESS" json:"ingest_address"`
+	// IngestSocket путь до unix сокета, через который агент принимает метрики от локальных приложений.
+	// По умолчанию не задан - метрики через сокет не принимаются.
+	IngestSocket string `env:"INGEST_SOCKET" json:"ingest_socket"`
+	// StatusAddress адрес и порт, на котором агент показывает свое состояние и текущие метрики.
+	// По умолчанию не задан - состояние не показывается.
+	StatusAddress string `env:"STATUS_ADDRESS" json:"status_address"`
+	// HealthPushFailures количество неудачных отправок подряд, после которого агент считается неработоспособным.
+	// По умолчанию 3.
+	HealthPushFailures uint `env:"HEALTH_PUSH_FAILURES" json:"health_push_failures"`
+}
+
+// CollectorConfig настройки сборщика метрик.
+type CollectorConfig struct {
+	// Enabled включает или выключает сборщик. Если не указан, то используется значение по умолчанию для сборщика.
+	Enabled *bool `json:"enabled,omitempty"`
+	// IntervalInSec интервал опроса сборщика (в секундах). По умолчанию 0 - общий интервал сбора метрик.
+	IntervalInSec uint `json:"interval,omitempty"`
+	// TimeoutInSec таймаут опроса сборщика (в секундах). По умолчанию 0 - 90% интервала опроса сборщика.
+	TimeoutInSec uint `json:"timeout,omitempty"`
+	// Options параметры, специфичные для сборщика.
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// IsEnabled возвращает true, если сборщик включен. Если в настройках это не указано, то возвращается def.
+func (c CollectorConfig) IsEnabled(def bool) bool {
+	if c.Enabled == nil {
+		return def
+	}
+	return *c.Enabled
+}
+
+// Interval возвращает интервал опроса сборщика в виде time.Duration.
+func (c CollectorConfig) Interval() time.Duration {
+	return time.Duration(c.IntervalInSec) * time.Second
+}
+
+// Timeout возвращает таймаут опроса сборщика в виде time.Duration.
+func (c CollectorConfig) Timeout() time.Duration {
+	return time.Duration(c.TimeoutInSec) * time.Second
 }
 
 // DefaultPollerConfig конфиг по умолчанию.
@@ -51,9 +122,23 @@ var DefaultPollerConfig = Poller{
 	PollIntervalInSec:    DefaultPollerPollIntervalInSec,
 	LogLevel:             DefaultPollerLogLevel,
 	Key:                  "",
+	KeyID:                "",
+	Token:                "",
+	TLSCA:                "",
+	TLSCert:              "",
+	TLSKey:               "",
+	PollWorkers:          DefaultPollerPollWorkers,
+	Collectors:           nil,
 	RateLimit:            DefaultPollerRateLimit,
+	BatchSize:            0,
+	BatchBytes:           0,
+	RequestsPerSecond:    0,
 	EnableProfiling:      false,
 	ShutdownTimeoutInSec: DefaultPollerShutdownTimeout,
+	IngestAddress:        "",
+	IngestSocket:         "",
+	StatusAddress:        "",
+	HealthPushFailures:   DefaultPollerHealthPushFailures,
 }
 
 // ParsePollerConfig возвращает конфиг Poller'a. Опции разбираются из аргументов командной строки
@@ -81,10 +166,79 @@ func (c Poller) PollInterval() time.Duration {
 	return time.Duration(c.PollIntervalInSec) * time.Second
 }
 
+// IsTLSEnabled возвращает true, если агент должен подключаться к серверу по HTTPS.
+func (c Poller) IsTLSEnabled() bool {
+	return len(c.TLSCA) > 0 || (len(c.TLSCert) > 0 && len(c.TLSKey) > 0)
+}
+
+// ShutdownTimeout возвращает таймаут завершения агента в виде time.Duration.
 func (c Poller) ShutdownTimeout() time.Duration {
 	return time.Duration(c.ShutdownTimeoutInSec) * time.Second
 }
 
+// secretOptions имена параметров сборщиков, значения которых считаются секретами.
+var secretOptions = map[string]struct{}{
+	"password": {},
+	// переменные окружения команд сборщика exec
+	"env": {},
+	// токен авторизации сборщика scrape
+	"bearer_token": {},
+}
+
+// Redacted возвращает копию конфигурации, в которой секреты заменены на RedactedValue.
+// В параметрах сборщиков заменяются значения параметров из secretOptions на любом уровне вложенности.
+func (c Poller) Redacted() Poller {
+	redacted := c
+	for _, secret := range []*string{&redacted.Key, &redacted.Token} {
+		if len(*secret) > 0 {
+			*secret = RedactedValue
+		}
+	}
+	if c.Collectors != nil {
+		redacted.Collectors = make(map[string]CollectorConfig, len(c.Collectors))
+		for name, cc := range c.Collectors {
+			cc.Options = redactOptions(cc.Options)
+			redacted.Collectors[name] = cc
+		}
+	}
+	return redacted
+}
+
+// redactOptions возвращает параметры сборщика, в которых секреты заменены на RedactedValue.
+// Если параметры не удалось разобрать, то они заменяются целиком.
+func redactOptions(options json.RawMessage) json.RawMessage {
+	if len(options) == 0 {
+		return options
+	}
+	var v any
+	if err := json.Unmarshal(options, &v); err != nil {
+		return json.RawMessage(`"` + RedactedValue + `"`)
+	}
+	redacted, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return json.RawMessage(`"` + RedactedValue + `"`)
+	}
+	return redacted
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if _, ok := secretOptions[key]; ok {
+				v[key] = RedactedValue
+				continue
+			}
+			v[key] = redactValue(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+	return v
+}
+
 func parsePollerConfigFromJSON(c *Poller, jsonValue []byte) error {
 	if len(jsonValue) == 0 {
 		return nil
@@ -102,15 +256,29 @@ func parsePollerConfigFromCmd(c *Poller) error {
 	pollInterval := cmd.UintP("poll-interval", "p", c.PollIntervalInSec, "интервал сбора метрик")
 	logLevel := cmd.StringP("log-level", "", c.LogLevel, "уровень логирования")
 	key := cmd.StringP("key", "k", c.Key, "ключ хеша")
+	keyID := cmd.StringP("key-id", "", c.KeyID, "идентификатор ключа хеша")
+	token := cmd.StringP("token", "", c.Token, "API токен для доступа к серверу")
+	tlsCA := cmd.StringP("tls-ca", "", c.TLSCA, "путь до файла с сертификатами центров сертификации сервера")
+	tlsCert := cmd.StringP("tls-cert", "", c.TLSCert, "путь до файла с сертификатом агента")
+	tlsKey := cmd.StringP("tls-key", "", c.TLSKey, "путь до файла с закрытым ключом сертификата агента")
+	pollWorkers := cmd.UintP("poll-workers", "", c.PollWorkers, "количество одновременно опрашиваемых сборщиков метрик")
 	rateLimit := cmd.UintP("rate-limit", "l", c.RateLimit, "количество одновременно исходящих запросов на сервер")
+	batchSize := cmd.UintP("batch-size", "", c.BatchSize, "максимальное количество метрик в одном запросе")
+	batchBytes := cmd.UintP("batch-bytes", "", c.BatchBytes, "максимальный размер метрик в одном запросе в байтах")
+	requestsPerSecond := cmd.Float64P("requests-per-second", "", c.RequestsPerSecond, "максимальное количество запросов на сервер в секунду")
 	shutdownTimeout := cmd.UintP("shutdown-timeout", "", c.ShutdownTimeoutInSec, "таймаут завершения программы")
 	enableProfiling := cmd.BoolP("enable-pprof", "", c.EnableProfiling, "включить профилироовщик")
+	ingestAddress := cmd.StringP("ingest-address", "", c.IngestAddress, "адрес и порт для приема метрик от локальных приложений")
+	ingestSocket := cmd.StringP("ingest-socket", "", c.IngestSocket, "путь до unix сокета для приема метрик от локальных приложений")
+	statusAddress := cmd.StringP("status-address", "", c.StatusAddress, "адрес и порт для просмотра состояния агента")
+	healthPushFailures := cmd.UintP("health-push-failures", "", c.HealthPushFailures, "количество неудачных отправок подряд, после которого агент неработоспособен")
 	cmd.StringP("config", "c", "", "путь к конфигурационному файлу")
 
 	if err := cmd.Parse(os.Args[1:]); err != nil {
 		return err
 	}
 
+	// сборщики настраиваются только в конфигурационном файле
 	*c = Poller{
 		Address:              address,
 		CryptoKey:            *cryptoKey,
@@ -118,9 +286,23 @@ func parsePollerConfigFromCmd(c *Poller) error {
 		PollIntervalInSec:    *pollInterval,
 		LogLevel:             *logLevel,
 		Key:                  *key,
+		KeyID:                *keyID,
+		Token:                *token,
+		TLSCA:                *tlsCA,
+		TLSCert:              *tlsCert,
+		TLSKey:               *tlsKey,
+		PollWorkers:          *pollWorkers,
+		Collectors:           c.Collectors,
 		RateLimit:            *rateLimit,
+		BatchSize:            *batchSize,
+		BatchBytes:           *batchBytes,
+		RequestsPerSecond:    *requestsPerSecond,
 		ShutdownTimeoutInSec: *shutdownTimeout,
 		EnableProfiling:      *enableProfiling,
+		IngestAddress:        *ingestAddress,
+		IngestSocket:         *ingestSocket,
+		StatusAddress:        *statusAddress,
+		HealthPushFailures:   *healthPushFailures,
 	}
 	return nil
 }
@@ -1,13 +1,16 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestParse(t *testing.T) {
+	disabled := false
 	tests := []struct {
 		name      string
 		osargs    []string
@@ -28,12 +31,14 @@ func TestParse(t *testing.T) {
 				LogLevel:             "info",
 				RateLimit:            0,
 				ShutdownTimeoutInSec: DefaultPollerShutdownTimeout,
+				HealthPushFailures:   DefaultPollerHealthPushFailures,
+				PollWorkers:          DefaultPollerPollWorkers,
 			},
 			wantErr: false,
 		},
 		{
 			name:      "Only arguments",
-			osargs:    []string{"server", "-a", ":8090", "-r", "30", "-p", "10", "--log-level", "error", "-k", "secret", "-l", "12", "--shutdown-timeout", "20"},
+			osargs:    []string{"server", "-a", ":8090", "-r", "30", "-p", "10", "--log-level", "error", "-k", "secret", "--key-id", "k1", "-l", "12", "--batch-size", "100", "--requests-per-second", "2.5", "--shutdown-timeout", "20", "--ingest-address", "localhost:8081", "--health-push-failures", "5", "--poll-workers", "4"},
 			env:       map[string]string{},
 			jsonValue: nil,
 			want: Poller{
@@ -42,8 +47,14 @@ func TestParse(t *testing.T) {
 				PollIntervalInSec:    10,
 				LogLevel:             "error",
 				Key:                  "secret",
+				KeyID:                "k1",
 				RateLimit:            12,
+				BatchSize:            100,
+				RequestsPerSecond:    2.5,
 				ShutdownTimeoutInSec: 20,
+				IngestAddress:        "localhost:8081",
+				HealthPushFailures:   5,
+				PollWorkers:          4,
 			},
 			wantErr: false,
 		},
@@ -57,7 +68,9 @@ func TestParse(t *testing.T) {
 				"POLL_INTERVAL":    "10",
 				"LOG_LEVEL":        "debug",
 				"KEY":              "secret",
+				"KEY_ID":           "k2",
 				"RATE_LIMIT":       "12",
+				"BATCH_BYTES":      "4096",
 				"SHUTDOWN_TIMEOUT": "20",
 			},
 			want: Poller{
@@ -66,8 +79,12 @@ func TestParse(t *testing.T) {
 				PollIntervalInSec:    10,
 				LogLevel:             "debug",
 				Key:                  "secret",
+				KeyID:                "k2",
 				RateLimit:            12,
+				BatchBytes:           4096,
 				ShutdownTimeoutInSec: 20,
+				HealthPushFailures:   DefaultPollerHealthPushFailures,
+				PollWorkers:          DefaultPollerPollWorkers,
 			},
 			wantErr: false,
 		},
@@ -82,8 +99,14 @@ func TestParse(t *testing.T) {
 					"poll_interval":10,
 					"log_level":"debug",
 					"key":"secret",
+					"token":"abc",
 					"rate_limit": 2,
-					"shutdown_timeout": 20
+					"shutdown_timeout": 20,
+					"ingest_socket": "/run/agent.sock",
+					"collectors": {
+						"gops": {"enabled": false},
+						"runtime": {"interval": 5, "timeout": 1, "options": {"a": 1}}
+					}
 				}
 			`),
 			env: map[string]string{},
@@ -94,15 +117,23 @@ func TestParse(t *testing.T) {
 				PollIntervalInSec:    10,
 				LogLevel:             "debug",
 				Key:                  "secret",
+				Token:                "abc",
 				RateLimit:            2,
 				ShutdownTimeoutInSec: 20,
+				HealthPushFailures:   DefaultPollerHealthPushFailures,
+				PollWorkers:          DefaultPollerPollWorkers,
+				IngestSocket:         "/run/agent.sock",
+				Collectors: map[string]CollectorConfig{
+					"gops":    {Enabled: &disabled},
+					"runtime": {IntervalInSec: 5, TimeoutInSec: 1, Options: json.RawMessage(`{"a": 1}`)},
+				},
 			},
 			wantErr: false,
 		},
 		{
 			name:      "Parse priority",
 			osargs:    []string{"server", "-a", ":8090", "-p", "100"},
-			env:       map[string]string{"ADDRESS": "127.0.0.1:9000", "CRYPTO_KEY": "key.pem"},
+			env:       map[string]string{"ADDRESS": "127.0.0.1:9000", "CRYPTO_KEY": "key.pem", "TLS_CA": "ca.pem"},
 			jsonValue: []byte(`{"address":"1.1.1.1:80", "key":"key"}`),
 			want: Poller{
 				Address:              "127.0.0.1:9000",
@@ -111,7 +142,10 @@ func TestParse(t *testing.T) {
 				LogLevel:             "info",
 				Key:                  "key",
 				CryptoKey:            "key.pem",
+				TLSCA:                "ca.pem",
 				ShutdownTimeoutInSec: DefaultPollerShutdownTimeout,
+				HealthPushFailures:   DefaultPollerHealthPushFailures,
+				PollWorkers:          DefaultPollerPollWorkers,
 			},
 			wantErr: false,
 		},
@@ -174,3 +208,49 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestPollerRedacted(t *testing.T) {
+	c := Poller{
+		Address: "localhost:8080",
+		Key:     "secret",
+		KeyID:   "k1",
+	}
+	got := c.Redacted()
+	assert.Equal(t, RedactedValue, got.Key)
+	assert.Equal(t, "k1", got.KeyID)
+	// пустые секреты остаются пустыми
+	assert.Empty(t, got.Token)
+	// исходная конфигурация не меняется
+	assert.Equal(t, "secret", c.Key)
+}
+
+func TestPollerRedactedCollectors(t *testing.T) {
+	options := json.RawMessage(`{"servers": [{"name": "db", "password": "secret"}], "user": "agent"}`)
+	c := Poller{
+		Collectors: map[string]CollectorConfig{
+			"custom":  {Options: options},
+			"invalid": {Options: json.RawMessage(`{"password": `)},
+			"exec":    {Options: json.RawMessage(`{"commands": [{"name": "db", "env": {"PGPASSWORD": "secret"}}]}`)},
+			"scrape":  {Options: json.RawMessage(`{"targets": [{"name": "app", "password": "secret", "bearer_token": "token"}]}`)},
+			"disk":    {},
+		},
+	}
+	got := c.Redacted()
+	assert.JSONEq(t, `{"servers": [{"name": "db", "password": "[REDACTED]"}], "user": "agent"}`, string(got.Collectors["custom"].Options))
+	assert.Equal(t, `"[REDACTED]"`, string(got.Collectors["invalid"].Options))
+	assert.JSONEq(t, `{"commands": [{"name": "db", "env": "[REDACTED]"}]}`, string(got.Collectors["exec"].Options))
+	assert.JSONEq(t, `{"targets": [{"name": "app", "password": "[REDACTED]", "bearer_token": "[REDACTED]"}]}`, string(got.Collectors["scrape"].Options))
+	assert.Empty(t, got.Collectors["disk"].Options)
+	// исходная конфигурация не меняется
+	assert.Equal(t, options, c.Collectors["custom"].Options)
+}
+
+func TestCollectorConfig(t *testing.T) {
+	enabled, disabled := true, false
+	assert.True(t, CollectorConfig{}.IsEnabled(true))
+	assert.False(t, CollectorConfig{}.IsEnabled(false))
+	assert.True(t, CollectorConfig{Enabled: &enabled}.IsEnabled(false))
+	assert.False(t, CollectorConfig{Enabled: &disabled}.IsEnabled(true))
+	assert.Equal(t, 5*time.Second, CollectorConfig{IntervalInSec: 5}.Interval())
+	assert.Equal(t, time.Duration(0), CollectorConfig{}.Timeout())
+}
@@ -0,0 +1,70 @@
+// Пакет filewatch позволяет отслеживать изменения файлов без перезапуска приложения.
+// Изменения определяются периодическим опросом времени модификации и размера файла.
+package filewatch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultInterval интервал опроса файла по умолчанию.
+	DefaultInterval = 10 * time.Second
+)
+
+type watchLogger interface {
+	Errorf(template string, args ...interface{})
+	Infof(template string, args ...interface{})
+}
+
+// fileState состояние файла, по которому определяется его изменение.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func stat(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// Watch с интервалом interval проверяет файл path и вызывает onChange, если файл изменился.
+// Ошибки onChange и ошибки доступа к файлу логируются, при этом отслеживание продолжается.
+// Отслеживание выполняется в отдельной горутине до завершения контекста ctx.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func() error, l watchLogger) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	// запоминаем текущее состояние, чтобы не вызывать onChange сразу после запуска
+	last, _ := stat(path)
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				current, err := stat(path)
+				if err != nil {
+					l.Errorf("watch %s: %v", path, err)
+					continue
+				}
+				if current == last {
+					continue
+				}
+				if err := onChange(); err != nil {
+					l.Errorf("reload %s: %v", path, err)
+					// состояние не обновляем, чтобы повторить попытку на следующей итерации
+					continue
+				}
+				last = current
+				l.Infof("reloaded %s", path)
+			}
+		}
+	}()
+}
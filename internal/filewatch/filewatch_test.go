@@ -0,0 +1,65 @@
+package filewatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/logger"
+)
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched")
+	if err := os.WriteFile(path, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	Watch(ctx, path, 10*time.Millisecond, func() error {
+		calls.Add(1)
+		return nil
+	}, &logger.Blackhole{})
+
+	time.Sleep(50 * time.Millisecond)
+	// файл не менялся
+	assert.Equal(t, int32(0), calls.Load())
+
+	if err := os.WriteFile(path, []byte("version 2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 10*time.Millisecond)
+	// повторно на то же изменение не реагируем
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWatchRetryOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched")
+	if err := os.WriteFile(path, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	Watch(ctx, path, 10*time.Millisecond, func() error {
+		if calls.Add(1) == 1 {
+			return errors.New("fake error")
+		}
+		return nil
+	}, &logger.Blackhole{})
+
+	if err := os.WriteFile(path, []byte("version 2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// после неудачной перезагрузки будет повторная попытка
+	assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 10*time.Millisecond)
+}
@@ -4,8 +4,10 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -48,6 +50,28 @@ func (h Handler) AllMetrics() gin.HandlerFunc {
 	}
 }
 
+// AllMetricsJSON обработчик вывода всех метрик на сервере в формате JSON.
+// Метрики выводятся списком, упорядоченным по типу и имени.
+func (h Handler) AllMetricsJSON() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		metrics := metric.Metrics{}
+		if err := h.keeper.Snapshot(ctx.Request.Context(), &metrics); err != nil {
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		result := make([]protocol.Metrics, 0, len(metrics.Counters)+len(metrics.Gauges))
+		sort.Slice(metrics.Counters, func(i, j int) bool { return metrics.Counters[i].Name < metrics.Counters[j].Name })
+		sort.Slice(metrics.Gauges, func(i, j int) bool { return metrics.Gauges[i].Name < metrics.Gauges[j].Name })
+		for _, m := range metrics.Counters {
+			result = append(result, protocol.Metrics{ID: m.Name, MType: string(TypeCounter), Delta: &m.Value})
+		}
+		for _, m := range metrics.Gauges {
+			result = append(result, protocol.Metrics{ID: m.Name, MType: string(TypeGauge), Value: &m.Value})
+		}
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
 // Value Обработчик вывода текущего значения запрашиваемой метрики.
 func (h Handler) Value() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -82,7 +106,7 @@ func (h Handler) ValueJSON() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var m protocol.Metrics
 		if err := json.NewDecoder(ctx.Request.Body).Decode(&m); err != nil {
-			ctx.Status(http.StatusBadRequest)
+			ctx.Status(decodeErrorStatus(err))
 			return
 		}
 		t := metricType(m.MType)
@@ -149,7 +173,7 @@ func (h Handler) UpdateJSON() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var m protocol.Metrics
 		if err := json.NewDecoder(ctx.Request.Body).Decode(&m); err != nil {
-			ctx.Status(http.StatusBadRequest)
+			ctx.Status(decodeErrorStatus(err))
 			return
 		}
 		t := metricType(m.MType)
@@ -203,7 +227,7 @@ func (h Handler) UpdatesJSON() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		recievedMetrics := make([]protocol.Metrics, 0, 10)
 		if err := json.NewDecoder(ctx.Request.Body).Decode(&recievedMetrics); err != nil {
-			ctx.Status(http.StatusBadRequest)
+			ctx.Status(decodeErrorStatus(err))
 			return
 		}
 		metrics := metric.NewMetrics()
@@ -222,3 +246,11 @@ func (h Handler) UpdatesJSON() gin.HandlerFunc {
 		ctx.Status(http.StatusOK)
 	}
 }
+
+// decodeErrorStatus возвращает статус ответа на ошибку разбора тела запроса err.
+func decodeErrorStatus(err error) int {
+	if errors.Is(err, protocol.ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
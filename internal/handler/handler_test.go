@@ -505,6 +505,54 @@ func TestAllMetrics(t *testing.T) {
 	}
 }
 
+func TestAllMetricsJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStorage(ctrl)
+
+	tests := []struct {
+		name string
+		ms   metric.Metrics
+		want string
+	}{
+		{
+			name: "With values",
+			want: `[{"id":"c1","type":"counter","delta":10},{"id":"c2","type":"counter","delta":1},{"id":"g1","type":"gauge","value":10.1}]`,
+			ms: metric.Metrics{
+				Counters: []*metric.Counter{metric.NewCounter("c2", 1), metric.NewCounter("c1", 10)},
+				Gauges:   []*metric.Gauge{metric.NewGauge("g1", 10.1)},
+			},
+		},
+		{
+			name: "Without values",
+			want: `[]`,
+			ms:   metric.Metrics{},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, r := gin.CreateTestContext(w)
+			store.EXPECT().Snapshot(gomock.Any(), gomock.Any()).SetArg(1, tt.ms)
+			keeper := keeper.New(store, config.Keeper{}, &logger.Blackhole{})
+			h := New(*keeper)
+			r.GET("/values/", h.AllMetricsJSON())
+			c.Request = httptest.NewRequest(http.MethodGet, "/values/", nil)
+			r.ServeHTTP(w, c.Request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			body, err := io.ReadAll(result.Body)
+			if !assert.NoError(t, err, "error while reading body") {
+				return
+			}
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.JSONEq(t, tt.want, string(body))
+		})
+	}
+}
+
 func TestUpdatesJSON(t *testing.T) {
 	type want struct {
 		statusCode int
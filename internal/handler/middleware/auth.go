@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/k1nky/ypmetrics/internal/auth"
+)
+
+const (
+	// TokenIDKey ключ контекста запроса, по которому доступен идентификатор токена клиента.
+	TokenIDKey = "token_id"
+)
+
+// хранилище токенов
+type tokenStore interface {
+	Lookup(value string, at time.Time) (auth.Token, error)
+}
+
+// TokenAuth middleware для аутентификации по API токенам.
+// Токен передается в заголовке Authorization: Bearer <токен>.
+type TokenAuth struct {
+	tokens tokenStore
+}
+
+// NewTokenAuth возвращает новую middleware для аутентификации по токенам из tokens.
+func NewTokenAuth(tokens tokenStore) *TokenAuth {
+	return &TokenAuth{
+		tokens: tokens,
+	}
+}
+
+// Require пропускает только запросы с действующим токеном, имеющим доступ к области scope.
+// Запрос без токена или с недействующим токеном отклоняется со статусом 401,
+// запрос с токеном без нужной области доступа - со статусом 403.
+// Идентификатор токена сохраняется в контексте запроса по ключу TokenIDKey.
+func (a *TokenAuth) Require(scope auth.Scope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		value, ok := bearerToken(ctx.Request)
+		if !ok {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		token, err := a.tokens.Lookup(value, time.Now())
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !token.HasScope(scope) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Set(TokenIDKey, token.ID)
+		ctx.Next()
+	}
+}
+
+// bearerToken возвращает значение токена из заголовка Authorization.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return "", false
+	}
+	value := strings.TrimSpace(h[len(prefix):])
+	return value, len(value) > 0
+}
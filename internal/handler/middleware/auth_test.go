@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/auth"
+)
+
+func TestTokenAuthRequire(t *testing.T) {
+	tokens := auth.New()
+	reader, _, _ := tokens.Mint("dashboard", []auth.Scope{auth.ScopeRead}, 0)
+	writer, _, _ := tokens.Mint("agent", []auth.Scope{auth.ScopeWrite}, 0)
+	admin, _, _ := tokens.Mint("admin", []auth.Scope{auth.ScopeAdmin}, 0)
+	expired, _, _ := tokens.Mint("expired", []auth.Scope{auth.ScopeWrite}, time.Nanosecond)
+	time.Sleep(10 * time.Millisecond)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantTokenID   string
+	}{
+		{name: "Write token", authorization: "Bearer " + writer, wantStatus: http.StatusOK, wantTokenID: "agent"},
+		{name: "Lowercase scheme", authorization: "bearer " + writer, wantStatus: http.StatusOK, wantTokenID: "agent"},
+		{name: "Admin token", authorization: "Bearer " + admin, wantStatus: http.StatusOK, wantTokenID: "admin"},
+		{name: "Read token", authorization: "Bearer " + reader, wantStatus: http.StatusForbidden},
+		{name: "Expired token", authorization: "Bearer " + expired, wantStatus: http.StatusUnauthorized},
+		{name: "Unknown token", authorization: "Bearer unknown", wantStatus: http.StatusUnauthorized},
+		{name: "Another scheme", authorization: "Basic " + writer, wantStatus: http.StatusUnauthorized},
+		{name: "Without token", authorization: "", wantStatus: http.StatusUnauthorized},
+	}
+	gin.SetMode(gin.TestMode)
+	a := NewTokenAuth(tokens)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, r := gin.CreateTestContext(w)
+			r.POST("/", a.Require(auth.ScopeWrite), func(c *gin.Context) {
+				assert.Equal(t, tt.wantTokenID, c.GetString(TokenIDKey))
+				c.Status(http.StatusOK)
+			})
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			if len(tt.authorization) > 0 {
+				c.Request.Header.Set("Authorization", tt.authorization)
+			}
+			r.ServeHTTP(w, c.Request)
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.wantStatus, result.StatusCode)
+		})
+	}
+}
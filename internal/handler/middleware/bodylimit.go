@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/k1nky/ypmetrics/internal/protocol"
+)
+
+// BodyLimit middleware для ограничения размера тела запроса в том виде, в котором он передан клиентом.
+// Размер разжатого тела ограничивается в Gzip.
+type BodyLimit struct {
+	maxSize int64
+}
+
+// NewBodyLimit возвращает новую middleware, которая ограничивает размер тела запроса maxSize байтами.
+func NewBodyLimit(maxSize int64) *BodyLimit {
+	return &BodyLimit{
+		maxSize: maxSize,
+	}
+}
+
+// Use отклоняет запросы с заведомо большим телом со статусом 413. Если размер тела заранее неизвестен,
+// то чтение тела сверх ограничения завершится ошибкой protocol.ErrBodyTooLarge.
+func (bl *BodyLimit) Use() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.ContentLength > bl.maxSize {
+			ctx.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		ctx.Request.Body = limitBody(ctx.Writer, ctx.Request.Body, bl.maxSize)
+		ctx.Next()
+	}
+}
+
+// limitedBody тело запроса, ограниченное http.MaxBytesReader. Ошибка превышения размера
+// заменяется на protocol.ErrBodyTooLarge, чтобы обработчики не зависели от способа ограничения.
+type limitedBody struct {
+	io.ReadCloser
+}
+
+// limitBody возвращает тело запроса body, чтение которого сверх maxSize байт завершается
+// ошибкой protocol.ErrBodyTooLarge.
+func limitBody(w http.ResponseWriter, body io.ReadCloser, maxSize int64) io.ReadCloser {
+	return limitedBody{ReadCloser: http.MaxBytesReader(w, body, maxSize)}
+}
+
+func (b limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = fmt.Errorf("%w: %v", protocol.ErrBodyTooLarge, err)
+	}
+	return n, err
+}
+
+// bodyErrorStatus возвращает статус ответа на ошибку чтения тела запроса err.
+func bodyErrorStatus(err error, status int) int {
+	if errors.Is(err, protocol.ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return status
+}
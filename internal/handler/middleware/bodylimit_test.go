@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength bool
+		wantStatus    int
+	}{
+		{name: "Small body", body: "abc", contentLength: true, wantStatus: http.StatusOK},
+		{name: "Large body", body: strings.Repeat("a", 11), contentLength: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Large body without content length", body: strings.Repeat("a", 11), contentLength: false, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, r := gin.CreateTestContext(w)
+			r.POST("/", NewBodyLimit(10).Use(), func(c *gin.Context) {
+				if _, err := (&bytes.Buffer{}).ReadFrom(c.Request.Body); err != nil {
+					c.Status(bodyErrorStatus(err, http.StatusBadRequest))
+					return
+				}
+				c.Status(http.StatusOK)
+			})
+			var body io.Reader = strings.NewReader(tt.body)
+			if !tt.contentLength {
+				// httptest.NewRequest определяет размер только для известных типов
+				body = io.MultiReader(body)
+			}
+			c.Request = httptest.NewRequest(http.MethodPost, "/", body)
+			r.ServeHTTP(w, c.Request)
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.wantStatus, result.StatusCode)
+		})
+	}
+}
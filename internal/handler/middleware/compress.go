@@ -15,6 +15,8 @@ import (
 type Gzip struct {
 	contentTypes []string
 	compressors  sync.Pool
+	// максимальный размер разжатого тела запроса, 0 - без ограничений
+	maxSize int64
 }
 
 // NewGzip возвращает новый экземпляр middleware для сжатия и расжатия тела запроса
@@ -30,6 +32,13 @@ func NewGzip(contentTypes []string) *Gzip {
 	}
 }
 
+// SetMaxSize ограничивает размер разжатого тела запроса maxSize байтами.
+// Защищает от запросов с небольшим сжатым телом, которое при разжатии занимает много памяти.
+func (gh *Gzip) SetMaxSize(maxSize int64) *Gzip {
+	gh.maxSize = maxSize
+	return gh
+}
+
 // Gzip middleware позволяет разжимать тело запроса и сжимать тело ответа.
 // Тело запроса будет разжато, если указан заголовок content-encoding: gzip.
 //
@@ -44,11 +53,14 @@ func (gh *Gzip) Use() gin.HandlerFunc {
 			// требуется разжатие тела запроса, поэтому подменяем тело запроса
 			gz, err := gzip.NewReader(ctx.Request.Body)
 			if err != nil {
-				ctx.AbortWithStatus(http.StatusInternalServerError)
+				ctx.AbortWithStatus(bodyErrorStatus(err, http.StatusInternalServerError))
 				return
 			}
 			defer gz.Close()
 			ctx.Request.Body = gz
+			if gh.maxSize > 0 {
+				ctx.Request.Body = limitBody(ctx.Writer, gz, gh.maxSize)
+			}
 		}
 
 		gzwriter := &bufferWriter{
@@ -10,6 +10,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/protocol"
 )
 
 func TestShouldCompress(t *testing.T) {
@@ -259,3 +261,26 @@ func TestUncompressRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestUncompressRequestMaxSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// небольшое сжатое тело, которое при разжатии превышает ограничение
+	buf := &bytes.Buffer{}
+	gz, _ := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	gz.Write(bytes.Repeat([]byte{0}, 1<<20))
+	gz.Close()
+
+	w := httptest.NewRecorder()
+	c, r := gin.CreateTestContext(w)
+	r.POST("/", NewGzip([]string{}).SetMaxSize(1024).Use(), func(c *gin.Context) {
+		_, err := (&bytes.Buffer{}).ReadFrom(c.Request.Body)
+		assert.ErrorIs(t, err, protocol.ErrBodyTooLarge)
+		c.Status(bodyErrorStatus(err, http.StatusBadRequest))
+	})
+	c.Request = httptest.NewRequest(http.MethodPost, "/", buf)
+	c.Request.Header.Set("Content-Encoding", "gzip")
+	r.ServeHTTP(w, c.Request)
+	result := w.Result()
+	defer result.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, result.StatusCode)
+}
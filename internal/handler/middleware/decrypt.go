@@ -41,7 +41,7 @@ func (d *Decrypter) Use() gin.HandlerFunc {
 		buf.Reset()
 
 		if _, err := buf.ReadFrom(ctx.Request.Body); err != nil {
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+			ctx.AbortWithStatus(bodyErrorStatus(err, http.StatusInternalServerError))
 			return
 		}
 		body, err := crypto.DecryptRSA(d.key, buf.Bytes())
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AgentIDKey ключ контекста запроса, по которому доступен идентификатор агента из его сертификата.
+	AgentIDKey = "agent_id"
+)
+
+// ClientCertIdentity это middleware, которая сохраняет в контексте запроса идентификатор агента,
+// полученный из поля CN проверенного клиентского сертификата. Если соединение без TLS или клиент
+// не предъявил сертификат, то идентификатор не задается.
+func ClientCertIdentity() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if tls := ctx.Request.TLS; tls != nil && len(tls.PeerCertificates) > 0 {
+			if cn := tls.PeerCertificates[0].Subject.CommonName; len(cn) > 0 {
+				ctx.Set(AgentIDKey, cn)
+			}
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCertIdentity(t *testing.T) {
+	tests := []struct {
+		name   string
+		tls    *tls.ConnectionState
+		wantID string
+		wantOk bool
+	}{
+		{
+			name:   "With certificate",
+			tls:    &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "agent-1"}}}},
+			wantID: "agent-1",
+			wantOk: true,
+		},
+		{
+			name:   "Without certificate",
+			tls:    &tls.ConnectionState{},
+			wantOk: false,
+		},
+		{
+			name:   "Without TLS",
+			tls:    nil,
+			wantOk: false,
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, r := gin.CreateTestContext(w)
+			r.GET("/", ClientCertIdentity(), func(c *gin.Context) {
+				id, ok := c.Get(AgentIDKey)
+				assert.Equal(t, tt.wantOk, ok)
+				if ok {
+					assert.Equal(t, tt.wantID, id)
+				}
+				c.Status(http.StatusOK)
+			})
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.TLS = tt.tls
+			r.ServeHTTP(w, c.Request)
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/k1nky/ypmetrics/internal/ratelimit"
+)
+
+// RateLimit middleware для ограничения частоты запросов от каждого клиента.
+// Клиент определяется по идентификатору агента (CN клиентского сертификата), а если он неизвестен -
+// по адресу соединения. Заголовок X-Real-IP не используется, т.к. клиент может менять его
+// в каждом запросе и обходить ограничение.
+type RateLimit struct {
+	limiter *ratelimit.Limiter
+}
+
+// NewRateLimit возвращает новую middleware, которая разрешает каждому клиенту rate запросов
+// в секунду с возможностью кратковременного превышения до burst запросов.
+func NewRateLimit(rate float64, burst int) *RateLimit {
+	return &RateLimit{
+		limiter: ratelimit.NewLimiter(rate, burst),
+	}
+}
+
+// Use отклоняет запросы сверх ограничения со статусом 429. В заголовке Retry-After
+// указывается количество секунд до следующей попытки.
+func (rl *RateLimit) Use() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ok, wait := rl.limiter.Allow(clientKey(ctx)); !ok {
+			ctx.Header("Retry-After", retryAfter(wait))
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// clientKey возвращает ключ, по которому определяется клиент.
+func clientKey(ctx *gin.Context) string {
+	if id := ctx.GetString(AgentIDKey); len(id) > 0 {
+		return "agent:" + id
+	}
+	if ip := remoteIP(ctx.Request); ip != nil {
+		return "ip:" + ip.String()
+	}
+	return "addr:" + ctx.Request.RemoteAddr
+}
+
+// retryAfter возвращает значение заголовка Retry-After в секундах, округленное вверх.
+func retryAfter(wait time.Duration) string {
+	seconds := math.Ceil(wait.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	if seconds > math.MaxInt32 {
+		seconds = math.MaxInt32
+	}
+	return strconv.Itoa(int(seconds))
+}
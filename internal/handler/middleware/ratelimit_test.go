@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, r := gin.CreateTestContext(httptest.NewRecorder())
+	r.Use(func(c *gin.Context) {
+		if id := c.Request.Header.Get("Agent"); len(id) > 0 {
+			c.Set(AgentIDKey, id)
+		}
+	})
+	r.POST("/", NewRateLimit(0.5, 2).Use(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	do := func(remoteAddr string, agent string, realIP string) *http.Response {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if len(agent) > 0 {
+			req.Header.Set("Agent", agent)
+		}
+		if len(realIP) > 0 {
+			req.Header.Set("X-Real-IP", realIP)
+		}
+		r.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := do("10.0.0.1:1000", "", "")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	// X-Real-IP не влияет на определение клиента
+	resp := do("10.0.0.1:1001", "", "10.0.0.2")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+
+	// другой адрес
+	resp = do("10.0.0.2:1000", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// агент с того же адреса имеет отдельное ограничение
+	resp = do("10.0.0.1:1000", "agent1", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, "1", retryAfter(0))
+	assert.Equal(t, "1", retryAfter(100*time.Millisecond))
+	assert.Equal(t, "2", retryAfter(1100*time.Millisecond))
+}
@@ -9,35 +9,88 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/k1nky/ypmetrics/internal/keyring"
+)
+
+// Заголовки, используемые для подписи данных.
+const (
+	// HashHeader заголовок с подписью данных.
+	HashHeader = "HashSHA256"
+	// KeyIDHeader заголовок с идентификатором ключа подписи.
+	KeyIDHeader = "Key-ID"
 )
 
+// хранилище ключей подписи
+type keyStore interface {
+	Lookup(id string, at time.Time) (keyring.Key, error)
+	Version() uint64
+}
+
+// hasherPool пул хешеров для ключа подписи.
+type hasherPool struct {
+	secret string
+	pool   sync.Pool
+}
+
 // Seal middleware для подписи отправляемых данных и проверки подписи получаемых данных.
 type Seal struct {
-	hashers sync.Pool
+	keys keyStore
+
+	lock sync.Mutex
+	// пулы хешеров по идентификаторам ключей для версии набора ключей version
+	hashers map[string]*hasherPool
+	version uint64
 }
 
+// NewSeal возвращает новую middleware для подписи с единственным ключом key.
 func NewSeal(key string) *Seal {
+	keys := keyring.New()
+	keys.SetDefault(key)
+	return NewKeyringSeal(keys)
+}
+
+// NewKeyringSeal возвращает новую middleware для подписи с набором ключей keys.
+// Ключ выбирается по идентификатору из заголовка Key-ID, если заголовок не указан,
+// то используется ключ по умолчанию.
+func NewKeyringSeal(keys keyStore) *Seal {
 	return &Seal{
-		hashers: sync.Pool{
-			New: func() any {
-				return hmac.New(sha256.New, []byte(key))
-			},
-		},
+		keys:    keys,
+		hashers: make(map[string]*hasherPool),
 	}
 }
 
 // Use формирует подпись отправляемых данных и проверяет подпись получаемых данных.
-// Подпись должна быть указана в заголовке HashSHA256.
+// Подпись должна быть указана в заголовке HashSHA256, идентификатор ключа - в заголовке Key-ID.
+// Ответ подписывается тем же ключом, что и запрос.
 func (s *Seal) Use() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		if seal := ctx.Request.Header.Get("HashSHA256"); len(seal) > 0 {
-			if valid, err := s.verify(ctx.Request, seal); !valid || err != nil {
+		keyID := ctx.Request.Header.Get(KeyIDHeader)
+		key, keyErr := s.keys.Lookup(keyID, time.Now())
+		if seal := ctx.Request.Header.Get(HashHeader); len(seal) > 0 {
+			if keyErr != nil {
+				// неизвестный или недействующий ключ
+				ctx.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			valid, err := s.verify(ctx.Request, key, seal)
+			if err != nil {
+				ctx.AbortWithStatus(bodyErrorStatus(err, http.StatusBadRequest))
+				return
+			}
+			if !valid {
 				ctx.AbortWithStatus(http.StatusBadRequest)
 				return
 			}
 		}
+		if keyErr != nil {
+			// ключа для подписи ответа нет
+			ctx.Next()
+			return
+		}
 
 		bw := &bufferWriter{
 			body:           &bytes.Buffer{},
@@ -45,21 +98,63 @@ func (s *Seal) Use() gin.HandlerFunc {
 		}
 		ctx.Writer = bw
 		ctx.Next()
-		h, err := s.sign(bw.body.Bytes())
+		h, err := s.sign(key, bw.body.Bytes())
 		if err != nil {
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
-		bw.Header().Set("HashSHA256", h)
+		bw.Header().Set(HashHeader, h)
+		if len(keyID) > 0 {
+			bw.Header().Set(KeyIDHeader, keyID)
+		}
 		if _, err := bw.ResponseWriter.Write(bw.body.Bytes()); err != nil {
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 		}
 	}
 }
 
-func (s *Seal) verify(req *http.Request, seal string) (bool, error) {
-	h := s.hashers.Get().(hash.Hash)
-	defer s.hashers.Put(h)
+// Require отклоняет запросы без подписи со статусом 401. Проверяется только наличие подписи,
+// поэтому Require можно вызывать до чтения тела запроса. Сама подпись проверяется в Use.
+// Используется для маршрутов, которые должны быть доступны только подписанным запросам.
+func (s *Seal) Require() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if len(ctx.Request.Header.Get(HashHeader)) == 0 {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// hasher возвращает пул хешеров для ключа key. При изменении набора ключей пулы прежних ключей
+// удаляются, поэтому их количество не растет при ротации ключей.
+func (s *Seal) hasher(key keyring.Key) *sync.Pool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if v := s.keys.Version(); v != s.version {
+		s.hashers = make(map[string]*hasherPool)
+		s.version = v
+	}
+	if p, ok := s.hashers[key.ID]; ok && p.secret == key.Secret {
+		return &p.pool
+	}
+	secret := key.Secret
+	p := &hasherPool{
+		secret: secret,
+		pool: sync.Pool{
+			New: func() any {
+				return hmac.New(sha256.New, []byte(secret))
+			},
+		},
+	}
+	s.hashers[key.ID] = p
+	return &p.pool
+}
+
+func (s *Seal) verify(req *http.Request, key keyring.Key, seal string) (bool, error) {
+	pool := s.hasher(key)
+	h := pool.Get().(hash.Hash)
+	defer pool.Put(h)
 	h.Reset()
 
 	buf := io.TeeReader(req.Body, h)
@@ -70,13 +165,17 @@ func (s *Seal) verify(req *http.Request, seal string) (bool, error) {
 	_ = req.Body.Close()
 	req.Body = io.NopCloser(body)
 
-	got := hex.EncodeToString(h.Sum(nil))
-	return seal == got, nil
+	want, err := hex.DecodeString(seal)
+	if err != nil {
+		return false, nil
+	}
+	return hmac.Equal(want, h.Sum(nil)), nil
 }
 
-func (s *Seal) sign(data []byte) (string, error) {
-	h := s.hashers.Get().(hash.Hash)
-	defer s.hashers.Put(h)
+func (s *Seal) sign(key keyring.Key, data []byte) (string, error) {
+	pool := s.hasher(key)
+	h := pool.Get().(hash.Hash)
+	defer pool.Put(h)
 	h.Reset()
 
 	if _, err := h.Write(data); err != nil {
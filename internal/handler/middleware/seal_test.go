@@ -2,12 +2,19 @@ package middleware
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/keyring"
 )
 
 func TestSealVerify(t *testing.T) {
@@ -29,6 +36,12 @@ func TestSealVerify(t *testing.T) {
 			data:       "bye",
 			hashValue:  "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
 		},
+		{
+			name:       "With invalid hex",
+			wantStatus: http.StatusBadRequest,
+			data:       "hello",
+			hashValue:  "not a hash",
+		},
 		{
 			name:       "Without header",
 			wantStatus: http.StatusOK,
@@ -71,3 +84,165 @@ func TestSealResponse(t *testing.T) {
 	assert.Equal(t, http.StatusOK, result.StatusCode)
 	assert.Equal(t, "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b", w.Header().Get("HashSHA256"))
 }
+
+func TestSealVerifyWithKeyID(t *testing.T) {
+	keys := keyring.New()
+	keys.SetDefault("secret")
+	if err := keys.Load(strings.NewReader(`{
+		"keys": [
+			{"id": "k1", "secret": "secret1"},
+			{"id": "expired", "secret": "secret", "not_after": "2000-01-01T00:00:00Z"}
+		]
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		wantStatus int
+		data       string
+		keyID      string
+		hashValue  string
+		wantHash   string
+	}{
+		{
+			name:       "Default key",
+			wantStatus: http.StatusOK,
+			data:       "hello",
+			keyID:      "",
+			hashValue:  "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+			wantHash:   "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+		},
+		{
+			name:       "Named key",
+			wantStatus: http.StatusOK,
+			data:       "hello",
+			keyID:      "k1",
+			hashValue:  "aa079133ea800e13f8ea6c1c8174ee78354b200fe2ce6ac5e865d3a90080d6dd",
+			wantHash:   "aa079133ea800e13f8ea6c1c8174ee78354b200fe2ce6ac5e865d3a90080d6dd",
+		},
+		{
+			name:       "Signed by another key",
+			wantStatus: http.StatusBadRequest,
+			data:       "hello",
+			keyID:      "k1",
+			hashValue:  "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+		},
+		{
+			name:       "Expired key",
+			wantStatus: http.StatusBadRequest,
+			data:       "hello",
+			keyID:      "expired",
+			hashValue:  "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+		},
+		{
+			name:       "Unknown key",
+			wantStatus: http.StatusBadRequest,
+			data:       "hello",
+			keyID:      "unknown",
+			hashValue:  "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	seal := NewKeyringSeal(keys)
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, r := gin.CreateTestContext(w)
+		r.Any("/", seal.Use(), func(c *gin.Context) {
+			c.Writer.WriteString(tt.data)
+			c.Status(http.StatusOK)
+		})
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.data))
+		c.Request.Header.Set("HashSHA256", tt.hashValue)
+		if len(tt.keyID) > 0 {
+			c.Request.Header.Set("Key-ID", tt.keyID)
+		}
+		r.ServeHTTP(w, c.Request)
+		result := w.Result()
+		defer result.Body.Close()
+		assert.Equal(t, tt.wantStatus, result.StatusCode, tt.name)
+		if tt.wantStatus == http.StatusOK {
+			// ответ подписан тем же ключом, что и запрос
+			assert.Equal(t, tt.wantHash, result.Header.Get("HashSHA256"), tt.name)
+			assert.Equal(t, tt.keyID, result.Header.Get("Key-ID"), tt.name)
+		}
+	}
+}
+
+func TestSealKeyRotation(t *testing.T) {
+	keys := keyring.New()
+	seal := NewKeyringSeal(keys)
+	sign := func(secret string, data string) string {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(data))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	status := func(keyID string, hashValue string) int {
+		w := httptest.NewRecorder()
+		c, r := gin.CreateTestContext(w)
+		r.POST("/", seal.Use(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("hello"))
+		c.Request.Header.Set("HashSHA256", hashValue)
+		c.Request.Header.Set("Key-ID", keyID)
+		r.ServeHTTP(w, c.Request)
+		return w.Code
+	}
+	gin.SetMode(gin.TestMode)
+	for i, secret := range []string{"secret1", "secret2", "secret3"} {
+		id := fmt.Sprintf("k%d", i)
+		if !assert.NoError(t, keys.Load(strings.NewReader(`{"keys": [{"id": "`+id+`", "secret": "`+secret+`"}, {"id": "main", "secret": "`+secret+`"}]}`))) {
+			return
+		}
+		assert.Equal(t, http.StatusOK, status(id, sign(secret, "hello")))
+		assert.Equal(t, http.StatusOK, status("main", sign(secret, "hello")))
+		if i > 0 {
+			// прежний секрет ключа main больше не действует
+			assert.Equal(t, http.StatusBadRequest, status("main", sign("secret1", "hello")))
+		}
+		// пулы удаленных ключей не сохраняются
+		assert.Len(t, seal.hashers, 2)
+	}
+}
+
+func TestSealRequire(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantStatus int
+		hashValue  string
+	}{
+		{
+			name:       "Signed",
+			wantStatus: http.StatusOK,
+			hashValue:  "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b",
+		},
+		{
+			name:       "Invalid signature",
+			wantStatus: http.StatusBadRequest,
+			hashValue:  "734cc62f32841568f45715aeb9f4d7891324e6d948e4c6c60c0621cdac48623a",
+		},
+		{
+			name:       "Unsigned",
+			wantStatus: http.StatusUnauthorized,
+			hashValue:  "",
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	seal := NewSeal("secret")
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, r := gin.CreateTestContext(w)
+		r.Use(seal.Use())
+		r.POST("/", seal.Require(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("hello"))
+		if len(tt.hashValue) > 0 {
+			c.Request.Header.Set("HashSHA256", tt.hashValue)
+		}
+		r.ServeHTTP(w, c.Request)
+		result := w.Result()
+		defer result.Body.Close()
+		assert.Equal(t, tt.wantStatus, result.StatusCode, tt.name)
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RealIPHeader заголовок, в котором агент передает свой IP адрес.
+	RealIPHeader = "X-Real-IP"
+)
+
+// SubnetFilter middleware для ограничения доступа по IP адресу клиента.
+// Адрес клиента берется из адреса соединения. Заголовку X-Real-IP доверяют, только если
+// соединение установлено с адреса доверенного прокси, т.к. клиент может указать в нем любой адрес.
+type SubnetFilter struct {
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+	proxies []*net.IPNet
+}
+
+// NewSubnetFilter возвращает новую middleware с разрешенными подсетями allow, запрещенными подсетями deny
+// и подсетями доверенных прокси proxies, от которых принимается заголовок X-Real-IP.
+// Подсети задаются в формате CIDR, отдельный адрес можно указать без префикса.
+// Запрещенные подсети имеют приоритет над разрешенными. Пустой список allow разрешает доступ
+// для всех адресов, кроме запрещенных.
+func NewSubnetFilter(allow []string, deny []string, proxies []string) (*SubnetFilter, error) {
+	var err error
+	f := &SubnetFilter{}
+	if f.allow, err = parseSubnets(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parseSubnets(deny); err != nil {
+		return nil, err
+	}
+	if f.proxies, err = parseSubnets(proxies); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Use отклоняет запросы с недопустимых адресов со статусом 403.
+// Адрес соединения всегда проверяется по списку запрещенных подсетей.
+func (f *SubnetFilter) Use() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		remote := remoteIP(ctx.Request)
+		if remote == nil || containsIP(f.deny, remote) || !f.IsAllowed(f.ClientIP(ctx.Request)) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// IsAllowed возвращает true, если доступ с адреса ip разрешен.
+func (f *SubnetFilter) IsAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	return containsIP(f.allow, ip)
+}
+
+// ClientIP возвращает IP адрес клиента. Адрес берется из заголовка X-Real-IP, если соединение
+// установлено с адреса доверенного прокси, иначе - из адреса соединения.
+// Возвращает nil, если адрес определить не удалось.
+func (f *SubnetFilter) ClientIP(r *http.Request) net.IP {
+	ip := remoteIP(r)
+	if ip == nil || !containsIP(f.proxies, ip) {
+		return ip
+	}
+	if v := strings.TrimSpace(r.Header.Get(RealIPHeader)); len(v) > 0 {
+		return net.ParseIP(v)
+	}
+	return ip
+}
+
+// remoteIP возвращает IP адрес соединения.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func containsIP(subnets []*net.IPNet, ip net.IP) bool {
+	for _, s := range subnets {
+		if s.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSubnets(values []string) ([]*net.IPNet, error) {
+	subnets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			// отдельный адрес
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			subnets = append(subnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSubnetFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		wantErr bool
+	}{
+		{name: "Subnets", allow: []string{"10.0.0.0/8", "fd00::/8"}, deny: []string{"10.0.1.0/24"}, wantErr: false},
+		{name: "Single address", allow: []string{"10.0.0.1", "::1"}, wantErr: false},
+		{name: "Empty", allow: []string{}, deny: []string{""}, wantErr: false},
+		{name: "Invalid subnet", allow: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "Invalid address", deny: []string{"10.0.0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSubnetFilter(tt.allow, tt.deny, nil)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestSubnetFilterIsAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		ip    string
+		want  bool
+	}{
+		{name: "Allowed subnet", allow: []string{"10.0.0.0/8"}, ip: "10.1.2.3", want: true},
+		{name: "Not allowed subnet", allow: []string{"10.0.0.0/8"}, ip: "192.168.1.1", want: false},
+		{name: "Denied inside allowed", allow: []string{"10.0.0.0/8"}, deny: []string{"10.0.1.0/24"}, ip: "10.0.1.5", want: false},
+		{name: "Only deny list", deny: []string{"10.0.1.0/24"}, ip: "192.168.1.1", want: true},
+		{name: "Single address", allow: []string{"10.0.0.1"}, ip: "10.0.0.1", want: true},
+		{name: "IPv6", allow: []string{"fd00::/8"}, ip: "fd00::1", want: true},
+		{name: "Empty lists", ip: "192.168.1.1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewSubnetFilter(tt.allow, tt.deny, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, f.IsAllowed(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func TestSubnetFilterUse(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		wantStatus int
+	}{
+		{name: "Trusted connection address", remoteAddr: "10.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "Untrusted connection address", remoteAddr: "192.168.1.1:5000", wantStatus: http.StatusForbidden},
+		{name: "Spoofed X-Real-IP from untrusted peer", remoteAddr: "192.168.1.1:5000", realIP: "10.0.0.2", wantStatus: http.StatusForbidden},
+		{name: "Ignored X-Real-IP from untrusted peer", remoteAddr: "10.0.0.1:5000", realIP: "192.168.1.1", wantStatus: http.StatusOK},
+		{name: "Trusted X-Real-IP from proxy", remoteAddr: "172.16.0.1:5000", realIP: "10.0.0.2", wantStatus: http.StatusOK},
+		{name: "Untrusted X-Real-IP from proxy", remoteAddr: "172.16.0.1:5000", realIP: "192.168.1.1", wantStatus: http.StatusForbidden},
+		{name: "Denied X-Real-IP from proxy", remoteAddr: "172.16.0.1:5000", realIP: "10.0.1.1", wantStatus: http.StatusForbidden},
+		{name: "Invalid X-Real-IP from proxy", remoteAddr: "172.16.0.1:5000", realIP: "invalid", wantStatus: http.StatusForbidden},
+		{name: "Proxy without X-Real-IP", remoteAddr: "172.16.0.1:5000", wantStatus: http.StatusForbidden},
+		{name: "Denied connection address", remoteAddr: "10.0.1.1:5000", realIP: "10.0.0.2", wantStatus: http.StatusForbidden},
+		{name: "Denied proxy address", remoteAddr: "172.16.1.1:5000", realIP: "10.0.0.2", wantStatus: http.StatusForbidden},
+	}
+	gin.SetMode(gin.TestMode)
+	f, _ := NewSubnetFilter([]string{"10.0.0.0/8"}, []string{"10.0.1.0/24", "172.16.1.0/24"}, []string{"172.16.0.0/16"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, r := gin.CreateTestContext(w)
+			r.POST("/", f.Use(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			c.Request.RemoteAddr = tt.remoteAddr
+			if len(tt.realIP) > 0 {
+				c.Request.Header.Set("X-Real-IP", tt.realIP)
+			}
+			r.ServeHTTP(w, c.Request)
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.wantStatus, result.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Пакет ingest реализует локальную точку приема метрик агентом.
+//
+// Приложения, запущенные на том же хосте, отправляют метрики агенту по тому же протоколу,
+// что и серверу (/update/ и /updates/). Агент объединяет полученные метрики со своими
+// и отправляет их на сервер в общем цикле отправки, поэтому приложениям не нужны
+// ключ подписи и другие параметры доступа к серверу.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/handler"
+	"github.com/k1nky/ypmetrics/internal/handler/middleware"
+	"github.com/k1nky/ypmetrics/internal/usecases/keeper"
+)
+
+// Значения по умолчанию.
+const (
+	DefaultReadTimeout  = 5 * time.Second
+	DefaultWriteTimeout = 5 * time.Second
+	DefaultCloseTimeout = 2 * time.Second
+	// DefaultDialTimeout время ожидания подключения к существующему сокету при запуске.
+	DefaultDialTimeout = time.Second
+	// DefaultMaxBodySize максимальный размер тела запроса.
+	DefaultMaxBodySize = config.DefaultKeeperMaxBodySize
+	// DefaultMaxDecompressedBodySize максимальный размер разжатого тела запроса.
+	DefaultMaxDecompressedBodySize = config.DefaultKeeperMaxDecompressedBodySize
+	// DefaultSocketMode права доступа к unix сокету: отправлять метрики могут владелец и группа.
+	DefaultSocketMode os.FileMode = 0660
+)
+
+// Поддерживаемые типы сетевых адресов.
+const (
+	NetworkTCP  = "tcp"
+	NetworkUnix = "unix"
+)
+
+var (
+	// ErrNotSocket по адресу unix сокета находится файл, который не является сокетом.
+	ErrNotSocket = errors.New("not a socket")
+	// ErrAddressInUse unix сокет уже используется другим процессом, например, другим агентом.
+	ErrAddressInUse = errors.New("address already in use")
+	// ErrNotLoopback TCP адрес не является локальным. Точка приема метрик не проверяет подпись
+	// и токены, поэтому принимать метрики можно только с локального хоста.
+	ErrNotLoopback = errors.New("address is not loopback")
+)
+
+// хранилище метрик агента
+type metricStorage interface {
+	GetCounter(ctx context.Context, name string) *metric.Counter
+	GetGauge(ctx context.Context, name string) *metric.Gauge
+	UpdateCounter(ctx context.Context, name string, value int64) error
+	UpdateGauge(ctx context.Context, name string, value float64) error
+	UpdateMetrics(ctx context.Context, metrics metric.Metrics) error
+	Snapshot(ctx context.Context, metrics *metric.Metrics) error
+}
+
+type logger interface {
+	Debugf(template string, args ...interface{})
+	Infof(template string, args ...interface{})
+	Errorf(template string, args ...interface{})
+}
+
+// Server локальная точка приема метрик. Один сервер может обслуживать несколько адресов,
+// например, TCP порт и unix сокет.
+type Server struct {
+	handler http.Handler
+	logger  logger
+	wg      sync.WaitGroup
+}
+
+// New возвращает новую точку приема метрик, которая сохраняет полученные метрики в store.
+func New(store metricStorage, l logger) *Server {
+	return &Server{
+		handler: NewRouter(store, l),
+		logger:  l,
+	}
+}
+
+// NewRouter возвращает обработчик запросов на изменение метрик в хранилище store.
+// Маршруты и формат запросов совпадают с маршрутами изменения метрик на сервере.
+func NewRouter(store metricStorage, l logger) *gin.Engine {
+	h := handler.New(*keeper.New(store, config.Keeper{}, l))
+
+	router := gin.New()
+	router.Use(middleware.Logger(l))
+	router.Use(middleware.NewBodyLimit(DefaultMaxBodySize).Use())
+	router.Use(middleware.NewGzip([]string{"application/json"}).SetMaxSize(DefaultMaxDecompressedBodySize).Use())
+
+	router.POST("/updates/", middleware.RequireContentType("application/json"), h.UpdatesJSON())
+	updateRoutes := router.Group("/update")
+	updateRoutes.POST("/", middleware.RequireContentType("application/json"), h.UpdateJSON())
+	updateRoutes.POST("/:type/", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+	updateRoutes.POST("/:type/:name/:value", h.Update())
+
+	return router
+}
+
+// Handler возвращает обработчик запросов точки приема метрик.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Serve начинает прием метрик по адресу address в сети network (tcp или unix) и возвращает
+// фактический адрес, на котором принимаются запросы. Прием метрик прекращается при завершении ctx.
+// Для unix сокета оставшийся от предыдущего запуска файл сокета удаляется перед запуском
+// и после завершения работы. Сокет, который используется другим процессом, не удаляется.
+func (s *Server) Serve(ctx context.Context, network string, address string) (net.Addr, error) {
+	listener, err := listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{
+		Handler:      s.handler,
+		ReadTimeout:  DefaultReadTimeout,
+		WriteTimeout: DefaultWriteTimeout,
+	}
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("ingest: unexpected closing %s: %v", listener.Addr(), err)
+		}
+	}()
+	go func() {
+		defer s.wg.Done()
+		<-ctx.Done()
+		s.logger.Debugf("ingest: closing %s", listener.Addr())
+		c, cancel := context.WithTimeout(context.Background(), DefaultCloseTimeout)
+		defer cancel()
+		_ = srv.Shutdown(c)
+	}()
+	return listener.Addr(), nil
+}
+
+// Wait ожидает завершения приема метрик по всем адресам.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// listen открывает адрес для приема запросов. TCP адрес должен быть локальным.
+func listen(network string, address string) (net.Listener, error) {
+	if network != NetworkUnix {
+		if err := checkLoopback(address); err != nil {
+			return nil, err
+		}
+		return net.Listen(network, address)
+	}
+	if err := removeStaleSocket(address); err != nil {
+		return nil, err
+	}
+	// сокет сразу создается с правами DefaultSocketMode
+	return listenUnix(address)
+}
+
+// checkLoopback возвращает ошибку ErrNotLoopback, если address не является локальным адресом.
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s: %w", address, ErrNotLoopback)
+	}
+	return nil
+}
+
+// removeStaleSocket удаляет сокет, который остался после аварийного завершения агента.
+// Если сокет принимает соединения, то возвращается ошибка ErrAddressInUse, а другие файлы
+// не удаляются и возвращается ошибка ErrNotSocket.
+func removeStaleSocket(address string) error {
+	fi, err := os.Lstat(address)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s: %w", address, ErrNotSocket)
+	}
+	conn, err := net.DialTimeout(NetworkUnix, address, DefaultDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s: %w", address, ErrAddressInUse)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return os.Remove(address)
+}
@@ -0,0 +1,205 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/storage"
+)
+
+func TestRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{
+			name:        "Update JSON",
+			path:        "/update/",
+			contentType: "application/json",
+			body:        `{"id":"c0","type":"counter","delta":2}`,
+			want:        http.StatusOK,
+		},
+		{
+			name:        "Updates JSON",
+			path:        "/updates/",
+			contentType: "application/json",
+			body:        `[{"id":"c0","type":"counter","delta":3},{"id":"g0","type":"gauge","value":1.5}]`,
+			want:        http.StatusOK,
+		},
+		{
+			name: "Update plain",
+			path: "/update/gauge/g1/10.5",
+			want: http.StatusOK,
+		},
+		{
+			name:        "Invalid type",
+			path:        "/update/",
+			contentType: "application/json",
+			body:        `{"id":"c0","type":"unknown","delta":2}`,
+			want:        http.StatusBadRequest,
+		},
+		{
+			name:        "Invalid content type",
+			path:        "/updates/",
+			contentType: "text/plain",
+			body:        `[]`,
+			want:        http.StatusBadRequest,
+		},
+		{
+			name: "Without name",
+			path: "/update/gauge/",
+			want: http.StatusNotFound,
+		},
+	}
+	store := storage.NewMemStorage()
+	router := NewRouter(store, log.New())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			if len(tt.contentType) > 0 {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			router.ServeHTTP(w, req)
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.want, result.StatusCode)
+		})
+	}
+	assert.Equal(t, int64(5), store.GetCounter(context.TODO(), "c0").Value)
+	assert.Equal(t, 1.5, store.GetGauge(context.TODO(), "g0").Value)
+	assert.Equal(t, 10.5, store.GetGauge(context.TODO(), "g1").Value)
+}
+
+func TestServe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewMemStorage()
+	s := New(store, log.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tcpAddr, err := s.Serve(ctx, NetworkTCP, "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	if _, err := s.Serve(ctx, NetworkUnix, socket); !assert.NoError(t, err) {
+		return
+	}
+
+	resp, err := http.Post("http://"+tcpAddr.String()+"/update/counter/c0/1", "text/plain", nil)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	unixClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, NetworkUnix, socket)
+			},
+		},
+	}
+	resp, err = unixClient.Post("http://agent/update/counter/c0/2", "text/plain", nil)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Equal(t, int64(3), store.GetCounter(context.TODO(), "c0").Value)
+
+	cancel()
+	s.Wait()
+	assert.NoFileExists(t, socket)
+}
+
+func TestServeStaleSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	// сокет, оставшийся после аварийного завершения
+	stale, err := net.Listen(NetworkUnix, socket)
+	if !assert.NoError(t, err) {
+		return
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := New(storage.NewMemStorage(), log.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = s.Serve(ctx, NetworkUnix, socket)
+	assert.NoError(t, err)
+	cancel()
+	s.Wait()
+}
+
+func TestServeNotSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	if !assert.NoError(t, os.WriteFile(path, []byte("data"), 0600)) {
+		return
+	}
+
+	s := New(storage.NewMemStorage(), log.New())
+	_, err := s.Serve(context.Background(), NetworkUnix, path)
+	assert.ErrorIs(t, err, ErrNotSocket)
+	// файл не удален
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestServeLiveSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	s := New(storage.NewMemStorage(), log.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.Wait()
+	}()
+	if _, err := s.Serve(ctx, NetworkUnix, socket); !assert.NoError(t, err) {
+		return
+	}
+	fi, err := os.Stat(socket)
+	if assert.NoError(t, err) {
+		assert.Equal(t, DefaultSocketMode, fi.Mode().Perm())
+	}
+
+	// второй агент не должен занимать сокет работающего агента
+	_, err = New(storage.NewMemStorage(), log.New()).Serve(ctx, NetworkUnix, socket)
+	assert.ErrorIs(t, err, ErrAddressInUse)
+	conn, err := net.Dial(NetworkUnix, socket)
+	if assert.NoError(t, err) {
+		conn.Close()
+	}
+}
+
+func TestServeNotLoopback(t *testing.T) {
+	s := New(storage.NewMemStorage(), log.New())
+	for _, address := range []string{":0", "0.0.0.0:0", "[::]:0", "example.com:0"} {
+		_, err := s.Serve(context.Background(), NetworkTCP, address)
+		assert.ErrorIs(t, err, ErrNotLoopback, address)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.Wait()
+	}()
+	for _, address := range []string{"localhost:0", "127.0.0.1:0"} {
+		_, err := s.Serve(ctx, NetworkTCP, address)
+		assert.NoError(t, err, address)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package ingest
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix создает unix сокет address с правами DefaultSocketMode.
+func listenUnix(address string) (net.Listener, error) {
+	listener, err := net.Listen(NetworkUnix, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, DefaultSocketMode); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
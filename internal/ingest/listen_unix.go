@@ -0,0 +1,23 @@
+//go:build unix
+
+package ingest
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+// umaskLock защищает изменение umask процесса на время создания сокета.
+var umaskLock sync.Mutex
+
+// listenUnix создает unix сокет address с правами DefaultSocketMode. Права задаются через umask,
+// поэтому сокет ни в какой момент не доступен другим пользователям. Umask действует на весь процесс,
+// поэтому меняется только на время создания сокета.
+func listenUnix(address string) (net.Listener, error) {
+	umaskLock.Lock()
+	defer umaskLock.Unlock()
+	old := syscall.Umask(int(0777 &^ DefaultSocketMode))
+	defer syscall.Umask(old)
+	return net.Listen(NetworkUnix, address)
+}
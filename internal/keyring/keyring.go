@@ -0,0 +1,165 @@
+// Пакет keyring реализует набор именованных ключей подписи (HMAC) с периодом действия.
+// Набор ключей позволяет менять ключ подписи постепенно: сервер принимает подписи как
+// старым, так и новым ключом, пока агенты не переключатся на новый.
+//
+// Формат файла с ключами:
+//
+//	{
+//		"keys": [
+//			{"id": "2023-10", "secret": "old", "not_after": "2023-11-15T00:00:00Z"},
+//			{"id": "2023-11", "secret": "new", "not_before": "2023-11-01T00:00:00Z"}
+//		]
+//	}
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrKeyNotFound ключ с указанным идентификатором не найден.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyNotActive ключ найден, но еще не действует или уже не действует.
+	ErrKeyNotActive = errors.New("key is not active")
+	// ErrInvalidKey ключ задан неверно.
+	ErrInvalidKey = errors.New("invalid key")
+)
+
+// Key именованный ключ подписи.
+type Key struct {
+	// ID идентификатор ключа, передается агентом в заголовке Key-ID.
+	ID string `json:"id"`
+	// Secret секрет для формирования подписи.
+	Secret string `json:"secret"`
+	// NotBefore ключ действует начиная с указанного момента. Нулевое значение - без ограничения.
+	NotBefore time.Time `json:"not_before,omitempty"`
+	// NotAfter ключ действует до указанного момента. Нулевое значение - без ограничения.
+	NotAfter time.Time `json:"not_after,omitempty"`
+}
+
+// IsActive возвращает true, если ключ действует в момент at.
+func (k Key) IsActive(at time.Time) bool {
+	if !k.NotBefore.IsZero() && at.Before(k.NotBefore) {
+		return false
+	}
+	if !k.NotAfter.IsZero() && at.After(k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// Keyring потокобезопасный набор ключей подписи.
+// Помимо именованных ключей может содержать ключ по умолчанию, который используется,
+// если идентификатор ключа не указан.
+type Keyring struct {
+	lock       sync.RWMutex
+	defaultKey *Key
+	keys       map[string]Key
+	// увеличивается при каждом изменении набора ключей
+	version uint64
+}
+
+// keyringFile формат файла с ключами.
+type keyringFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// New возвращает новый пустой набор ключей.
+func New() *Keyring {
+	return &Keyring{
+		keys: make(map[string]Key),
+	}
+}
+
+// SetDefault задает ключ по умолчанию. Пустой secret удаляет ключ по умолчанию.
+func (kr *Keyring) SetDefault(secret string) {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	kr.version++
+	if len(secret) == 0 {
+		kr.defaultKey = nil
+		return
+	}
+	kr.defaultKey = &Key{Secret: secret}
+}
+
+// Lookup возвращает ключ с идентификатором id, действующий в момент at.
+// Для пустого id возвращается ключ по умолчанию.
+func (kr *Keyring) Lookup(id string, at time.Time) (Key, error) {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+
+	if len(id) == 0 {
+		if kr.defaultKey == nil {
+			return Key{}, ErrKeyNotFound
+		}
+		return *kr.defaultKey, nil
+	}
+	k, ok := kr.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	if !k.IsActive(at) {
+		return Key{}, ErrKeyNotActive
+	}
+	return k, nil
+}
+
+// Empty возвращает true, если в наборе нет ни одного ключа.
+func (kr *Keyring) Empty() bool {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+	return kr.defaultKey == nil && len(kr.keys) == 0
+}
+
+// Version возвращает номер версии набора ключей, который меняется при каждом изменении набора.
+// Позволяет сбрасывать данные, вычисленные для прежних ключей.
+func (kr *Keyring) Version() uint64 {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+	return kr.version
+}
+
+// Load заменяет именованные ключи набора ключами, прочитанными из r в формате JSON.
+// Ключ по умолчанию не изменяется. Если хотя бы один ключ задан неверно, набор остается прежним.
+func (kr *Keyring) Load(r io.Reader) error {
+	f := keyringFile{}
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return err
+	}
+	keys := make(map[string]Key, len(f.Keys))
+	for _, k := range f.Keys {
+		if len(k.ID) == 0 || len(k.Secret) == 0 {
+			return fmt.Errorf("key %q: empty id or secret: %w", k.ID, ErrInvalidKey)
+		}
+		if _, ok := keys[k.ID]; ok {
+			return fmt.Errorf("key %q: duplicate id: %w", k.ID, ErrInvalidKey)
+		}
+		if !k.NotBefore.IsZero() && !k.NotAfter.IsZero() && k.NotAfter.Before(k.NotBefore) {
+			return fmt.Errorf("key %q: not_after is before not_before: %w", k.ID, ErrInvalidKey)
+		}
+		keys[k.ID] = k
+	}
+
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	kr.keys = keys
+	kr.version++
+	return nil
+}
+
+// LoadFile загружает именованные ключи из файла path.
+func (kr *Keyring) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return kr.Load(f)
+}
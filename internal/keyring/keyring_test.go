@@ -0,0 +1,96 @@
+package keyring
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyIsActive(t *testing.T) {
+	now := time.Date(2023, 11, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		key  Key
+		want bool
+	}{
+		{name: "Without window", key: Key{}, want: true},
+		{name: "Inside window", key: Key{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}, want: true},
+		{name: "Not yet valid", key: Key{NotBefore: now.Add(time.Hour)}, want: false},
+		{name: "Expired", key: Key{NotAfter: now.Add(-time.Hour)}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.key.IsActive(now))
+		})
+	}
+}
+
+func TestKeyringLookup(t *testing.T) {
+	now := time.Date(2023, 11, 10, 0, 0, 0, 0, time.UTC)
+	kr := New()
+	assert.True(t, kr.Empty())
+	kr.SetDefault("default")
+	err := kr.Load(strings.NewReader(`{
+		"keys": [
+			{"id": "old", "secret": "s1", "not_after": "2023-11-01T00:00:00Z"},
+			{"id": "new", "secret": "s2", "not_before": "2023-11-01T00:00:00Z"}
+		]
+	}`))
+	assert.NoError(t, err)
+	assert.False(t, kr.Empty())
+
+	k, err := kr.Lookup("", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", k.Secret)
+
+	k, err = kr.Lookup("new", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "s2", k.Secret)
+
+	_, err = kr.Lookup("old", now)
+	assert.ErrorIs(t, err, ErrKeyNotActive)
+
+	_, err = kr.Lookup("unknown", now)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestKeyringLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Invalid JSON", data: `{"keys": [`},
+		{name: "Empty id", data: `{"keys": [{"secret": "s1"}]}`},
+		{name: "Empty secret", data: `{"keys": [{"id": "k1"}]}`},
+		{name: "Duplicate id", data: `{"keys": [{"id": "k1", "secret": "s1"}, {"id": "k1", "secret": "s2"}]}`},
+		{name: "Invalid window", data: `{"keys": [{"id": "k1", "secret": "s1", "not_before": "2023-11-02T00:00:00Z", "not_after": "2023-11-01T00:00:00Z"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr := New()
+			assert.NoError(t, kr.Load(strings.NewReader(`{"keys": [{"id": "k0", "secret": "s0"}]}`)))
+			assert.Error(t, kr.Load(strings.NewReader(tt.data)))
+			// при ошибке набор ключей не меняется
+			_, err := kr.Lookup("k0", time.Now())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestKeyringLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, []byte(`{"keys": [{"id": "k1", "secret": "s1"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	kr := New()
+	assert.NoError(t, kr.LoadFile(path))
+	k, err := kr.Lookup("k1", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", k.Secret)
+
+	assert.Error(t, kr.LoadFile(filepath.Join(t.TempDir(), "unknown.json")))
+}
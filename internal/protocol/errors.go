@@ -0,0 +1,8 @@
+package protocol
+
+import "errors"
+
+var (
+	// ErrBodyTooLarge размер тела запроса превышает допустимый.
+	ErrBodyTooLarge = errors.New("request body too large")
+)
@@ -1,5 +1,11 @@
 package protocol
 
+// Типы метрик.
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+)
+
 //go:generate easyjson metrics.go
 //easyjson:json
 type Metrics struct {
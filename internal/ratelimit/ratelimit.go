@@ -0,0 +1,165 @@
+// Пакет ratelimit реализует ограничение частоты запросов по алгоритму token bucket.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSweepInterval интервал удаления неиспользуемых корзин в Limiter.
+	DefaultSweepInterval = time.Minute
+)
+
+// Bucket корзина токенов. Токены пополняются со скоростью rate в секунду,
+// но не более burst штук. Каждое действие расходует один токен.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBucket возвращает новую полную корзину со скоростью пополнения rate токенов в секунду
+// и емкостью burst. Емкость не может быть меньше одного токена.
+func NewBucket(rate float64, burst int) *Bucket {
+	return newBucket(rate, burst, time.Now)
+}
+
+func newBucket(rate float64, burst int, now func() time.Time) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+// Allow расходует токен, если он есть, и возвращает true. Иначе возвращает false и время,
+// через которое появится следующий токен.
+func (b *Bucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, b.waitTime()
+}
+
+// Wait ожидает появления токена и расходует его. Возвращает ошибку, если контекст
+// завершился раньше.
+func (b *Bucket) Wait(ctx context.Context) error {
+	for {
+		ok, wait := b.Allow()
+		if ok {
+			return nil
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// isFull возвращает true, если корзина полностью пополнена.
+func (b *Bucket) isFull() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	return b.tokens >= b.burst
+}
+
+func (b *Bucket) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+}
+
+func (b *Bucket) waitTime() time.Duration {
+	if b.rate <= 0 {
+		// токены не пополняются
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Limiter набор корзин токенов с отдельной корзиной для каждого ключа.
+// Корзины, которые успели полностью пополниться, периодически удаляются,
+// т.к. они не отличаются от новых.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter возвращает новый Limiter, в котором каждая корзина пополняется со скоростью
+// rate токенов в секунду и имеет емкость burst.
+func NewLimiter(rate float64, burst int) *Limiter {
+	return newLimiter(rate, burst, time.Now)
+}
+
+func newLimiter(rate float64, burst int, now func() time.Time) *Limiter {
+	return &Limiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[string]*Bucket),
+		lastSweep: now(),
+		now:       now,
+	}
+}
+
+// Allow расходует токен из корзины ключа key. Возвращает false и время ожидания следующего токена,
+// если токенов в корзине нет.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.bucket(key).Allow()
+}
+
+// Len возвращает количество корзин.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+func (l *Limiter) bucket(key string) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := l.now(); now.Sub(l.lastSweep) >= DefaultSweepInterval {
+		l.lastSweep = now
+		for k, b := range l.buckets {
+			if b.isFull() {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(l.rate, l.burst, l.now)
+		l.buckets[key] = b
+	}
+	return b
+}
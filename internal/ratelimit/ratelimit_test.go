@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestBucketAllow(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	b := newBucket(2, 3, clock.Now)
+
+	for i := 0; i < 3; i++ {
+		ok, _ := b.Allow()
+		assert.True(t, ok)
+	}
+	ok, wait := b.Allow()
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	clock.Add(500 * time.Millisecond)
+	ok, _ = b.Allow()
+	assert.True(t, ok)
+
+	// корзина не пополняется больше емкости
+	clock.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ := b.Allow()
+		assert.True(t, ok)
+	}
+	ok, _ = b.Allow()
+	assert.False(t, ok)
+}
+
+func TestBucketMinBurst(t *testing.T) {
+	b := NewBucket(1, 0)
+	ok, _ := b.Allow()
+	assert.True(t, ok)
+	ok, _ = b.Allow()
+	assert.False(t, ok)
+}
+
+func TestBucketWait(t *testing.T) {
+	b := NewBucket(100, 1)
+	assert.NoError(t, b.Wait(context.Background()))
+	assert.NoError(t, b.Wait(context.Background()))
+
+	b = NewBucket(0.001, 1)
+	b.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestLimiter(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	l := newLimiter(1, 1, clock.Now)
+
+	ok, _ := l.Allow("a")
+	assert.True(t, ok)
+	ok, _ = l.Allow("a")
+	assert.False(t, ok)
+	// у другого ключа своя корзина
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, l.Len())
+
+	// полностью пополненные корзины удаляются
+	clock.Add(DefaultSweepInterval)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, l.Len())
+}
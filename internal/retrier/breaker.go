@@ -0,0 +1,125 @@
+package retrier
+
+import (
+	"sync"
+	"time"
+)
+
+// State состояние предохранителя.
+type State int
+
+// Состояния предохранителя.
+const (
+	// StateClosed вызовы выполняются.
+	StateClosed State = iota
+	// StateOpen вызовы не выполняются до истечения времени восстановления.
+	StateOpen
+	// StateHalfOpen выполняется пробный вызов, от результата которого зависит следующее состояние.
+	StateHalfOpen
+)
+
+// String возвращает название состояния.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker предохранитель (circuit breaker). После threshold неудачных вызовов подряд предохранитель
+// размыкается и в течение cooldown отклоняет вызовы. Затем разрешается один пробный вызов:
+// при успехе предохранитель замыкается, при неудаче снова размыкается.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+// NewBreaker возвращает новый замкнутый предохранитель. Значение threshold меньше 1 считается равным 1.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     StateClosed,
+		now:       time.Now,
+	}
+}
+
+// Allow возвращает true, если вызов можно выполнить.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		// время восстановления истекло, пропускаем пробный вызов
+		b.state = StateHalfOpen
+		return true
+	case StateHalfOpen:
+		// пробный вызов уже выполняется
+		return false
+	}
+	return true
+}
+
+// Record учитывает результат вызова: success - вызов завершился успешно.
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		b.state = StateClosed
+		return
+	}
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release отменяет пробный вызов, результат которого неизвестен. Следующий вызов снова будет пробным.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.state = StateOpen
+	}
+}
+
+// State возвращает текущее состояние предохранителя.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		// следующий вызов будет пробным
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Failures возвращает количество неудачных вызовов подряд.
+func (b *Breaker) Failures() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures
+}
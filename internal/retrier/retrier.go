@@ -2,60 +2,187 @@
 // если предыдущий вызов завершился с ошибкой.
 package retrier
 
-import "time"
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen вызов не выполнялся, т.к. предохранитель разомкнут.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
 
 // ShouldRetry сигнатура функции, с помощью который определяется следует ли повторить вызов целевой функции.
 // Функция должна проверить ошибку err и вернуть true - попробовать еще раз или false - остановиться.
 type ShouldRetry func(err error) bool
 
-// Retrier контроллер повторного выполнения.
+// Policy политика повторного выполнения. Задержка между попытками растет экспоненциально:
+// InitialInterval * Multiplier^(n-1), но не более MaxInterval.
+type Policy struct {
+	// MaxAttempts максимальное количество попыток, включая первую. 0 - без ограничений.
+	MaxAttempts int
+	// InitialInterval задержка перед первым повтором.
+	InitialInterval time.Duration
+	// MaxInterval максимальная задержка между попытками. 0 - без ограничений.
+	MaxInterval time.Duration
+	// Multiplier множитель задержки. Значения меньше 1 считаются равными 1.
+	Multiplier float64
+	// MaxElapsedTime максимальное время от первой попытки, после которого повторы прекращаются.
+	// 0 - без ограничений.
+	MaxElapsedTime time.Duration
+	// Jitter использовать случайную задержку в интервале [0, задержка) (full jitter).
+	// Позволяет разнести во времени повторы от разных клиентов.
+	Jitter bool
+}
+
+// DefaultPolicy политика по умолчанию: до трех повторов с задержкой 1, 2 и 4 секунды.
+var DefaultPolicy = Policy{
+	MaxAttempts:     4,
+	InitialInterval: time.Second,
+	MaxInterval:     5 * time.Second,
+	Multiplier:      2,
+	MaxElapsedTime:  15 * time.Second,
+	Jitter:          false,
+}
+
+// Interval возвращает задержку перед повтором номер retry (начиная с 1) без учета Jitter.
+func (p Policy) Interval(retry int) time.Duration {
+	if retry < 1 {
+		return 0
+	}
+	multiplier := math.Max(p.Multiplier, 1)
+	interval := float64(p.InitialInterval) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxInterval > 0 && interval > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+	if interval > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(interval)
+}
+
+// Retrier контроллер повторного выполнения. Может использоваться одновременно из нескольких горутин.
 //
-//	for r := retrier.New(myShouldRetry); r.Next(err); {
-//		err = doSomething()
-//	}
+//	r := retrier.New()
+//	err := r.Do(ctx, retrier.AlwaysRetry, func(ctx context.Context) error {
+//		return doSomething(ctx)
+//	})
 type Retrier struct {
-	// счетчик попыток
-	attempt int
-	// задержка между попытками
-	retries []time.Duration
-	// функция, которая проверяет ошибку на Retriable
-	shouldRetry ShouldRetry
+	policy  Policy
+	breaker *Breaker
+	// источник случайных чисел для Jitter
+	randMu sync.Mutex
+	rand   *rand.Rand
 }
 
-// New Возвращает новый контроллер повторного выполнения.
+// New Возвращает новый контроллер повторного выполнения с политикой DefaultPolicy.
 func New() *Retrier {
+	return NewWithPolicy(DefaultPolicy)
+}
+
+// NewWithPolicy возвращает новый контроллер повторного выполнения с политикой policy.
+func NewWithPolicy(policy Policy) *Retrier {
 	return &Retrier{
-		attempt: 0,
-		// по умолчанию 1 сек, 3 сек, 5 сек
-		retries: []time.Duration{time.Second, 3 * time.Second, 5 * time.Second},
+		policy: policy,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
-// Init задает для контроллера функцию, с помощью которой
-// будет определяться необходимость повторного вызова функции.
-func (r *Retrier) Init(shouldRetry func(error) bool) {
-	r.attempt = 0
-	r.shouldRetry = shouldRetry
+// SetBreaker задает предохранитель. Пока предохранитель разомкнут, Do сразу возвращает ErrCircuitOpen.
+func (r *Retrier) SetBreaker(b *Breaker) *Retrier {
+	r.breaker = b
+	return r
+}
+
+// Breaker возвращает предохранитель или nil, если он не задан.
+func (r *Retrier) Breaker() *Breaker {
+	return r.breaker
+}
+
+// Policy возвращает политику повторного выполнения.
+func (r *Retrier) Policy() Policy {
+	return r.policy
+}
+
+// Do вызывает функцию f и повторяет вызов, пока f возвращает ошибку, для которой shouldRetry
+// возвращает true, и политика допускает еще одну попытку. Ожидание между попытками прерывается
+// при завершении ctx, в этом случае возвращается ошибка последней попытки вместе с ошибкой контекста.
+//
+// Предохранитель учитывает только ошибки, для которых shouldRetry возвращает true.
+func (r *Retrier) Do(ctx context.Context, shouldRetry ShouldRetry, f func(ctx context.Context) error) error {
+	var lastErr error
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		if r.breaker != nil && !r.breaker.Allow() {
+			if lastErr != nil {
+				// предохранитель разомкнулся во время повторов
+				return errors.Join(lastErr, ErrCircuitOpen)
+			}
+			return ErrCircuitOpen
+		}
+		err := f(ctx)
+		if err != nil && ctx.Err() != nil {
+			// вызов прерван по контексту, поэтому не учитываем его в предохранителе
+			if r.breaker != nil {
+				r.breaker.release()
+			}
+			if errors.Is(err, ctx.Err()) {
+				return err
+			}
+			return errors.Join(err, ctx.Err())
+		}
+		lastErr = err
+		retriable := err != nil && shouldRetry(err)
+		if r.breaker != nil {
+			r.breaker.Record(!retriable)
+		}
+		if !retriable {
+			return err
+		}
+		if r.policy.MaxAttempts > 0 && attempt >= r.policy.MaxAttempts {
+			return err
+		}
+		wait := r.wait(attempt)
+		if r.policy.MaxElapsedTime > 0 && time.Since(start)+wait > r.policy.MaxElapsedTime {
+			return err
+		}
+		if waitErr := sleep(ctx, wait); waitErr != nil {
+			return errors.Join(err, waitErr)
+		}
+	}
+}
+
+// wait возвращает задержку перед повтором номер retry с учетом Jitter.
+func (r *Retrier) wait(retry int) time.Duration {
+	interval := r.policy.Interval(retry)
+	if !r.policy.Jitter || interval <= 0 {
+		return interval
+	}
+	r.randMu.Lock()
+	defer r.randMu.Unlock()
+	return time.Duration(r.rand.Int63n(int64(interval)))
 }
 
-// Возвращает true если следует повторить действие. False - нет ошибки или попытки кончались.
-func (r *Retrier) Next(err error) bool {
-	// всегда увеличиваем счетчик попыток
-	defer func() {
-		r.attempt += 1
-	}()
-	if r.attempt == 0 {
-		// еще не было попыток
-		return true
+// sleep ожидает в течение d или до завершения ctx.
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	if err == nil {
-		return false
+	if d <= 0 {
+		return nil
 	}
-	if (r.attempt <= len(r.retries)) && r.shouldRetry(err) {
-		time.Sleep(r.retries[r.attempt-1])
-		return true
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
-	return false
 }
 
 // AlwaysRetry удобно использовать, если планируем повторять при любой ошибке.
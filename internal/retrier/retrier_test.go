@@ -1,6 +1,7 @@
 package retrier
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -8,47 +9,184 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var errFake = errors.New("fake error")
+
 func TestRetrier(t *testing.T) {
-	type args struct {
-		err         error
-		retries     []time.Duration
-		shouldRetry ShouldRetry
-	}
 	tests := []struct {
 		name         string
-		args         args
+		err          error
+		policy       Policy
+		shouldRetry  ShouldRetry
 		wantAttempts int
 	}{
 		{
 			name:         "No retry",
 			wantAttempts: 1,
-			args:         args{err: errors.New("fake error"), retries: []time.Duration{}, shouldRetry: AlwaysRetry},
+			err:          errFake,
+			policy:       Policy{MaxAttempts: 1},
+			shouldRetry:  AlwaysRetry,
 		},
 		{
 			name:         "No error",
 			wantAttempts: 1,
-			args:         args{err: nil, retries: []time.Duration{time.Millisecond, time.Millisecond}, shouldRetry: AlwaysRetry},
+			err:          nil,
+			policy:       Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+			shouldRetry:  AlwaysRetry,
 		},
 		{
 			name:         "N times",
 			wantAttempts: 3,
-			args:         args{err: errors.New("fake error"), retries: []time.Duration{time.Millisecond, time.Millisecond}, shouldRetry: AlwaysRetry},
+			err:          errFake,
+			policy:       Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+			shouldRetry:  AlwaysRetry,
+		},
+		{
+			name:         "Not retriable error",
+			wantAttempts: 1,
+			err:          errFake,
+			policy:       Policy{MaxAttempts: 3, InitialInterval: time.Millisecond},
+			shouldRetry:  func(err error) bool { return false },
+		},
+		{
+			name:         "Max elapsed time",
+			wantAttempts: 2,
+			err:          errFake,
+			policy:       Policy{InitialInterval: 20 * time.Millisecond, Multiplier: 10, MaxElapsedTime: 100 * time.Millisecond},
+			shouldRetry:  AlwaysRetry,
+		},
+		{
+			name:         "With jitter",
+			wantAttempts: 5,
+			err:          errFake,
+			policy:       Policy{MaxAttempts: 5, InitialInterval: time.Millisecond, Jitter: true},
+			shouldRetry:  AlwaysRetry,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := &Retrier{
-				attempt:     0,
-				retries:     tt.args.retries,
-				shouldRetry: tt.args.shouldRetry,
-			}
+			r := NewWithPolicy(tt.policy)
 			attemptCount := 0
-			var err error
-			for r.Next(err) {
-				err = tt.args.err
+			err := r.Do(context.Background(), tt.shouldRetry, func(ctx context.Context) error {
 				attemptCount += 1
-			}
+				return tt.err
+			})
 			assert.Equal(t, tt.wantAttempts, attemptCount)
+			assert.Equal(t, tt.err, err)
 		})
 	}
 }
+
+func TestPolicyInterval(t *testing.T) {
+	p := Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: 5 * time.Second}
+	assert.Equal(t, time.Duration(0), p.Interval(0))
+	assert.Equal(t, time.Second, p.Interval(1))
+	assert.Equal(t, 2*time.Second, p.Interval(2))
+	assert.Equal(t, 4*time.Second, p.Interval(3))
+	assert.Equal(t, 5*time.Second, p.Interval(4))
+	assert.Equal(t, 5*time.Second, p.Interval(100))
+	// множитель меньше 1 не уменьшает задержку
+	p = Policy{InitialInterval: time.Second, Multiplier: 0}
+	assert.Equal(t, time.Second, p.Interval(3))
+}
+
+func TestRetrierJitter(t *testing.T) {
+	r := NewWithPolicy(Policy{InitialInterval: time.Second, Multiplier: 2, Jitter: true})
+	for i := 0; i < 100; i++ {
+		w := r.wait(2)
+		assert.GreaterOrEqual(t, w, time.Duration(0))
+		assert.Less(t, w, 2*time.Second)
+	}
+}
+
+func TestRetrierContext(t *testing.T) {
+	r := NewWithPolicy(Policy{InitialInterval: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	attemptCount := 0
+	start := time.Now()
+	err := r.Do(ctx, AlwaysRetry, func(ctx context.Context) error {
+		attemptCount++
+		return errFake
+	})
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, attemptCount)
+	assert.ErrorIs(t, err, errFake)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRetrierBreaker(t *testing.T) {
+	b := NewBreaker(2, time.Hour)
+	r := NewWithPolicy(Policy{MaxAttempts: 5}).SetBreaker(b)
+	attemptCount := 0
+	err := r.Do(context.Background(), AlwaysRetry, func(ctx context.Context) error {
+		attemptCount++
+		return errFake
+	})
+	// после двух неудачных попыток предохранитель размыкается
+	assert.Equal(t, 2, attemptCount)
+	assert.ErrorIs(t, err, errFake)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, StateOpen, b.State())
+
+	err = r.Do(context.Background(), AlwaysRetry, func(ctx context.Context) error {
+		attemptCount++
+		return nil
+	})
+	assert.Equal(t, 2, attemptCount)
+	assert.Equal(t, ErrCircuitOpen, err)
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.Equal(t, StateClosed, b.State())
+	assert.True(t, b.Allow())
+	b.Record(false)
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, 1, b.Failures())
+	// успешный вызов сбрасывает счетчик неудач
+	b.Record(true)
+	assert.Equal(t, 0, b.Failures())
+
+	b.Record(false)
+	b.Record(false)
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.Allow())
+
+	// время восстановления истекло - разрешен один пробный вызов
+	now = now.Add(time.Minute)
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+	// пробный вызов неудачный
+	b.Record(false)
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.Allow())
+	b.Record(true)
+	assert.Equal(t, StateClosed, b.State())
+	assert.True(t, b.Allow())
+	assert.Equal(t, "closed", b.State().String())
+}
+
+func TestRetrierCanceledCall(t *testing.T) {
+	b := NewBreaker(1, 0)
+	r := NewWithPolicy(Policy{MaxAttempts: 3}).SetBreaker(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	attemptCount := 0
+	err := r.Do(ctx, AlwaysRetry, func(ctx context.Context) error {
+		attemptCount++
+		cancel()
+		return errFake
+	})
+	// прерванный вызов не повторяется и не размыкает предохранитель
+	assert.Equal(t, 1, attemptCount)
+	assert.ErrorIs(t, err, errFake)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, 0, b.Failures())
+}
@@ -0,0 +1,245 @@
+// Пакет status реализует HTTP обработчик для просмотра состояния агента.
+//
+// Доступны маршруты:
+//   - GET /status - текущие значения метрик, состояние сборщиков, отправки и повторов, конфигурация агента в формате JSON;
+//   - GET /metrics - то же в текстовом формате Prometheus (без конфигурации);
+//   - GET /healthz - проверка работоспособности: 503, если подряд не удалось отправить заданное количество запросов.
+package status
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/protocol"
+	"github.com/k1nky/ypmetrics/internal/retrier"
+	"github.com/k1nky/ypmetrics/internal/usecases/poller"
+)
+
+// хранилище метрик агента
+type metricStorage interface {
+	Snapshot(ctx context.Context, metrics *metric.Metrics) error
+}
+
+// источник состояния опроса и отправки метрик
+type statusSource interface {
+	Status() poller.Status
+}
+
+// RetryStatus состояние повторной отправки метрик.
+type RetryStatus struct {
+	// State состояние автоматического выключателя отправки.
+	State string `json:"state"`
+	// Failures количество неудачных отправок подряд, учтенных выключателем.
+	Failures int `json:"failures"`
+}
+
+// Report состояние агента.
+type Report struct {
+	// Healthy признак работоспособности агента.
+	Healthy bool `json:"healthy"`
+	// Metrics текущие значения метрик, упорядоченные по типу и имени.
+	Metrics []protocol.Metrics `json:"metrics"`
+	// Collectors состояние сборщиков метрик.
+	Collectors map[string]poller.CollectorStatus `json:"collectors"`
+	// Push состояние отправки метрик.
+	Push poller.PushStatus `json:"push"`
+	// Retry состояние повторной отправки метрик, если используется выключатель.
+	Retry *RetryStatus `json:"retry,omitempty"`
+	// Config конфигурация агента без секретов.
+	Config config.Poller `json:"config"`
+}
+
+// Handler обработчик запросов состояния агента.
+type Handler struct {
+	storage metricStorage
+	source  statusSource
+	breaker *retrier.Breaker
+	config  config.Poller
+}
+
+// New возвращает обработчик запросов состояния агента с метриками из store,
+// состоянием опроса и отправки из source и конфигурацией cfg.
+func New(store metricStorage, source statusSource, cfg config.Poller) *Handler {
+	return &Handler{
+		storage: store,
+		source:  source,
+		config:  cfg.Redacted(),
+	}
+}
+
+// SetBreaker задает выключатель отправки метрик, состояние которого будет показано в отчете.
+func (h *Handler) SetBreaker(b *retrier.Breaker) *Handler {
+	h.breaker = b
+	return h
+}
+
+// Router возвращает маршрутизатор запросов состояния агента.
+func (h *Handler) Router() *gin.Engine {
+	router := gin.New()
+	router.GET("/status", h.Status())
+	router.GET("/metrics", h.Metrics())
+	router.GET("/healthz", h.Health())
+	return router
+}
+
+// Status обработчик вывода состояния агента в формате JSON.
+func (h *Handler) Status() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report, err := h.report(ctx.Request.Context())
+		if err != nil {
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx.JSON(http.StatusOK, report)
+	}
+}
+
+// Metrics обработчик вывода состояния агента в текстовом формате Prometheus.
+func (h *Handler) Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report, err := h.report(ctx.Request.Context())
+		if err != nil {
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(formatPrometheus(report)))
+	}
+}
+
+// Health обработчик проверки работоспособности агента.
+func (h *Handler) Health() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		push := h.source.Status().Push
+		if !h.isHealthy(push) {
+			ctx.String(http.StatusServiceUnavailable, "push failed %d times in a row: %s\n", push.ConsecutiveFailures, push.LastError)
+			return
+		}
+		ctx.String(http.StatusOK, "ok\n")
+	}
+}
+
+// isHealthy возвращает false, если количество неудачных отправок подряд достигло Config.HealthPushFailures.
+// Нулевое значение отключает проверку.
+func (h *Handler) isHealthy(push poller.PushStatus) bool {
+	return h.config.HealthPushFailures == 0 || push.ConsecutiveFailures < h.config.HealthPushFailures
+}
+
+// report собирает текущее состояние агента.
+func (h *Handler) report(ctx context.Context) (Report, error) {
+	metrics := metric.Metrics{}
+	if err := h.storage.Snapshot(ctx, &metrics); err != nil {
+		return Report{}, err
+	}
+	status := h.source.Status()
+	report := Report{
+		Healthy:    h.isHealthy(status.Push),
+		Metrics:    make([]protocol.Metrics, 0, len(metrics.Counters)+len(metrics.Gauges)),
+		Collectors: status.Collectors,
+		Push:       status.Push,
+		Config:     h.config,
+	}
+	sort.Slice(metrics.Counters, func(i, j int) bool { return metrics.Counters[i].Name < metrics.Counters[j].Name })
+	sort.Slice(metrics.Gauges, func(i, j int) bool { return metrics.Gauges[i].Name < metrics.Gauges[j].Name })
+	for _, m := range metrics.Counters {
+		report.Metrics = append(report.Metrics, protocol.Metrics{ID: m.Name, MType: protocol.TypeCounter, Delta: &m.Value})
+	}
+	for _, m := range metrics.Gauges {
+		report.Metrics = append(report.Metrics, protocol.Metrics{ID: m.Name, MType: protocol.TypeGauge, Value: &m.Value})
+	}
+	if h.breaker != nil {
+		report.Retry = &RetryStatus{
+			State:    h.breaker.State().String(),
+			Failures: h.breaker.Failures(),
+		}
+	}
+	return report, nil
+}
+
+// formatPrometheus возвращает отчет в текстовом формате Prometheus. Метрики агента выводятся
+// под своими именами, а состояние агента - в метриках с префиксом agent_. Если разные
+// метрики приводятся к одному имени, к повторным именам добавляется числовой суффикс.
+func formatPrometheus(report Report) string {
+	s := &strings.Builder{}
+	names := make(map[string]bool, len(report.Metrics))
+	for _, m := range report.Metrics {
+		name := uniqueName(names, prometheusName(m.ID))
+		fmt.Fprintf(s, "# TYPE %s %s\n", name, m.MType)
+		if m.Delta != nil {
+			fmt.Fprintf(s, "%s %d\n", name, *m.Delta)
+		} else if m.Value != nil {
+			fmt.Fprintf(s, "%s %g\n", name, *m.Value)
+		}
+	}
+
+	collectors := make([]string, 0, len(report.Collectors))
+	for name := range report.Collectors {
+		collectors = append(collectors, name)
+	}
+	sort.Strings(collectors)
+	fmt.Fprintf(s, "# TYPE agent_collector_last_success_timestamp_seconds gauge\n")
+	for _, name := range collectors {
+		fmt.Fprintf(s, "agent_collector_last_success_timestamp_seconds{collector=%q} %d\n", name, unixTime(report.Collectors[name].LastSuccess))
+	}
+	fmt.Fprintf(s, "# TYPE agent_collector_last_error_timestamp_seconds gauge\n")
+	for _, name := range collectors {
+		fmt.Fprintf(s, "agent_collector_last_error_timestamp_seconds{collector=%q} %d\n", name, unixTime(report.Collectors[name].LastErrorTime))
+	}
+
+	fmt.Fprintf(s, "# TYPE agent_push_last_timestamp_seconds gauge\nagent_push_last_timestamp_seconds %d\n", unixTime(report.Push.LastTime))
+	fmt.Fprintf(s, "# TYPE agent_push_last_success_timestamp_seconds gauge\nagent_push_last_success_timestamp_seconds %d\n", unixTime(report.Push.LastSuccess))
+	fmt.Fprintf(s, "# TYPE agent_push_consecutive_failures gauge\nagent_push_consecutive_failures %d\n", report.Push.ConsecutiveFailures)
+	if report.Retry != nil {
+		fmt.Fprintf(s, "# TYPE agent_retry_breaker_open gauge\nagent_retry_breaker_open %d\n", boolToInt(report.Retry.State == retrier.StateOpen.String()))
+		fmt.Fprintf(s, "# TYPE agent_retry_breaker_failures gauge\nagent_retry_breaker_failures %d\n", report.Retry.Failures)
+	}
+	fmt.Fprintf(s, "# TYPE agent_healthy gauge\nagent_healthy %d\n", boolToInt(report.Healthy))
+	return s.String()
+}
+
+// prometheusName приводит имя метрики к допустимому в Prometheus виду,
+// заменяя недопустимые символы на подчеркивание.
+func prometheusName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == ':'
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// uniqueName возвращает имя, которого еще нет в names, добавляя к нему суффикс _2, _3 и т.д.,
+// и запоминает его.
+func uniqueName(names map[string]bool, name string) string {
+	unique := name
+	for i := 2; names[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	names[unique] = true
+	return unique
+}
+
+// unixTime возвращает время в секундах Unix или 0 для нулевого времени.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
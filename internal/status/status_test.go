@@ -0,0 +1,151 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/protocol"
+	"github.com/k1nky/ypmetrics/internal/retrier"
+	"github.com/k1nky/ypmetrics/internal/storage"
+	"github.com/k1nky/ypmetrics/internal/usecases/poller"
+)
+
+type fakeSource struct {
+	status poller.Status
+}
+
+func (s *fakeSource) Status() poller.Status {
+	return s.status
+}
+
+func newTestHandler(t *testing.T, source *fakeSource) *Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := storage.NewMemStorage()
+	store.UpdateMetrics(context.Background(), metric.Metrics{
+		Counters: []*metric.Counter{metric.NewCounter("PollCount", 5)},
+		Gauges:   []*metric.Gauge{metric.NewGauge("Alloc", 1.5), metric.NewGauge("CPU.1", 10)},
+	})
+	cfg := config.DefaultPollerConfig
+	cfg.Key = "secret"
+	cfg.HealthPushFailures = 2
+	return New(store, source, cfg)
+}
+
+func get(h *Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestStatus(t *testing.T) {
+	lastSuccess := time.Unix(1700000000, 0)
+	source := &fakeSource{status: poller.Status{
+		Collectors: map[string]poller.CollectorStatus{
+			"Gops": {LastSuccess: lastSuccess},
+		},
+		Push: poller.PushStatus{LastTime: lastSuccess, LastSuccess: lastSuccess},
+	}}
+	h := newTestHandler(t, source).SetBreaker(retrier.NewBreaker(3, time.Second))
+
+	w := get(h, "/status")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	report := Report{}
+	if !assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report)) {
+		return
+	}
+	assert.True(t, report.Healthy)
+	if assert.Len(t, report.Metrics, 3) {
+		assert.Equal(t, "PollCount", report.Metrics[0].ID)
+		assert.Equal(t, int64(5), *report.Metrics[0].Delta)
+		assert.Equal(t, "Alloc", report.Metrics[1].ID)
+		assert.Equal(t, 1.5, *report.Metrics[1].Value)
+	}
+	assert.True(t, lastSuccess.Equal(report.Collectors["Gops"].LastSuccess))
+	assert.Equal(t, &RetryStatus{State: "closed", Failures: 0}, report.Retry)
+	assert.Equal(t, config.RedactedValue, report.Config.Key)
+}
+
+func TestMetrics(t *testing.T) {
+	lastSuccess := time.Unix(1700000000, 0)
+	source := &fakeSource{status: poller.Status{
+		Collectors: map[string]poller.CollectorStatus{
+			"Gops": {LastSuccess: lastSuccess},
+		},
+		Push: poller.PushStatus{ConsecutiveFailures: 1, LastError: "connection refused"},
+	}}
+	h := newTestHandler(t, source)
+
+	w := get(h, "/metrics")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE PollCount counter\nPollCount 5\n")
+	assert.Contains(t, body, "# TYPE Alloc gauge\nAlloc 1.5\n")
+	assert.Contains(t, body, "CPU_1 10\n")
+	assert.Contains(t, body, "agent_collector_last_success_timestamp_seconds{collector=\"Gops\"} 1700000000\n")
+	assert.Contains(t, body, "agent_push_consecutive_failures 1\n")
+	assert.Contains(t, body, "agent_healthy 1\n")
+	assert.NotContains(t, body, "agent_retry_breaker")
+}
+
+func TestHealth(t *testing.T) {
+	source := &fakeSource{}
+	h := newTestHandler(t, source)
+
+	assert.Equal(t, http.StatusOK, get(h, "/healthz").Code)
+	source.status.Push.ConsecutiveFailures = 1
+	assert.Equal(t, http.StatusOK, get(h, "/healthz").Code)
+	// порог неудачных отправок достигнут
+	source.status.Push.ConsecutiveFailures = 2
+	assert.Equal(t, http.StatusServiceUnavailable, get(h, "/healthz").Code)
+
+	// проверка отключена
+	h.config.HealthPushFailures = 0
+	assert.Equal(t, http.StatusOK, get(h, "/healthz").Code)
+}
+
+func TestFormatPrometheusCollisions(t *testing.T) {
+	used, other := 1.0, 2.0
+	report := Report{Metrics: []protocol.Metrics{
+		{ID: "DiskUsed._var", MType: protocol.TypeGauge, Value: &used},
+		{ID: "DiskUsed.-var", MType: protocol.TypeGauge, Value: &other},
+		{ID: "DiskUsed__var_2", MType: protocol.TypeGauge, Value: &other},
+	}}
+
+	body := formatPrometheus(report)
+	assert.Contains(t, body, "# TYPE DiskUsed__var gauge\nDiskUsed__var 1\n")
+	assert.Contains(t, body, "# TYPE DiskUsed__var_2 gauge\nDiskUsed__var_2 2\n")
+	assert.Contains(t, body, "# TYPE DiskUsed__var_2_2 gauge\nDiskUsed__var_2_2 2\n")
+	assert.Equal(t, 1, strings.Count(body, "# TYPE DiskUsed__var gauge\n"))
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Alloc", want: "Alloc"},
+		{name: "CPUutilization1", want: "CPUutilization1"},
+		{name: "disk.used./", want: "disk_used__"},
+		{name: "1m", want: "_m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, prometheusName(tt.name))
+		})
+	}
+}
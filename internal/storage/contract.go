@@ -5,6 +5,7 @@ import (
 	"context"
 
 	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/retrier"
 )
 
 type storageLogger interface {
@@ -12,8 +13,7 @@ type storageLogger interface {
 }
 
 type storageRetrier interface {
-	Init(func(error) bool)
-	Next(error) bool
+	Do(ctx context.Context, shouldRetry retrier.ShouldRetry, f func(ctx context.Context) error) error
 }
 
 //go:generate mockgen -source=contract.go -destination=mock/storage.go -package=mock Storage
@@ -110,10 +110,8 @@ func (dbs *DBStorage) GetGauge(ctx context.Context, name string) *metric.Gauge {
 
 // UpdateCounter обновляет метрику Counter в базе данных.
 func (dbs *DBStorage) UpdateCounter(ctx context.Context, name string, value int64) error {
-	var err error
-
-	for dbs.retrier.Init(shouldRetryDBQuery); dbs.retrier.Next(err); {
-		_, err = dbs.ExecContext(ctx, `
+	return dbs.retrier.Do(ctx, shouldRetryDBQuery, func(ctx context.Context) error {
+		_, err := dbs.ExecContext(ctx, `
 			INSERT INTO counter as c (name, value)
 			VALUES ($1, $2)
 			ON CONFLICT ON CONSTRAINT counter_name_key
@@ -122,16 +120,14 @@ func (dbs *DBStorage) UpdateCounter(ctx context.Context, name string, value int6
 		if err != nil {
 			dbs.logger.Errorf("UpdateCounter: %v", err)
 		}
-	}
-	return err
+		return err
+	})
 }
 
 // UpdateGauge обновляет метрику Gauge в базе данных.
 func (dbs *DBStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
-	var err error
-
-	for dbs.retrier.Init(shouldRetryDBQuery); dbs.retrier.Next(err); {
-		_, err = dbs.ExecContext(ctx, `
+	return dbs.retrier.Do(ctx, shouldRetryDBQuery, func(ctx context.Context) error {
+		_, err := dbs.ExecContext(ctx, `
 			INSERT INTO gauge (name, value)
 			VALUES ($1, $2)
 			ON CONFLICT ON CONSTRAINT gauge_name_key
@@ -140,8 +136,8 @@ func (dbs *DBStorage) UpdateGauge(ctx context.Context, name string, value float6
 		if err != nil {
 			dbs.logger.Errorf("UpdateGauge: %v", err)
 		}
-	}
-	return err
+		return err
+	})
 }
 
 // UpdateMetrics выполняет множественно обновление метрик. Обновление выполняется в транзакции.
@@ -149,15 +145,13 @@ func (dbs *DBStorage) UpdateGauge(ctx context.Context, name string, value float6
 // В dbstorage_test рассмотрены еще возможные варианты BenchmarkBulkUpdate*. Выбран вариант с UNNEST,
 // т.к. не требует создания строк, однако требует указания типа аргументов.
 func (dbs *DBStorage) UpdateMetrics(ctx context.Context, metrics metric.Metrics) error {
-	var err error
-
-	for dbs.retrier.Init(shouldRetryDBQuery); dbs.retrier.Next(err); {
-		err = dbs.updateMetrics(ctx, metrics)
+	return dbs.retrier.Do(ctx, shouldRetryDBQuery, func(ctx context.Context) error {
+		err := dbs.updateMetrics(ctx, metrics)
 		if err != nil {
 			dbs.logger.Errorf("UpdateMetrics: %v", err)
 		}
-	}
-	return err
+		return err
+	})
 }
 
 // Snapshot создает снимок метрик из базы данных.
@@ -27,7 +27,9 @@ type FileStorage struct {
 // AsyncFileStorage хранит текущие метрики в памяти, но периодически сохраняет их в файл.
 type AsyncFileStorage struct {
 	FileStorage
-	stopFlush chan struct{}
+	// завершается при закрытии хранилища, в том числе прерывает повторы сохранения
+	ctx       context.Context
+	stopFlush context.CancelFunc
 }
 
 // SyncFileStorage хранит текущие метрики в памяти и сохраняет их в файл после каждого изменения.
@@ -51,6 +53,7 @@ func NewFileStorage(logger storageLogger, retrier storageRetrier) *FileStorage {
 // NewAsyncFileStorage возвращает новое файловое хранилище, сохранение изменений в котором,
 // выполняется асинхронно с заданной периодичностью.
 func NewAsyncFileStorage(logger storageLogger, retrier storageRetrier) *AsyncFileStorage {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &AsyncFileStorage{
 		FileStorage: FileStorage{
 			MemStorage: MemStorage{
@@ -60,7 +63,8 @@ func NewAsyncFileStorage(logger storageLogger, retrier storageRetrier) *AsyncFil
 			logger:  logger,
 			retrier: retrier,
 		},
-		stopFlush: make(chan struct{}),
+		ctx:       ctx,
+		stopFlush: cancel,
 	}
 }
 
@@ -121,20 +125,20 @@ func (fs *FileStorage) Restore(r io.Reader) error {
 }
 
 // WriteToFile сохраняет метрики в файл. Файл должен быть предварительно открыт.
-func (fs *FileStorage) WriteToFile(f *os.File) error {
-	var err error
-	for fs.retrier.Init(retrier.AlwaysRetry); fs.retrier.Next(err); {
-		err = fs.writeToFile(f)
+// Повторы сохранения прекращаются при завершении ctx.
+func (fs *FileStorage) WriteToFile(ctx context.Context, f *os.File) error {
+	return fs.retrier.Do(ctx, retrier.AlwaysRetry, func(ctx context.Context) error {
+		err := fs.writeToFile(f)
 		if err != nil {
 			fs.logger.Errorf("WriteToFile: %v", err)
 		}
-	}
-	return err
+		return err
+	})
 }
 
 // Close закрывает асинхронное файловое хранилище.
 func (afs *AsyncFileStorage) Close() error {
-	close(afs.stopFlush)
+	afs.stopFlush()
 	return nil
 }
 
@@ -161,10 +165,10 @@ func (afs *AsyncFileStorage) Open(cfg Config) error {
 		defer f.Close()
 		for {
 			select {
-			case <-afs.stopFlush:
+			case <-afs.ctx.Done():
 				return
 			case <-t.C:
-				if err := afs.WriteToFile(f); err != nil {
+				if err := afs.WriteToFile(afs.ctx, f); err != nil {
 					afs.logger.Errorf("Flash: %v", err)
 				}
 			}
@@ -195,7 +199,7 @@ func (sfs *SyncFileStorage) UpdateCounter(ctx context.Context, name string, valu
 	if err := sfs.MemStorage.UpdateCounter(ctx, name, value); err != nil {
 		return err
 	}
-	if err := sfs.WriteToFile(sfs.writer); err != nil {
+	if err := sfs.WriteToFile(ctx, sfs.writer); err != nil {
 		sfs.logger.Errorf("SetCounter: %v", err)
 		return err
 	}
@@ -207,7 +211,7 @@ func (sfs *SyncFileStorage) UpdateGauge(ctx context.Context, name string, value
 	if err := sfs.MemStorage.UpdateGauge(ctx, name, value); err != nil {
 		return err
 	}
-	if err := sfs.WriteToFile(sfs.writer); err != nil {
+	if err := sfs.WriteToFile(ctx, sfs.writer); err != nil {
 		sfs.logger.Errorf("SetGauge: %v", err)
 		return err
 	}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -100,16 +101,16 @@ func (suite *fileStorageTestSuite) TestWriteToFile() {
 		return
 	}
 	defer f.Close()
-	if err := suite.fs.WriteToFile(f); err != nil {
+	ctx := context.TODO()
+	if err := suite.fs.WriteToFile(ctx, f); err != nil {
 		suite.T().Errorf("unexpected error = %v", err)
 		return
 	}
-	ctx := context.TODO()
 	data, _ := os.ReadFile(filename)
 	want := `{"Counters":[{"Name":"c0","Value":1},{"Name":"c1","Value":15}],"Gauges":[{"Name":"g0","Value":1.1},{"Name":"g1","Value":36.6}]}`
 	assertMetricsJSONEq(suite.T(), want, string(data))
 	suite.fs.UpdateCounter(ctx, "c2", 20)
-	if err := suite.fs.WriteToFile(f); err != nil {
+	if err := suite.fs.WriteToFile(ctx, f); err != nil {
 		suite.T().Errorf("unexpected error = %v", err)
 		return
 	}
@@ -118,6 +119,22 @@ func (suite *fileStorageTestSuite) TestWriteToFile() {
 	assertMetricsJSONEq(suite.T(), want, string(data))
 }
 
+func (suite *fileStorageTestSuite) TestWriteToFileCanceled() {
+	f, err := os.CreateTemp(suite.T().TempDir(), "storage")
+	if err != nil {
+		suite.T().Errorf("unexpected error = %v", err)
+		return
+	}
+	// запись в закрытый файл завершается ошибкой, которая повторяется
+	f.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err = suite.fs.WriteToFile(ctx, f)
+	suite.ErrorIs(err, context.Canceled)
+	suite.Less(time.Since(start), time.Second)
+}
+
 func TestFileStorage(t *testing.T) {
 	suite.Run(t, new(fileStorageTestSuite))
 }
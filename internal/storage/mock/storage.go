@@ -11,6 +11,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 
 	metric "github.com/k1nky/ypmetrics/internal/entities/metric"
+	retrier "github.com/k1nky/ypmetrics/internal/retrier"
 	storage "github.com/k1nky/ypmetrics/internal/storage"
 )
 
@@ -77,30 +78,18 @@ func (m *MockstorageRetrier) EXPECT() *MockstorageRetrierMockRecorder {
 	return m.recorder
 }
 
-// Init mocks base method.
-func (m *MockstorageRetrier) Init(arg0 func(error) bool) {
+// Do mocks base method.
+func (m *MockstorageRetrier) Do(ctx context.Context, shouldRetry retrier.ShouldRetry, f func(context.Context) error) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Init", arg0)
-}
-
-// Init indicates an expected call of Init.
-func (mr *MockstorageRetrierMockRecorder) Init(arg0 interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockstorageRetrier)(nil).Init), arg0)
-}
-
-// Next mocks base method.
-func (m *MockstorageRetrier) Next(arg0 error) bool {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Next", arg0)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "Do", ctx, shouldRetry, f)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Next indicates an expected call of Next.
-func (mr *MockstorageRetrierMockRecorder) Next(arg0 interface{}) *gomock.Call {
+// Do indicates an expected call of Do.
+func (mr *MockstorageRetrierMockRecorder) Do(ctx, shouldRetry, f interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockstorageRetrier)(nil).Next), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockstorageRetrier)(nil).Do), ctx, shouldRetry, f)
 }
 
 // MockStorage is a mock of Storage interface.
@@ -0,0 +1,191 @@
+// Пакет tlsconfig формирует настройки TLS для сервера и агента.
+// Сертификаты и списки доверенных центров сертификации перечитываются при изменении файлов,
+// поэтому обновить сертификат можно без перезапуска.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/k1nky/ypmetrics/internal/filewatch"
+)
+
+var (
+	// ErrNoCertificates файл не содержит ни одного сертификата.
+	ErrNoCertificates = errors.New("no certificates found")
+	// ErrNoPeerCertificate удаленная сторона не предъявила сертификат.
+	ErrNoPeerCertificate = errors.New("no peer certificate")
+)
+
+type watchLogger interface {
+	Errorf(template string, args ...interface{})
+	Infof(template string, args ...interface{})
+}
+
+// KeyPair сертификат с закрытым ключом, который перечитывается при изменении файлов.
+type KeyPair struct {
+	certPath string
+	keyPath  string
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+}
+
+// NewKeyPair загружает сертификат certPath и закрытый ключ keyPath в формате PEM.
+func NewKeyPair(certPath string, keyPath string) (*KeyPair, error) {
+	kp := &KeyPair{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+	if err := kp.Reload(); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// Reload перечитывает сертификат и ключ. При ошибке остается прежний сертификат.
+func (kp *KeyPair) Reload() error {
+	cert, err := tls.LoadX509KeyPair(kp.certPath, kp.keyPath)
+	if err != nil {
+		return err
+	}
+	kp.lock.Lock()
+	defer kp.lock.Unlock()
+	kp.cert = &cert
+	return nil
+}
+
+// Certificate возвращает текущий сертификат.
+func (kp *KeyPair) Certificate() *tls.Certificate {
+	kp.lock.RLock()
+	defer kp.lock.RUnlock()
+	return kp.cert
+}
+
+// GetCertificate возвращает текущий сертификат сервера. Совместим с tls.Config.GetCertificate.
+func (kp *KeyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return kp.Certificate(), nil
+}
+
+// GetClientCertificate возвращает текущий сертификат клиента. Совместим с tls.Config.GetClientCertificate.
+func (kp *KeyPair) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return kp.Certificate(), nil
+}
+
+// Watch отслеживает изменения файлов сертификата и ключа до завершения контекста ctx.
+func (kp *KeyPair) Watch(ctx context.Context, interval time.Duration, l watchLogger) {
+	// сертификат и ключ обычно обновляются вместе, поэтому перечитываем оба файла
+	// при изменении любого из них
+	filewatch.Watch(ctx, kp.certPath, interval, kp.Reload, l)
+	filewatch.Watch(ctx, kp.keyPath, interval, kp.Reload, l)
+}
+
+// CAPool список доверенных центров сертификации, который перечитывается при изменении файла.
+type CAPool struct {
+	path string
+	lock sync.RWMutex
+	pool *x509.CertPool
+}
+
+// NewCAPool загружает сертификаты центров сертификации из файла path в формате PEM.
+func NewCAPool(path string) (*CAPool, error) {
+	p := &CAPool{
+		path: path,
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload перечитывает список центров сертификации. При ошибке остается прежний список.
+func (p *CAPool) Reload() error {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return ErrNoCertificates
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.pool = pool
+	return nil
+}
+
+// Pool возвращает текущий список центров сертификации.
+func (p *CAPool) Pool() *x509.CertPool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.pool
+}
+
+// Watch отслеживает изменения файла до завершения контекста ctx.
+func (p *CAPool) Watch(ctx context.Context, interval time.Duration, l watchLogger) {
+	filewatch.Watch(ctx, p.path, interval, p.Reload, l)
+}
+
+// NewServerConfig возвращает настройки TLS сервера с сертификатом kp.
+// Если задан clientCAs, то сервер требует от клиентов сертификат, подписанный одним из этих центров.
+func NewServerConfig(kp *KeyPair, clientCAs *CAPool) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: kp.GetCertificate,
+	}
+	if clientCAs == nil {
+		return cfg
+	}
+	// список центров сертификации может измениться, поэтому формируем настройки для каждого клиента
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := cfg.Clone()
+		c.GetConfigForClient = nil
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		c.ClientCAs = clientCAs.Pool()
+		return c, nil
+	}
+	return cfg
+}
+
+// NewClientConfig возвращает настройки TLS клиента. Если задан kp, то клиент предъявляет серверу сертификат.
+// Если задан rootCAs, то сертификат сервера проверяется по этому списку центров сертификации,
+// иначе - по системному списку.
+func NewClientConfig(kp *KeyPair, rootCAs *CAPool) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if kp != nil {
+		cfg.GetClientCertificate = kp.GetClientCertificate
+	}
+	if rootCAs == nil {
+		return cfg
+	}
+	// RootCAs нельзя заменить после создания соединения, поэтому проверяем сертификат сервера
+	// самостоятельно по актуальному списку центров сертификации
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		return verifyPeer(cs, rootCAs.Pool())
+	}
+	return cfg
+}
+
+// verifyPeer проверяет цепочку сертификатов сервера и соответствие имени сервера.
+func verifyPeer(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return ErrNoPeerCertificate
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,218 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA создает самоподписанный центр сертификации.
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// writeCA сохраняет сертификат центра сертификации в файл и возвращает путь до него.
+func (ca *testCA) writeCA(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "ca.pem")
+	writePEM(t, path, "CERTIFICATE", ca.cert.Raw)
+	return path
+}
+
+// issue выпускает сертификат с именем cn и сохраняет его вместе с ключом в dir.
+func (ca *testCA) issue(t *testing.T, dir string, cn string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath := filepath.Join(dir, cn+".pem")
+	keyPath := filepath.Join(dir, cn+".key")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "PRIVATE KEY", keyDer)
+	return certPath, keyPath
+}
+
+func writePEM(t *testing.T, path string, typ string, der []byte) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: typ, Bytes: der}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyPairReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPath, keyPath := ca.issue(t, dir, "server", 2)
+	kp, err := NewKeyPair(certPath, keyPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	before := kp.Certificate()
+
+	// выпускаем новый сертификат в те же файлы
+	ca.issue(t, dir, "server", 3)
+	assert.NoError(t, kp.Reload())
+	after, _ := kp.GetCertificate(nil)
+	assert.NotEqual(t, before.Certificate[0], after.Certificate[0])
+
+	// при ошибке остается прежний сертификат
+	os.WriteFile(certPath, []byte("broken"), 0600)
+	assert.Error(t, kp.Reload())
+	assert.Equal(t, after, kp.Certificate())
+}
+
+func TestNewKeyPairInvalid(t *testing.T) {
+	_, err := NewKeyPair(filepath.Join(t.TempDir(), "cert.pem"), filepath.Join(t.TempDir(), "key.pem"))
+	assert.Error(t, err)
+}
+
+func TestNewCAPoolInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(path, []byte("not a certificate"), 0600)
+	_, err := NewCAPool(path)
+	assert.ErrorIs(t, err, ErrNoCertificates)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := ca.writeCA(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	clientCert, clientKey := ca.issue(t, dir, "agent-1", 3)
+	// клиентский сертификат, выпущенный другим центром сертификации
+	foreignCert, foreignKey := newTestCA(t).issue(t, t.TempDir(), "agent-2", 4)
+
+	serverPair, err := NewKeyPair(serverCert, serverKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	pool, err := NewCAPool(caPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = NewServerConfig(serverPair, pool)
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		wantErr bool
+	}{
+		{name: "Trusted client", cert: clientCert, key: clientKey, wantErr: false},
+		{name: "Untrusted client", cert: foreignCert, key: foreignKey, wantErr: true},
+		{name: "Without client certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kp *KeyPair
+			if len(tt.cert) > 0 {
+				kp, err = NewKeyPair(tt.cert, tt.key)
+				if !assert.NoError(t, err) {
+					return
+				}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: NewClientConfig(kp, pool)}}
+			resp, err := client.Get(srv.URL)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			body := make([]byte, 64)
+			n, _ := resp.Body.Read(body)
+			assert.Equal(t, "agent-1", string(body[:n]))
+		})
+	}
+}
+
+func TestClientRejectsUntrustedServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := ca.writeCA(t, dir)
+	// сервер с сертификатом от другого центра сертификации
+	serverCert, serverKey := newTestCA(t).issue(t, t.TempDir(), "server", 2)
+	serverPair, err := NewKeyPair(serverCert, serverKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = NewServerConfig(serverPair, nil)
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool, err := NewCAPool(caPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: NewClientConfig(nil, pool)}}
+	_, err = client.Get(srv.URL)
+	assert.Error(t, err)
+}
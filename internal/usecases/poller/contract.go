@@ -21,11 +21,11 @@ type logger interface {
 	Errorf(template string, args ...interface{})
 }
 
-// отправитель метрик на сервер
+// отправитель метрик на сервер, отправка прерывается при завершении контекста
 type sender interface {
-	PushCounter(name string, value int64) error
-	PushGauge(name string, value float64) error
-	PushMetrics(metrics metric.Metrics) error
+	PushCounterContext(ctx context.Context, name string, value int64) error
+	PushGaugeContext(ctx context.Context, name string, value float64) error
+	PushMetricsContext(ctx context.Context, metrics metric.Metrics) error
 }
 
 // сборщик метрик
@@ -0,0 +1,152 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+)
+
+// Параметры отключения сборщиков, которые опрашиваются с ошибками.
+const (
+	// CollectorFailureThreshold количество ошибок опроса подряд, после которого сборщик отключается.
+	CollectorFailureThreshold = 3
+	// CollectorMinBackoff время первого отключения сборщика. Каждое следующее отключение подряд длится вдвое дольше.
+	CollectorMinBackoff = time.Minute
+	// CollectorMaxBackoff максимальное время отключения сборщика.
+	CollectorMaxBackoff = 30 * time.Minute
+)
+
+// Префиксы имен метрик самого агента. Полное имя метрики - <префикс>.<имя сборщика>.
+const (
+	// длительность последнего опроса сборщика в секундах
+	CollectDurationMetric = "CollectDuration"
+	// количество ошибок опроса сборщика
+	CollectErrorsMetric = "CollectErrors"
+)
+
+var (
+	// ErrCollectorPanic опрос сборщика завершился паникой.
+	ErrCollectorPanic = errors.New("collector panic")
+	// ErrCollectorBusy предыдущий опрос сборщика еще не завершился.
+	ErrCollectorBusy = errors.New("previous collection is still running")
+)
+
+// job задание опроса сборщика метрик.
+type job struct {
+	name      string
+	collector Collector
+	// интервал опроса, 0 - Config.PollInterval
+	interval time.Duration
+	// таймаут опроса, 0 - без ограничения
+	timeout time.Duration
+	// количество ошибок подряд, после которого сборщик отключается
+	threshold int
+	// время отключения сборщика
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	// выполняется опрос сборщика
+	running atomic.Bool
+
+	mu            sync.Mutex
+	failures      int
+	disabledUntil time.Time
+}
+
+func newJob(name string, c Collector, interval time.Duration, timeout time.Duration) *job {
+	return &job{
+		name:       name,
+		collector:  c,
+		interval:   interval,
+		timeout:    timeout,
+		threshold:  CollectorFailureThreshold,
+		minBackoff: CollectorMinBackoff,
+		maxBackoff: CollectorMaxBackoff,
+	}
+}
+
+// run опрашивает сборщика. Опрос выполняется в отдельной горутине, поэтому по истечении таймаута
+// или завершении ctx результат возвращается, даже если сборщик не учитывает контекст.
+// Паника сборщика возвращается как ошибка ErrCollectorPanic. Пока не завершился предыдущий опрос,
+// новый опрос не начинается и возвращается ошибка ErrCollectorBusy.
+func (j *job) run(ctx context.Context) (metric.Metrics, error) {
+	if !j.running.CompareAndSwap(false, true) {
+		return metric.Metrics{}, ErrCollectorBusy
+	}
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	type result struct {
+		metrics metric.Metrics
+		err     error
+	}
+	// буфер нужен, чтобы зависший сборщик мог завершиться, когда результат уже никто не ждет
+	ch := make(chan result, 1)
+	go func() {
+		defer j.running.Store(false)
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- result{err: fmt.Errorf("%w: %v", ErrCollectorPanic, r)}
+			}
+		}()
+		m, err := j.collector.Collect(ctx)
+		ch <- result{metrics: m, err: err}
+	}()
+	select {
+	case r := <-ch:
+		return r.metrics, r.err
+	case <-ctx.Done():
+		return metric.Metrics{}, ctx.Err()
+	}
+}
+
+// isDisabled возвращает true, если в момент now сборщик отключен.
+func (j *job) isDisabled(now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return now.Before(j.disabledUntil)
+}
+
+// record учитывает результат опроса в момент now. Если сборщик нужно отключить,
+// то возвращается время, до которого он отключен, иначе нулевое время.
+func (j *job) record(err error, now time.Time) time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err == nil {
+		j.failures = 0
+		return time.Time{}
+	}
+	j.failures++
+	if j.threshold <= 0 || j.failures < j.threshold {
+		return time.Time{}
+	}
+	// после включения сборщика каждая следующая ошибка отключает его вдвое дольше
+	backoff := j.minBackoff
+	for i := j.threshold; i < j.failures && backoff < j.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > j.maxBackoff {
+		backoff = j.maxBackoff
+	}
+	j.disabledUntil = now.Add(backoff)
+	return j.disabledUntil
+}
+
+// selfMetrics возвращает метрики опроса сборщика: длительность опроса duration и количество ошибок.
+func (j *job) selfMetrics(duration time.Duration, err error) metric.Metrics {
+	var failed int64
+	if err != nil {
+		failed = 1
+	}
+	return metric.Metrics{
+		Counters: []*metric.Counter{metric.NewCounter(CollectErrorsMetric+"."+j.name, failed)},
+		Gauges:   []*metric.Gauge{metric.NewGauge(CollectDurationMetric+"."+j.name, duration.Seconds())},
+	}
+}
@@ -0,0 +1,120 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/collector"
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	log "github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/storage"
+)
+
+type panicCollector struct{}
+
+func (panicCollector) Init() error {
+	return nil
+}
+
+func (panicCollector) Collect(ctx context.Context) (metric.Metrics, error) {
+	panic("unexpected")
+}
+
+// hungCollector не учитывает контекст и завершается только после закрытия release
+type hungCollector struct {
+	release chan struct{}
+}
+
+func (c hungCollector) Init() error {
+	return nil
+}
+
+func (c hungCollector) Collect(ctx context.Context) (metric.Metrics, error) {
+	<-c.release
+	return metric.Metrics{}, nil
+}
+
+func TestJobRunPanic(t *testing.T) {
+	j := newJob("panic", panicCollector{}, 0, 0)
+	_, err := j.run(context.Background())
+	assert.ErrorIs(t, err, ErrCollectorPanic)
+	// после паники сборщика можно опрашивать снова
+	_, err = j.run(context.Background())
+	assert.ErrorIs(t, err, ErrCollectorPanic)
+}
+
+func TestJobRunHung(t *testing.T) {
+	c := hungCollector{release: make(chan struct{})}
+	j := newJob("hung", c, 0, 100*time.Millisecond)
+	_, err := j.run(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// зависший опрос еще выполняется
+	_, err = j.run(context.Background())
+	assert.ErrorIs(t, err, ErrCollectorBusy)
+
+	close(c.release)
+	assert.Eventually(t, func() bool { return !j.running.Load() }, time.Second, 10*time.Millisecond)
+	_, err = j.run(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestJobRecord(t *testing.T) {
+	j := newJob("test", &collector.PollCounter{}, 0, 0)
+	j.minBackoff = time.Minute
+	j.maxBackoff = 3 * time.Minute
+	now := time.Now()
+	failed := errors.New("failed")
+
+	for i := 1; i < CollectorFailureThreshold; i++ {
+		assert.True(t, j.record(failed, now).IsZero())
+	}
+	// отключения подряд удваиваются до максимального времени
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		assert.Equal(t, now.Add(want), j.record(failed, now))
+		assert.True(t, j.isDisabled(now))
+		assert.False(t, j.isDisabled(now.Add(want)))
+	}
+	// успешный опрос сбрасывает счетчик ошибок
+	assert.True(t, j.record(nil, now).IsZero())
+	assert.True(t, j.record(failed, now).IsZero())
+}
+
+func TestPollDisablesFailingCollector(t *testing.T) {
+	p := New(config.Poller{PollIntervalInSec: 1}, storage.NewMemStorage(), &log.Blackhole{}, &fakeSender{})
+	p.AddNamedCollector("failing", failingCollector{}, config.CollectorConfig{})
+	p.collectors[0].threshold = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	polled := 0
+	for m := range p.poll(ctx, 1) {
+		polled++
+		// при ошибке передаются только метрики опроса
+		if assert.Len(t, m.Counters, 1) {
+			assert.Equal(t, CollectErrorsMetric+".failing", m.Counters[0].Name)
+			assert.Equal(t, int64(1), m.Counters[0].Value)
+		}
+		if assert.Len(t, m.Gauges, 1) {
+			assert.Equal(t, CollectDurationMetric+".failing", m.Gauges[0].Name)
+		}
+	}
+	// после первой ошибки сборщик отключен и больше не опрашивается
+	assert.Equal(t, 1, polled)
+	assert.False(t, p.Status().Collectors["failing"].DisabledUntil.IsZero())
+}
+
+func TestPollRecoversPanic(t *testing.T) {
+	p := New(config.Poller{PollIntervalInSec: 1}, storage.NewMemStorage(), &log.Blackhole{}, &fakeSender{})
+	p.AddNamedCollector("panic", panicCollector{}, config.CollectorConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	for range p.poll(ctx, 1) {
+	}
+	assert.Contains(t, p.Status().Collectors["panic"].LastError, ErrCollectorPanic.Error())
+}
@@ -2,19 +2,24 @@ package poller
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/k1nky/ypmetrics/internal/config"
 	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	"github.com/k1nky/ypmetrics/internal/protocol"
+	"github.com/k1nky/ypmetrics/internal/ratelimit"
 )
 
 // Poller представляет собой набор метрик с расширенным функционалом. Он опрашивает сборщиков (Collector)
 // и периодически отправляет обновления метрик в единый набор метрик.
 type Poller struct {
 	storage    metricStorage
-	collectors []Collector
+	collectors []*job
 	logger     logger
 	client     sender
+	status     *statusTracker
 	Config     config.Poller
 }
 
@@ -26,13 +31,13 @@ const (
 )
 
 const (
-	//
+	// NoLimitToReport значение ограничений на отправку метрик, которое означает отсутствие ограничения.
 	NoLimitToReport = 0
 )
 
 // Количество воркеров по умолчанию
 const (
-	// воркеры сбора метрик
+	// воркеры сбора метрик, если их количество не задано в Config.PollWorkers
 	MaxPollWorkers = 2
 )
 
@@ -43,42 +48,83 @@ func New(cfg config.Poller, store metricStorage, log logger, client sender) *Pol
 		logger:     log,
 		storage:    store,
 		Config:     cfg,
-		collectors: make([]Collector, 0),
+		collectors: make([]*job, 0),
+		status:     newStatusTracker(),
 	}
 }
 
-// Добавляет сборщика для опроса
+// Добавляет сборщика для опроса с общим интервалом сбора метрик. Имя сборщика определяется по его типу.
+// Ошибки инициализации сборщиков записываются в лог.
 func (p *Poller) AddCollector(c ...Collector) {
 	for _, collector := range c {
-		if err := collector.Init(); err != nil {
-			p.logger.Errorf("failed initializing collector %T: %s", collector, err)
-			continue
+		name := collectorName(collector)
+		if err := p.AddNamedCollector(name, collector, config.CollectorConfig{}); err != nil {
+			p.logger.Errorf("failed initializing collector %s: %s", name, err)
 		}
-		p.collectors = append(p.collectors, collector)
 	}
 }
 
-// Run запускает Poller
+// AddNamedCollector добавляет сборщика с именем name для опроса с интервалом и таймаутом из cfg.
+// Если таймаут не указан, то он составляет 90% интервала опроса сборщика, чтобы зависший сборщик
+// не занимал воркер и успевал завершиться до следующего опроса.
+// Сборщик, который не удалось инициализировать, не добавляется, а возвращается ошибка инициализации.
+// Сборщик, который CollectorFailureThreshold раз подряд опрашивается с ошибкой, временно отключается.
+func (p *Poller) AddNamedCollector(name string, c Collector, cfg config.CollectorConfig) error {
+	if err := c.Init(); err != nil {
+		return err
+	}
+	timeout := cfg.Timeout()
+	if timeout == 0 {
+		interval := cfg.Interval()
+		if interval == 0 {
+			interval = p.Config.PollInterval()
+		}
+		timeout = interval * 9 / 10
+	}
+	p.collectors = append(p.collectors, newJob(name, c, cfg.Interval(), timeout))
+	return nil
+}
+
+// Status возвращает текущее состояние опроса сборщиков и отправки метрик.
+func (p Poller) Status() Status {
+	return p.status.snapshot()
+}
+
+// Run запускает Poller. При завершении контекста ctx опрос сборщиков прекращается, а текущие значения
+// метрик отправляются на сервер последний раз. На отправку отводится Config.ShutdownTimeout.
+// Возвращаемый канал закрывается после завершения отправки.
 func (p Poller) Run(ctx context.Context) <-chan struct{} {
 	// получаем метрики со сборщиков
-	metrics := p.poll(ctx, MaxPollWorkers)
+	metrics := p.poll(ctx, p.pollWorkers())
 	// сохраняем их по мере поступления
-	p.storeWorker(ctx, metrics)
+	stored := p.storeWorker(ctx, metrics)
 	// отправляем метрик на сервер по таймеру
-	done := p.report(ctx)
+	done := p.report(ctx, stored)
 	return done
 }
 
+// pollWorkers возвращает количество воркеров сбора метрик.
+func (p Poller) pollWorkers() int {
+	if p.Config.PollWorkers == 0 {
+		return MaxPollWorkers
+	}
+	return int(p.Config.PollWorkers)
+}
+
 // Создает и запускает maxWorkers воркеров для опроса сборщиков метрик.
+// Каждый сборщик опрашивается со своим интервалом.
 // Собранные метрики передаются через возвращаемый канал по мере поступления.
 func (p Poller) poll(ctx context.Context, maxWorkers int) <-chan metric.Metrics {
 	// возвращаемый канал с получаемыми метриками от сборщиков
 	result := make(chan metric.Metrics, len(p.collectors))
 	// задания для воркеров сбора метрик
-	jobs := make(chan Collector, len(p.collectors))
+	jobs := make(chan *job, len(p.collectors))
 
+	wg := &sync.WaitGroup{}
 	for i := 1; i <= maxWorkers; i++ {
+		wg.Add(1)
 		go func(id int) {
+			defer wg.Done()
 			// запускаем очередной воркер сбора метрик
 			// у каждого воркера свой канал, в который отправляются собранные метрики
 			ch := p.pollWorker(context.WithValue(ctx, keyWorkerID, id), jobs)
@@ -97,121 +143,283 @@ func (p Poller) poll(ctx context.Context, maxWorkers int) <-chan metric.Metrics
 			}
 		}(i)
 	}
+	schedulers := &sync.WaitGroup{}
+	for _, j := range p.collectors {
+		schedulers.Add(1)
+		go func(j *job) {
+			defer schedulers.Done()
+			p.schedule(ctx, j, jobs)
+		}(j)
+	}
 	go func() {
-		t := time.NewTicker(p.Config.PollInterval())
-		defer t.Stop()
-		for {
+		schedulers.Wait()
+		close(jobs)
+		// результирующий канал закрываем только после того, как в него перестанут писать
+		wg.Wait()
+		close(result)
+	}()
+
+	return result
+}
+
+// schedule кладет задание j в канал jobs с интервалом опроса сборщика до завершения ctx.
+func (p Poller) schedule(ctx context.Context, j *job, jobs chan<- *job) {
+	interval := j.interval
+	if interval == 0 {
+		interval = p.Config.PollInterval()
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
 			select {
 			case <-ctx.Done():
-				close(jobs)
-				time.Sleep(100 * time.Millisecond)
-				close(result)
 				return
-			case <-t.C:
-				// кладем в канал сборщиков, которых должны опросить воркеры
-				for _, c := range p.collectors {
-					jobs <- c
-				}
+			case jobs <- j:
 			}
 		}
-	}()
-
-	return result
+	}
 }
 
-// Воркер опроса сборщиков метрик
-func (p Poller) pollWorker(ctx context.Context, jobs <-chan Collector) <-chan metric.Metrics {
+// Воркер опроса сборщиков метрик. Вместе с собранными метриками передаются метрики опроса
+// сборщика: длительность и количество ошибок.
+func (p Poller) pollWorker(ctx context.Context, jobs <-chan *job) <-chan metric.Metrics {
 	result := make(chan metric.Metrics)
 	go func() {
 		defer close(result)
 		id := ctx.Value(keyWorkerID).(int)
-		for job := range jobs {
-			p.logger.Debugf("poll worker #%d: poll %T", id, job)
-			m, err := job.Collect(ctx)
+		for j := range jobs {
+			if j.isDisabled(time.Now()) {
+				p.logger.Debugf("poll worker #%d: skip disabled %s", id, j.name)
+				continue
+			}
+			p.logger.Debugf("poll worker #%d: poll %s", id, j.name)
+			start := time.Now()
+			m, err := j.run(ctx)
+			if ctx.Err() != nil {
+				// результат больше никто не ждет
+				return
+			}
+			if errors.Is(err, ErrCollectorBusy) {
+				// пропущенный опрос не считается ошибкой сборщика
+				p.logger.Infof("poll worker #%d: skip %s: %s", id, j.name, err)
+				continue
+			}
+			now := time.Now()
+			p.status.collected(j.name, err, now)
 			if err != nil {
-				p.logger.Errorf("poll worker #%d: %s", id, err)
+				p.logger.Errorf("poll worker #%d: %s: %s", id, j.name, err)
+				m = metric.Metrics{}
+				if until := j.record(err, now); !until.IsZero() {
+					p.logger.Errorf("poll worker #%d: %s is disabled until %s", id, j.name, until.Format(time.RFC3339))
+					p.status.disabled(j.name, until)
+				}
 			} else {
-				result <- m
+				j.record(nil, now)
+			}
+			self := j.selfMetrics(now.Sub(start), err)
+			m.Counters = append(m.Counters, self.Counters...)
+			m.Gauges = append(m.Gauges, self.Gauges...)
+			select {
+			case <-ctx.Done():
+				return
+			case result <- m:
 			}
 		}
 	}()
 	return result
 }
 
-// Воркер сохранения метрик из канала
-func (p Poller) storeWorker(ctx context.Context, metrics <-chan metric.Metrics) {
+// Воркер сохранения метрик из канала. Возвращаемый канал закрывается, когда все метрики сохранены.
+func (p Poller) storeWorker(ctx context.Context, metrics <-chan metric.Metrics) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		for m := range metrics {
 			if err := p.storage.UpdateMetrics(ctx, m); err != nil {
 				p.logger.Errorf("store worker: %s", err)
 			}
 		}
 	}()
+	return done
 }
 
-// Создает и запускает maxWorkers воркеров для отправки метрик на сервер.
-func (p Poller) report(ctx context.Context) <-chan struct{} {
-	metrics := make(chan metric.Metrics, p.Config.RateLimit)
+// Создает и запускает воркеры для отправки метрик на сервер. Количество воркеров определяется
+// Config.RateLimit. Снапшот метрик разбивается на пакеты не более Config.BatchSize метрик и
+// Config.BatchBytes байт.
+// После завершения ctx и сохранения всех собранных метрик (закрытие stored) выполняется последняя отправка.
+// Воркеры завершают отправку пакетов в течение Config.ShutdownTimeout.
+func (p Poller) report(ctx context.Context, stored <-chan struct{}) <-chan struct{} {
+	workers := int(p.Config.RateLimit)
+	if workers == NoLimitToReport {
+		workers = 1
+	}
+	batches := make(chan metric.Metrics, workers)
+	// контекст воркеров отправки продолжает действовать в течение таймаута после завершения ctx,
+	// чтобы успеть отправить последние метрики
+	shutdownCtx, cancel := withShutdownTimeout(ctx, p.Config.ShutdownTimeout())
+
+	var limiter *ratelimit.Bucket
+	if p.Config.RequestsPerSecond > 0 {
+		// ограничение общее для всех воркеров
+		limiter = ratelimit.NewBucket(p.Config.RequestsPerSecond, 1)
+	}
+	wg := &sync.WaitGroup{}
+	for i := 1; i <= workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			p.reportWorker(context.WithValue(shutdownCtx, keyWorkerID, id), batches, limiter)
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		cancel()
+		close(done)
+	}()
 
-	done := p.reportWorker(ctx, metrics)
 	go func() {
+		defer close(batches)
 		t := time.NewTicker(p.Config.ReportInterval())
 		defer t.Stop()
 		for {
 			select {
 			case <-ctx.Done():
-				close(metrics)
+				// дожидаемся сохранения уже собранных метрик
+				select {
+				case <-stored:
+				case <-shutdownCtx.Done():
+					return
+				}
+				p.logger.Infof("report: sending final metrics")
+				p.sendSnapshot(shutdownCtx, batches)
 				return
 			case <-t.C:
-				// делаем снапшот метрик из хранилища, которые будем отправлять
-				snapshot := &metric.Metrics{}
-				if err := p.storage.Snapshot(ctx, snapshot); err != nil {
-					p.logger.Errorf("report: %s", err)
-					continue
-				}
 				p.logger.Debugf("report: sending metrics")
-				if p.Config.RateLimit == NoLimitToReport {
-					// ограничения на отправку нет - отправляем все метрики одним большим запросов
-					metrics <- *snapshot
-					continue
-				}
-				// ограничение на количество запросов установлено
-				// отправляем по одной метрике (иначе не совсем понятно ограничение на отправку)
-				for _, m := range snapshot.Counters {
-					metrics <- metric.Metrics{
-						Counters: []*metric.Counter{m},
-					}
-				}
-				for _, m := range snapshot.Gauges {
-					metrics <- metric.Metrics{
-						Gauges: []*metric.Gauge{m},
-					}
-				}
+				p.sendSnapshot(ctx, batches)
 			}
 		}
 	}()
 	return done
 }
 
-func (p Poller) reportWorker(ctx context.Context, metrics <-chan metric.Metrics) (done chan struct{}) {
-	done = make(chan struct{})
-	go func() {
-		defer close(done)
-		for {
-			select {
-			case <-ctx.Done():
-				p.logger.Debugf("report worker: done")
+// sendSnapshot делает снапшот метрик из хранилища и передает его воркерам отправки пакетами.
+func (p Poller) sendSnapshot(ctx context.Context, batches chan<- metric.Metrics) {
+	snapshot := &metric.Metrics{}
+	if err := p.storage.Snapshot(ctx, snapshot); err != nil {
+		p.logger.Errorf("report: %s", err)
+		return
+	}
+	for _, b := range splitMetrics(*snapshot, int(p.Config.BatchSize), int(p.Config.BatchBytes)) {
+		select {
+		case <-ctx.Done():
+			return
+		case batches <- b:
+		}
+	}
+}
+
+// Воркер отправки пакетов метрик на сервер. Если указан limiter, то перед каждой отправкой
+// воркер ожидает разрешения. Воркер завершается после закрытия канала batches или завершения ctx.
+func (p Poller) reportWorker(ctx context.Context, batches <-chan metric.Metrics, limiter *ratelimit.Bucket) {
+	id := ctx.Value(keyWorkerID).(int)
+	for {
+		if ctx.Err() != nil {
+			// если пакет и завершение доступны одновременно, то select выбирает случайно
+			p.logger.Debugf("report worker #%d: done", id)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			p.logger.Debugf("report worker #%d: done", id)
+			return
+		case m, ok := <-batches:
+			if !ok {
+				p.logger.Debugf("report worker #%d: metrics channel was closed", id)
 				return
-			case m, ok := <-metrics:
-				if !ok {
-					p.logger.Errorf("report worker: metrics channel was closed")
+			}
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
 					return
 				}
-				if err := p.client.PushMetrics(m); err != nil {
-					p.logger.Errorf("report worker: %s", err)
-				}
+			}
+			err := p.client.PushMetricsContext(ctx, m)
+			p.status.pushed(err, time.Now())
+			if err != nil {
+				p.logger.Errorf("report worker #%d: %s", id, err)
 			}
 		}
+	}
+}
+
+// withShutdownTimeout возвращает контекст, который завершается через timeout после завершения ctx.
+func withShutdownTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	shutdownCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-shutdownCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-shutdownCtx.Done():
+		case <-t.C:
+			cancel()
+		}
 	}()
-	return
+	return shutdownCtx, cancel
+}
+
+// splitMetrics разбивает метрики на пакеты не более maxCount метрик и не более maxBytes байт
+// в формате JSON. Нулевые значения снимают соответствующее ограничение. Метрика, которая сама по себе
+// превышает maxBytes, отправляется отдельным пакетом.
+func splitMetrics(metrics metric.Metrics, maxCount int, maxBytes int) []metric.Metrics {
+	if maxCount == NoLimitToReport && maxBytes == NoLimitToReport {
+		return []metric.Metrics{metrics}
+	}
+	result := make([]metric.Metrics, 0)
+	batch := metric.Metrics{}
+	// размер пакета учитывает скобки массива и разделители элементов
+	count, size := 0, 2
+	add := func(s int) {
+		if count > 0 {
+			s++
+		}
+		if count > 0 && ((maxCount > 0 && count >= maxCount) || (maxBytes > 0 && size+s > maxBytes)) {
+			// текущий пакет заполнен
+			result = append(result, batch)
+			batch = metric.Metrics{}
+			count, size = 0, 2
+			s--
+		}
+		count++
+		size += s
+	}
+	for _, c := range metrics.Counters {
+		add(encodedSize(protocol.Metrics{ID: c.Name, MType: protocol.TypeCounter, Delta: &c.Value}))
+		batch.Counters = append(batch.Counters, c)
+	}
+	for _, g := range metrics.Gauges {
+		add(encodedSize(protocol.Metrics{ID: g.Name, MType: protocol.TypeGauge, Value: &g.Value}))
+		batch.Gauges = append(batch.Gauges, g)
+	}
+	if count > 0 {
+		result = append(result, batch)
+	}
+	return result
+}
+
+// encodedSize возвращает размер метрики в формате JSON.
+func encodedSize(m protocol.Metrics) int {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return 0
+	}
+	return len(data)
 }
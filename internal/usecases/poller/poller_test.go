@@ -2,12 +2,20 @@ package poller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/k1nky/ypmetrics/internal/collector"
 	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
 	log "github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/storage"
 )
 
 func BenchmarkPoll(b *testing.B) {
@@ -61,3 +69,297 @@ func BenchmarkPoll(b *testing.B) {
 		})
 	}
 }
+
+type fakeSender struct {
+	mu       sync.Mutex
+	inflight int
+	// максимальное количество одновременных запросов
+	maxInflight int
+	batches     []metric.Metrics
+	delay       time.Duration
+	// ошибка, которую возвращает каждая отправка
+	err error
+}
+
+func (s *fakeSender) PushCounterContext(ctx context.Context, name string, value int64) error {
+	return nil
+}
+
+func (s *fakeSender) PushGaugeContext(ctx context.Context, name string, value float64) error {
+	return nil
+}
+
+func (s *fakeSender) PushMetricsContext(ctx context.Context, m metric.Metrics) error {
+	s.mu.Lock()
+	s.inflight++
+	if s.inflight > s.maxInflight {
+		s.maxInflight = s.inflight
+	}
+	s.batches = append(s.batches, m)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inflight--
+		s.mu.Unlock()
+	}()
+	t := time.NewTimer(s.delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return s.err
+}
+
+func newTestMetrics(counters int, gauges int) metric.Metrics {
+	m := metric.Metrics{}
+	for i := 0; i < counters; i++ {
+		m.Counters = append(m.Counters, metric.NewCounter(fmt.Sprintf("c%d", i), int64(i)))
+	}
+	for i := 0; i < gauges; i++ {
+		m.Gauges = append(m.Gauges, metric.NewGauge(fmt.Sprintf("g%d", i), float64(i)))
+	}
+	return m
+}
+
+func TestSplitMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  metric.Metrics
+		maxCount int
+		maxBytes int
+		want     []int
+	}{
+		{name: "No limits", metrics: newTestMetrics(3, 3), want: []int{6}},
+		{name: "By count", metrics: newTestMetrics(3, 2), maxCount: 2, want: []int{2, 2, 1}},
+		// {"id":"c0","type":"counter","delta":0} - 38 байт, с учетом скобок и разделителя два счетчика - 79 байт
+		{name: "By bytes", metrics: newTestMetrics(5, 0), maxBytes: 79, want: []int{2, 2, 1}},
+		{name: "Metric larger than limit", metrics: newTestMetrics(2, 0), maxBytes: 10, want: []int{1, 1}},
+		{name: "By count and bytes", metrics: newTestMetrics(5, 0), maxCount: 1, maxBytes: 1000, want: []int{1, 1, 1, 1, 1}},
+		{name: "Empty", metrics: metric.Metrics{}, maxCount: 1, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMetrics(tt.metrics, tt.maxCount, tt.maxBytes)
+			sizes := make([]int, 0, len(got))
+			total := 0
+			for _, b := range got {
+				sizes = append(sizes, len(b.Counters)+len(b.Gauges))
+				total += len(b.Counters) + len(b.Gauges)
+			}
+			assert.Equal(t, tt.want, sizes)
+			assert.Equal(t, len(tt.metrics.Counters)+len(tt.metrics.Gauges), total)
+		})
+	}
+}
+
+func TestReportWorkers(t *testing.T) {
+	store := storage.NewMemStorage()
+	store.UpdateMetrics(context.Background(), newTestMetrics(10, 10))
+	s := &fakeSender{delay: 100 * time.Millisecond}
+	p := New(config.Poller{
+		ReportIntervalInSec:  1,
+		RateLimit:            4,
+		BatchSize:            2,
+		ShutdownTimeoutInSec: 1,
+	}, store, &log.Blackhole{}, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	stored := make(chan struct{})
+	close(stored)
+	<-p.report(ctx, stored)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, 4, s.maxInflight)
+	// одна отправка по таймеру и одна при завершении
+	assert.Len(t, s.batches, 20)
+	for _, b := range s.batches {
+		assert.Equal(t, 2, len(b.Counters)+len(b.Gauges))
+	}
+}
+
+func TestReportRequestsPerSecond(t *testing.T) {
+	store := storage.NewMemStorage()
+	store.UpdateMetrics(context.Background(), newTestMetrics(10, 0))
+	s := &fakeSender{}
+	p := New(config.Poller{
+		ReportIntervalInSec: 1,
+		RateLimit:           4,
+		BatchSize:           1,
+		RequestsPerSecond:   4,
+	}, store, &log.Blackhole{}, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1900*time.Millisecond)
+	defer cancel()
+	stored := make(chan struct{})
+	close(stored)
+	// таймаут завершения не задан, поэтому последняя отправка не выполняется
+	<-p.report(ctx, stored)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// первая отправка через 1 сек, затем не более 4 запросов в секунду
+	assert.LessOrEqual(t, len(s.batches), 5)
+	assert.GreaterOrEqual(t, len(s.batches), 3)
+}
+
+func TestRunFinalReport(t *testing.T) {
+	store := storage.NewMemStorage()
+	s := &fakeSender{}
+	p := New(config.Poller{
+		PollIntervalInSec: 1,
+		// до завершения метрики по таймеру не отправляются
+		ReportIntervalInSec:  100,
+		ShutdownTimeoutInSec: 1,
+	}, store, &log.Blackhole{}, s)
+	p.AddCollector(&collector.PollCounter{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	done := p.Run(ctx)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("poller did not stop within shutdown timeout")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if assert.Len(t, s.batches, 1) {
+		// счетчик сборщика и счетчик ошибок его опроса
+		names := []string{}
+		for _, c := range s.batches[0].Counters {
+			names = append(names, c.Name)
+		}
+		assert.ElementsMatch(t, []string{"PollCount", CollectErrorsMetric + ".PollCounter"}, names)
+	}
+}
+
+func TestRunShutdownTimeout(t *testing.T) {
+	store := storage.NewMemStorage()
+	store.UpdateMetrics(context.Background(), newTestMetrics(2, 0))
+	// отправка занимает больше таймаута завершения
+	s := &fakeSender{delay: 3 * time.Second}
+	p := New(config.Poller{
+		PollIntervalInSec:    1,
+		ReportIntervalInSec:  100,
+		BatchSize:            1,
+		ShutdownTimeoutInSec: 1,
+	}, store, &log.Blackhole{}, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := p.Run(ctx)
+	cancel()
+	start := time.Now()
+	<-done
+	// первая отправка прерывается по таймауту, вторая уже не начинается
+	assert.Less(t, time.Since(start), 2*time.Second)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Len(t, s.batches, 1)
+}
+
+// countingCollector считает количество опросов, а при заданной задержке ожидает ее или завершения контекста.
+type countingCollector struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (c *countingCollector) Init() error {
+	return nil
+}
+
+func (c *countingCollector) Collect(ctx context.Context) (metric.Metrics, error) {
+	c.calls.Add(1)
+	if c.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return metric.Metrics{}, ctx.Err()
+		case <-time.After(c.delay):
+		}
+	}
+	return metric.Metrics{}, nil
+}
+
+type initFailingCollector struct {
+	countingCollector
+}
+
+func (c *initFailingCollector) Init() error {
+	return errors.New("init failed")
+}
+
+func TestAddNamedCollector(t *testing.T) {
+	p := New(config.Poller{PollIntervalInSec: 1}, storage.NewMemStorage(), &log.Blackhole{}, &fakeSender{})
+	assert.Error(t, p.AddNamedCollector("failing", &initFailingCollector{}, config.CollectorConfig{}))
+	assert.NoError(t, p.AddNamedCollector("counting", &countingCollector{}, config.CollectorConfig{}))
+	if assert.Len(t, p.collectors, 1) {
+		assert.Equal(t, "counting", p.collectors[0].name)
+	}
+}
+
+func TestPollCollectorIntervals(t *testing.T) {
+	p := New(config.Poller{PollIntervalInSec: 1}, storage.NewMemStorage(), &log.Blackhole{}, &fakeSender{})
+	fast, slow := &countingCollector{}, &countingCollector{}
+	p.AddNamedCollector("fast", fast, config.CollectorConfig{})
+	p.AddNamedCollector("slow", slow, config.CollectorConfig{IntervalInSec: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	for range p.poll(ctx, 2) {
+	}
+	assert.Equal(t, int32(2), fast.calls.Load())
+	assert.Equal(t, int32(0), slow.calls.Load())
+}
+
+func TestPollCollectorTimeout(t *testing.T) {
+	p := New(config.Poller{PollIntervalInSec: 2}, storage.NewMemStorage(), &log.Blackhole{}, &fakeSender{})
+	p.AddNamedCollector("hung", &countingCollector{delay: time.Minute}, config.CollectorConfig{TimeoutInSec: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3500*time.Millisecond)
+	defer cancel()
+	for range p.poll(ctx, 1) {
+	}
+	status := p.Status().Collectors["hung"]
+	assert.Equal(t, context.DeadlineExceeded.Error(), status.LastError)
+}
+
+func TestPollCollectorDefaultTimeout(t *testing.T) {
+	p := New(config.Poller{PollIntervalInSec: 1}, storage.NewMemStorage(), &log.Blackhole{}, &fakeSender{})
+	p.AddNamedCollector("hung", &countingCollector{delay: time.Minute}, config.CollectorConfig{})
+	p.AddNamedCollector("slow", &countingCollector{delay: time.Minute}, config.CollectorConfig{IntervalInSec: 2})
+	assert.Equal(t, 900*time.Millisecond, p.collectors[0].timeout)
+	assert.Equal(t, 1800*time.Millisecond, p.collectors[1].timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	for range p.poll(ctx, 2) {
+	}
+	// сборщик так и не завершился успешно, поэтому последняя ошибка - таймаут опроса
+	status := p.Status().Collectors["hung"]
+	assert.Equal(t, context.DeadlineExceeded.Error(), status.LastError)
+}
+
+func TestPollCollectorBusy(t *testing.T) {
+	p := New(config.Poller{PollIntervalInSec: 1}, storage.NewMemStorage(), &log.Blackhole{}, &fakeSender{})
+	c := &countingCollector{delay: 1500 * time.Millisecond}
+	p.AddNamedCollector("slow", c, config.CollectorConfig{TimeoutInSec: 10})
+
+	// первый опрос выполняется с 1 до 2.5 секунды, опрос на 2 секунде пропускается
+	ctx, cancel := context.WithTimeout(context.Background(), 3200*time.Millisecond)
+	defer cancel()
+	for range p.poll(ctx, 2) {
+	}
+	// пропущенный опрос ошибкой не считается
+	status := p.Status().Collectors["slow"]
+	assert.Empty(t, status.LastError)
+	assert.False(t, status.LastSuccess.IsZero())
+}
+
+func TestPollWorkers(t *testing.T) {
+	assert.Equal(t, MaxPollWorkers, New(config.Poller{}, nil, &log.Blackhole{}, nil).pollWorkers())
+	assert.Equal(t, 5, New(config.Poller{PollWorkers: 5}, nil, &log.Blackhole{}, nil).pollWorkers())
+}
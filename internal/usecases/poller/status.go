@@ -0,0 +1,117 @@
+package poller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CollectorStatus состояние опроса сборщика метрик.
+type CollectorStatus struct {
+	// LastSuccess время последнего успешного опроса.
+	LastSuccess time.Time `json:"last_success"`
+	// LastErrorTime время последнего неудачного опроса.
+	LastErrorTime time.Time `json:"last_error_time"`
+	// LastError ошибка последнего неудачного опроса.
+	LastError string `json:"last_error,omitempty"`
+	// DisabledUntil время, до которого сборщик отключен из-за ошибок опроса.
+	DisabledUntil time.Time `json:"disabled_until"`
+}
+
+// PushStatus состояние отправки метрик на сервер.
+type PushStatus struct {
+	// LastTime время последней отправки.
+	LastTime time.Time `json:"last_time"`
+	// LastSuccess время последней успешной отправки.
+	LastSuccess time.Time `json:"last_success"`
+	// LastError ошибка последней отправки, пустая при успешной отправке.
+	LastError string `json:"last_error,omitempty"`
+	// ConsecutiveFailures количество неудачных отправок подряд.
+	ConsecutiveFailures uint `json:"consecutive_failures"`
+}
+
+// Status состояние агента: результаты опроса сборщиков и отправки метрик.
+type Status struct {
+	// Collectors состояние сборщиков по их именам.
+	Collectors map[string]CollectorStatus `json:"collectors"`
+	// Push состояние отправки метрик.
+	Push PushStatus `json:"push"`
+}
+
+// statusTracker накапливает состояние агента. Безопасен для конкурентного использования.
+type statusTracker struct {
+	mu     sync.Mutex
+	status Status
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		status: Status{
+			Collectors: make(map[string]CollectorStatus),
+		},
+	}
+}
+
+// collected фиксирует результат опроса сборщика name.
+func (t *statusTracker) collected(name string, err error, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.status.Collectors[name]
+	if err != nil {
+		s.LastErrorTime = at
+		s.LastError = err.Error()
+	} else {
+		s.LastSuccess = at
+	}
+	t.status.Collectors[name] = s
+}
+
+// disabled фиксирует отключение сборщика name до момента until.
+func (t *statusTracker) disabled(name string, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.status.Collectors[name]
+	s.DisabledUntil = until
+	t.status.Collectors[name] = s
+}
+
+// pushed фиксирует результат отправки метрик на сервер.
+func (t *statusTracker) pushed(err error, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &t.status.Push
+	s.LastTime = at
+	if err != nil {
+		s.LastError = err.Error()
+		s.ConsecutiveFailures++
+	} else {
+		s.LastError = ""
+		s.LastSuccess = at
+		s.ConsecutiveFailures = 0
+	}
+}
+
+// snapshot возвращает копию текущего состояния.
+func (t *statusTracker) snapshot() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := Status{
+		Collectors: make(map[string]CollectorStatus, len(t.status.Collectors)),
+		Push:       t.status.Push,
+	}
+	for name, c := range t.status.Collectors {
+		s.Collectors[name] = c
+	}
+	return s
+}
+
+// collectorName возвращает имя сборщика для отображения в состоянии агента.
+// Для сборщиков из пакета collector это имя типа, например, Gops.
+func collectorName(c Collector) string {
+	name := fmt.Sprintf("%T", c)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
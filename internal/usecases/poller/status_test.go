@@ -0,0 +1,71 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/k1nky/ypmetrics/internal/collector"
+	"github.com/k1nky/ypmetrics/internal/config"
+	"github.com/k1nky/ypmetrics/internal/entities/metric"
+	log "github.com/k1nky/ypmetrics/internal/logger"
+	"github.com/k1nky/ypmetrics/internal/storage"
+)
+
+type failingCollector struct{}
+
+func (failingCollector) Init() error {
+	return nil
+}
+
+func (failingCollector) Collect(ctx context.Context) (metric.Metrics, error) {
+	return metric.Metrics{}, errors.New("collect failed")
+}
+
+func TestStatusTrackerPushed(t *testing.T) {
+	tracker := newStatusTracker()
+	now := time.Now()
+	tracker.pushed(errors.New("push failed"), now)
+	tracker.pushed(errors.New("push failed"), now.Add(time.Second))
+	s := tracker.snapshot()
+	assert.Equal(t, uint(2), s.Push.ConsecutiveFailures)
+	assert.Equal(t, "push failed", s.Push.LastError)
+	assert.True(t, s.Push.LastSuccess.IsZero())
+
+	tracker.pushed(nil, now.Add(2*time.Second))
+	s = tracker.snapshot()
+	assert.Equal(t, uint(0), s.Push.ConsecutiveFailures)
+	assert.Empty(t, s.Push.LastError)
+	assert.Equal(t, now.Add(2*time.Second), s.Push.LastSuccess)
+	assert.Equal(t, s.Push.LastSuccess, s.Push.LastTime)
+}
+
+func TestRunStatus(t *testing.T) {
+	s := &fakeSender{err: errors.New("push failed")}
+	p := New(config.Poller{
+		PollIntervalInSec:    1,
+		ReportIntervalInSec:  1,
+		ShutdownTimeoutInSec: 1,
+	}, storage.NewMemStorage(), &log.Blackhole{}, s)
+	p.AddCollector(&collector.PollCounter{}, failingCollector{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	<-p.Run(ctx)
+
+	status := p.Status()
+	if assert.Contains(t, status.Collectors, "PollCounter") {
+		assert.False(t, status.Collectors["PollCounter"].LastSuccess.IsZero())
+		assert.Empty(t, status.Collectors["PollCounter"].LastError)
+	}
+	if assert.Contains(t, status.Collectors, "failingCollector") {
+		assert.True(t, status.Collectors["failingCollector"].LastSuccess.IsZero())
+		assert.Equal(t, "collect failed", status.Collectors["failingCollector"].LastError)
+	}
+	// отправка по таймеру и при завершении
+	assert.Equal(t, uint(2), status.Push.ConsecutiveFailures)
+	assert.Equal(t, "push failed", status.Push.LastError)
+}